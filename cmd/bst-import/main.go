@@ -0,0 +1,137 @@
+// Command bst-import backfills BaseStation BST log files into the time-series
+// database configured for the collector (OUTPUT_DB_TYPE and friends).
+//
+//	go run ./cmd/bst-import [-config collector.yaml] [-receiver name] [-timezone Europe/London] file.bst...
+//
+// Use "-" to read from standard input. Lines that do not decode are logged and skipped.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/bst"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/timeseries"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+func main() {
+	configPath := flag.String("config", "", "YAML or TOML configuration file (default CONFIG_FILE)")
+	receiver := flag.String("receiver", "", "receiver name to tag the imported records with (default RECEIVER_NAME)")
+	timezone := flag.String("timezone", "", "time zone of the BaseStation that wrote the files (default RECEIVER_TIMEZONE)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] file.bst... (\"-\" for stdin)\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *receiver == "" {
+		*receiver = cfg.Receiver.Name
+	}
+	if *timezone == "" {
+		*timezone = cfg.Receiver.Timezone
+	}
+	loc, err := time.LoadLocation(*timezone)
+	if err != nil {
+		log.Fatalf("Invalid time zone %q: %v", *timezone, err)
+	}
+
+	writer, err := timeseries.NewWriterFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create time-series writer: %v", err)
+	}
+	defer writer.Close()
+
+	imp := &importer{
+		writer:    writer,
+		receiver:  *receiver,
+		loc:       loc,
+		batchSize: cfg.Pipeline.Batch.Size,
+	}
+	failed := false
+	for _, path := range flag.Args() {
+		if err := imp.importFile(path); err != nil {
+			log.Printf("Failed to import %s: %v", path, err)
+			failed = true
+		}
+	}
+	log.Printf("Imported %d records (%d lines skipped).", imp.imported, imp.skipped)
+	if failed {
+		writer.Close()
+		os.Exit(1)
+	}
+}
+
+type importer struct {
+	writer    timeseries.TimeSeriesWriter
+	receiver  string
+	loc       *time.Location
+	batchSize int
+
+	imported int
+	skipped  int
+}
+
+func (imp *importer) importFile(path string) error {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	reader := bst.NewReader(in, imp.loc)
+	batch := make([]models.AircraftData, 0, imp.batchSize)
+	for {
+		data, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Printf("[%s] Skipping %v", path, err)
+			imp.skipped++
+			continue
+		}
+		data.Receiver = imp.receiver
+		batch = append(batch, *data)
+		if len(batch) >= imp.batchSize {
+			if err := imp.flush(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if n := reader.SquawkMismatches(); n > 0 {
+		log.Printf("[%s] Warning: %d lines: %v, kept the octal squawks.", path, n, bst.ErrSquawkMismatch)
+	}
+	return imp.flush(batch)
+}
+
+func (imp *importer) flush(batch []models.AircraftData) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := imp.writer.WriteBatch(context.Background(), batch); err != nil {
+		return fmt.Errorf("failed to write batch: %w", err)
+	}
+	imp.imported += len(batch)
+	return nil
+}
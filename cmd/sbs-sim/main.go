@@ -0,0 +1,81 @@
+// Command sbs-sim serves a synthetic dump1090 SBS-1 feed for load and
+// integration testing, with optional fault injection.
+//
+//	go run ./cmd/sbs-sim -listen :30003 -aircraft 200 -rate 2000 -malformed 0.01 -disconnect-every 5m
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/sbssim"
+)
+
+func main() {
+	listen := flag.String("listen", ":30003", "address to serve the SBS-1 feed on")
+	aircraft := flag.Int("aircraft", 50, "number of aircraft in the air at any time")
+	rate := flag.Float64("rate", 500, "SBS-1 lines per second across all aircraft")
+	centerLat := flag.Float64("lat", 51.4700, "latitude of the centre of the simulated area")
+	centerLon := flag.Float64("lon", -0.4543, "longitude of the centre of the simulated area")
+	radius := flag.Float64("radius", 200, "radius of the simulated area in km")
+	lifetime := flag.Duration("lifetime", 20*time.Minute, "average time an aircraft stays before it is replaced")
+	seed := flag.Int64("seed", 0, "random seed, for reproducible traffic (0 picks one)")
+	timezone := flag.String("timezone", "UTC", "time zone of the simulated receiver's clock")
+	malformed := flag.Float64("malformed", 0, "fraction of lines to corrupt")
+	disconnectEvery := flag.Duration("disconnect-every", 0, "drop all clients this often (0 disables)")
+	stallEvery := flag.Duration("stall-every", 0, "stop sending this often while keeping clients connected (0 disables)")
+	stallFor := flag.Duration("stall-for", 30*time.Second, "how long a stall lasts")
+	statsEvery := flag.Duration("stats", 10*time.Second, "how often to log the number of lines sent (0 disables)")
+	flag.Parse()
+
+	loc, err := time.LoadLocation(*timezone)
+	if err != nil {
+		log.Fatalf("Invalid time zone %q: %v", *timezone, err)
+	}
+
+	srv := sbssim.NewServer(*listen, sbssim.Options{
+		Aircraft:  *aircraft,
+		Rate:      *rate,
+		CenterLat: *centerLat,
+		CenterLon: *centerLon,
+		RadiusKm:  *radius,
+		Lifetime:  *lifetime,
+		Seed:      *seed,
+		Location:  loc,
+		Faults: sbssim.Faults{
+			Malformed:       *malformed,
+			DisconnectEvery: *disconnectEvery,
+			StallEvery:      *stallEvery,
+			StallFor:        *stallFor,
+		},
+	})
+	if err := srv.Start(); err != nil {
+		log.Fatalf("Failed to start simulator: %v", err)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	var stats <-chan time.Time
+	if *statsEvery > 0 {
+		ticker := time.NewTicker(*statsEvery)
+		defer ticker.Stop()
+		stats = ticker.C
+	}
+	for {
+		select {
+		case <-stats:
+			log.Printf("Sent %d lines to %d clients (%d disconnects, %d stalls injected).", srv.Sent(), srv.Clients(), srv.Disconnects(), srv.Stalls())
+		case <-sigChan:
+			if err := srv.Close(); err != nil {
+				log.Printf("Error stopping simulator: %v", err)
+			}
+			log.Printf("Stopped after sending %d lines.", srv.Sent())
+			return
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Package collector reads SBS-1 feeds from dump1090, parses them and writes
+// the records in batches to time-series databases. It is the engine of the
+// dump1090-collector command and can be embedded in other programs:
+//
+//	cfg, err := config.Load("collector.yaml")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	c, err := collector.New(cfg, collector.OnRecord(func(data *models.AircraftData) {
+//		fmt.Println(data.HexIdent, data.Altitude)
+//	}))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	err = c.Run(ctx) // until ctx is cancelled
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/api"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/clockskew"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/parser"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/rebroadcast"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/state"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/status"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/stream"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/telemetry"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/timeseries"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+	"github.com/m03315/go-dump1090-timeseries-collector/pipeline"
+)
+
+// Collector manages the connections to dump1090 and data ingestion.
+type Collector struct {
+	config      *config.Config
+	options     options
+	sinks       *timeseries.SinkSet // the configured sinks, reloadable
+	writer      timeseries.TimeSeriesWriter
+	telemetry   *telemetry.Telemetry
+	status      *status.Server            // nil when the HTTP server is disabled
+	state       *state.Store              // nil when the live API is disabled
+	stream      *stream.Hub               // nil when streaming is disabled
+	rebroadcast *rebroadcast.Server       // nil when rebroadcasting is disabled
+	parsers     map[string]*parser.Parser // per source, so that field errors can be attributed to a receiver
+	clockSkew   *clockskew.Detector       // nil when clock skew detection is disabled
+	stages      []pipeline.Stage          // configured stages, followed by those of WithStage
+	emitted     []models.AircraftData     // records emitted by the stages for the record being processed
+	processing  string                    // name of the stage processing the record, for pipeline.Count
+	dataChan    chan rawLine
+	batchChan   chan pendingBatch
+	errorChan   chan error
+	stopped     chan struct{}       // closed once batchWriter has written everything, e.g. at the end of a replay
+	reloadChan  chan pipelineUpdate // reloaded pipeline settings for parseAndBatchData
+	reloads     chan chan error     // Reload requests, served by Run
+
+	// Lifecycle: cancelling ctx stops the sources; the pipeline then drains
+	// through dataChan and batchChan, which each stage closes when it is done.
+	started      atomic.Bool
+	ctx          context.Context
+	cancel       context.CancelFunc
+	writeCtx     context.Context // cancelled when the shutdown deadline passes, abandoning pending writes
+	cancelWrites context.CancelFunc
+	workers      sync.WaitGroup // parseAndBatchData and batchWriter
+	errorsDone   chan struct{}  // closed once errorHandler has logged every error
+	drain        drainStats     // written by batchWriter, read once workers are done
+
+	sourcesMu     sync.Mutex
+	sources       map[string]*sourceRunner // running (or finished) reader of every configured source
+	activeSources int                      // readers still running; dataChan is closed when it drops to 0
+	sourcesDone   bool                     // dataChan has been closed
+}
+
+// New creates a collector for cfg, adjusted by opts, and opens its sinks.
+// Nothing is read until Run is called.
+func New(cfg *config.Config, opts ...Option) (*Collector, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	cfg, err := o.configure(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	stages, err := pipeline.Build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sinks, err := timeseries.NewSinkSet(cfg)
+	if err != nil {
+		pipeline.Close(stages)
+		return nil, fmt.Errorf("failed to create time-series writer: %w", err)
+	}
+	var writer timeseries.TimeSeriesWriter = sinks
+	if len(o.writers) > 0 {
+		names := []string{"sinks"}
+		writers := []timeseries.TimeSeriesWriter{sinks}
+		for i, w := range o.writers {
+			names = append(names, fmt.Sprintf("writer[%d]", i))
+			writers = append(writers, w)
+		}
+		writer = timeseries.NewMultiWriter(names, writers)
+	}
+
+	tel, err := telemetry.New(context.Background(), telemetry.Options{
+		Endpoint:       cfg.Telemetry.OTLPEndpoint,
+		ExportInterval: cfg.Telemetry.ExportInterval,
+		TracesEnabled:  cfg.Telemetry.Traces,
+		ServiceName:    "dump1090-collector",
+		TracerProvider: o.tracerProvider,
+	})
+	if err != nil {
+		pipeline.Close(stages)
+		writer.Close()
+		return nil, fmt.Errorf("failed to initialize OpenTelemetry: %w", err)
+	}
+	if cfg.Telemetry.OTLPEndpoint != "" {
+		log.Printf("Exporting collector telemetry via OTLP/HTTP to %s (traces: %t).", cfg.Telemetry.OTLPEndpoint, cfg.Telemetry.Traces)
+	}
+
+	c := &Collector{
+		config:     cfg,
+		options:    o,
+		sinks:      sinks,
+		writer:     writer,
+		telemetry:  tel,
+		stages:     append(stages, o.stages...),
+		dataChan:   make(chan rawLine, 1000),
+		batchChan:  make(chan pendingBatch, 10),
+		errorChan:  make(chan error, 100),
+		errorsDone: make(chan struct{}),
+		stopped:    make(chan struct{}),
+		reloadChan: make(chan pipelineUpdate),
+		reloads:    make(chan chan error),
+		parsers:    make(map[string]*parser.Parser, len(cfg.Sources)),
+		sources:    make(map[string]*sourceRunner, len(cfg.Sources)),
+	}
+	if cfg.HTTP.ListenAddr != "" {
+		c.status = status.NewServer(cfg.HTTP.ListenAddr, tel, cfg.HTTP.ReadyMaxWriteAge)
+		if cfg.API.Enabled {
+			c.state = state.NewStore(cfg.API.StateTTL, cfg.API.MaxTrackPoints)
+			if cfg.Stream.Enabled {
+				c.stream = stream.NewHub(cfg.Stream.ClientBuffer, cfg.Stream.MaxDropped)
+			}
+			c.status.Handle("/api/", api.NewHandler(c.state, c.stream))
+		}
+	}
+	if cfg.Rebroadcast.ListenAddr != "" {
+		c.rebroadcast = rebroadcast.NewServer(cfg.Rebroadcast.ListenAddr, cfg.Rebroadcast.QueueSize, cfg.Rebroadcast.MessageTypes, cfg.Rebroadcast.DedupWindow)
+	}
+
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.writeCtx, c.cancelWrites = context.WithCancel(context.Background())
+	c.setPipeline(nil, cfg)
+	tel.ObserveQueue("dataChan", func() int { return len(c.dataChan) }, cap(c.dataChan))
+	tel.ObserveQueue("batchChan", func() int { return len(c.batchChan) }, cap(c.batchChan))
+	return c, nil
+}
+
+// Run starts the HTTP and rebroadcast servers and collects data until ctx is
+// cancelled or every source has finished (only replays do). It then stops
+// reading, writes every pending batch and stops the servers. Run can only be
+// called once.
+func (c *Collector) Run(ctx context.Context) error {
+	if !c.started.CompareAndSwap(false, true) {
+		return errors.New("collector: Run can only be called once")
+	}
+	if err := c.startServers(); err != nil {
+		c.stopServers()
+		c.closeStages(c.stages)
+		if err := c.writer.Close(); err != nil {
+			log.Printf("Error closing time-series writer: %v", err)
+		}
+		return err
+	}
+	defer c.stopServers()
+
+	log.Println("Starting dump1090 data collector...")
+	go c.errorHandler()
+	if c.state != nil {
+		go c.state.Run(c.ctx.Done())
+	}
+	c.readData()
+	c.workers.Add(2)
+	go c.parseAndBatchData(c.config)
+	go c.batchWriter()
+
+	var fileChanged <-chan struct{}
+	if c.config.File != "" && c.config.Reload.WatchInterval > 0 {
+		fileChanged = watchFile(c.config.File, c.config.Reload.WatchInterval, c.ctx.Done())
+	}
+
+	log.Println("Data collection started.")
+wait:
+	for {
+		select {
+		case result := <-c.reloads:
+			result <- c.reload()
+		case <-fileChanged:
+			log.Printf("Configuration file %s changed. Reloading configuration...", c.config.File)
+			c.reload()
+		case <-ctx.Done():
+			log.Println("Initiating graceful shutdown...")
+			break wait
+		case <-c.stopped:
+			// Only replays run out of lines; everything has been written already.
+			log.Println("All sources finished. Shutting down...")
+			break wait
+		}
+	}
+
+	c.shutdown(c.config.Pipeline.ShutdownTimeout)
+	return nil
+}
+
+// Reload loads the configuration file again, applies the options given to New
+// on top, and switches to it while Run keeps collecting: only the sources and
+// sinks whose settings changed are restarted. If the new configuration is
+// invalid or cannot be applied, the current one is kept and the error returned.
+func (c *Collector) Reload() error {
+	if !c.started.Load() {
+		return errors.New("collector is not running")
+	}
+	result := make(chan error, 1)
+	select {
+	case c.reloads <- result:
+		return <-result
+	case <-c.ctx.Done():
+		return errors.New("collector is shutting down")
+	case <-c.stopped:
+		return errors.New("collector is shutting down")
+	}
+}
+
+// startServers starts the HTTP status server and the SBS-1 rebroadcast server, if enabled.
+func (c *Collector) startServers() error {
+	if c.status != nil {
+		if err := c.status.Start(); err != nil {
+			return fmt.Errorf("failed to start HTTP status server: %w", err)
+		}
+	}
+	if c.rebroadcast != nil {
+		if err := c.rebroadcast.Start(); err != nil {
+			return fmt.Errorf("failed to start SBS-1 rebroadcast server: %w", err)
+		}
+	}
+	return nil
+}
+
+// stopServers stops the servers and flushes the telemetry.
+func (c *Collector) stopServers() {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if c.rebroadcast != nil {
+		if err := c.rebroadcast.Close(); err != nil {
+			log.Printf("Error stopping SBS-1 rebroadcast server: %v", err)
+		}
+	}
+	if c.status != nil {
+		if err := c.status.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error stopping HTTP status server: %v", err)
+		}
+	}
+	if err := c.telemetry.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error flushing telemetry: %v", err)
+	}
+}
+
+// shutdown stops reading from the sources and waits for the lines already read
+// to be parsed and every pending batch to be written to every sink. Writes
+// still pending after timeout are abandoned, and counted as lost.
+func (c *Collector) shutdown(timeout time.Duration) {
+	c.cancel()
+	deadline := time.AfterFunc(timeout, func() {
+		log.Printf("Warning: shutdown deadline of %s exceeded, abandoning pending writes.", timeout)
+		c.cancelWrites()
+	})
+	c.workers.Wait()
+	deadline.Stop()
+	c.cancelWrites()
+
+	// Every goroutine that reports errors has stopped.
+	close(c.errorChan)
+	<-c.errorsDone
+
+	log.Printf("Shutdown complete: flushed %d records in %d batches, %d records lost.", c.drain.records, c.drain.batches, c.drain.lost)
+}
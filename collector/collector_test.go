@@ -0,0 +1,319 @@
+package collector
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/sbssim"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+func TestMain(m *testing.M) {
+	// The collector and the simulator log every connection and batch.
+	flag.Parse()
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeWriter records the size of every batch written to it. With block set,
+// every write after the first `after` batches blocks until its context ends.
+type fakeWriter struct {
+	block bool
+	after int
+
+	mu      sync.Mutex
+	batches []int
+	records int
+	closed  bool
+}
+
+func (w *fakeWriter) WriteBatch(ctx context.Context, batch []models.AircraftData) error {
+	w.mu.Lock()
+	blocked := w.block && len(w.batches) >= w.after
+	if !blocked {
+		w.batches = append(w.batches, len(batch))
+		w.records += len(batch)
+	}
+	w.mu.Unlock()
+	if blocked {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+// written returns the number of records written so far.
+func (w *fakeWriter) written() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.records
+}
+
+// startSimulator starts an SBS-1 simulator on a free port, stopped at the end of the test.
+func startSimulator(t *testing.T, opts sbssim.Options) *sbssim.Server {
+	t.Helper()
+	opts.Seed = 1
+	srv := sbssim.NewServer("127.0.0.1:0", opts)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+// testConfig returns a configuration reading from srv without any server or sink.
+func testConfig(t *testing.T, srv *sbssim.Server) *config.Config {
+	t.Helper()
+	host, port, err := net.SplitHostPort(srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	cfg := config.Default()
+	cfg.Sources = []config.SourceConfig{{Name: "sim", Host: host, Port: portNumber}}
+	cfg.Sinks = nil
+	cfg.HTTP.ListenAddr = ""
+	cfg.Connect.RetryDelay = 20 * time.Millisecond
+	cfg.Pipeline.Batch = config.BatchConfig{Size: 25, Interval: 50 * time.Millisecond}
+	cfg.Pipeline.ShutdownTimeout = 5 * time.Second
+	return cfg
+}
+
+// runCollector runs c in the background. The returned function stops it and
+// returns how long Run took to return.
+func runCollector(t *testing.T, c *Collector) func() time.Duration {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+	return func() time.Duration {
+		cancel()
+		start := time.Now()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Run: %v", err)
+			}
+		case <-time.After(30 * time.Second):
+			t.Fatal("Run did not return after cancellation")
+		}
+		return time.Since(start)
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after timeout.
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCollectorBatchesSimulatedFeed(t *testing.T) {
+	srv := startSimulator(t, sbssim.Options{Aircraft: 5, Rate: 500})
+	cfg := testConfig(t, srv)
+
+	var mu sync.Mutex
+	published := 0
+	w := &fakeWriter{}
+	c, err := New(cfg, WithWriter(w), OnRecord(func(*models.AircraftData) {
+		mu.Lock()
+		published++
+		mu.Unlock()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := runCollector(t, c)
+	waitFor(t, 10*time.Second, "200 records", func() bool { return w.written() >= 200 })
+	stop()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		t.Error("writer was not closed")
+	}
+	full := 0
+	for i, size := range w.batches {
+		if size == 0 || size > cfg.Pipeline.Batch.Size {
+			t.Errorf("batch %d has %d records, want 1 to %d", i, size, cfg.Pipeline.Batch.Size)
+		}
+		if size == cfg.Pipeline.Batch.Size {
+			full++
+		}
+	}
+	if full == 0 {
+		t.Errorf("no batch reached the batch size of %d: %v", cfg.Pipeline.Batch.Size, w.batches)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if w.records != published {
+		t.Errorf("wrote %d records, want every one of the %d published", w.records, published)
+	}
+	if c.drain.lost != 0 {
+		t.Errorf("lost %d records at shutdown, want 0", c.drain.lost)
+	}
+}
+
+func TestCollectorReconnectsAfterDisconnect(t *testing.T) {
+	srv := startSimulator(t, sbssim.Options{
+		Aircraft: 5,
+		Rate:     500,
+		Faults:   sbssim.Faults{DisconnectEvery: 200 * time.Millisecond},
+	})
+	cfg := testConfig(t, srv)
+
+	w := &fakeWriter{}
+	c, err := New(cfg, WithWriter(w))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := runCollector(t, c)
+	defer stop()
+
+	waitFor(t, 10*time.Second, "two disconnects", func() bool { return srv.Disconnects() >= 2 })
+	before := w.written()
+	waitFor(t, 10*time.Second, "records after reconnecting", func() bool {
+		return srv.Clients() > 0 && w.written() > before+50
+	})
+}
+
+func TestCollectorShutdownAbandonsBlockedWrites(t *testing.T) {
+	srv := startSimulator(t, sbssim.Options{Aircraft: 5, Rate: 500})
+	cfg := testConfig(t, srv)
+	cfg.Pipeline.ShutdownTimeout = 300 * time.Millisecond
+
+	var mu sync.Mutex
+	published := 0
+	w := &fakeWriter{block: true, after: 2}
+	c, err := New(cfg, WithWriter(w), OnRecord(func(*models.AircraftData) {
+		mu.Lock()
+		published++
+		mu.Unlock()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := runCollector(t, c)
+	waitFor(t, 10*time.Second, "the batches to back up", func() bool { return len(c.batchChan) == cap(c.batchChan) })
+	elapsed := stop()
+
+	if limit := cfg.Pipeline.ShutdownTimeout + time.Second; elapsed > limit {
+		t.Errorf("Run took %s to return, want less than %s", elapsed, limit)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	written := w.written()
+	if c.drain.lost == 0 {
+		t.Error("no records reported lost")
+	}
+	if c.drain.lost != published-written {
+		t.Errorf("reported %d records lost, want %d (%d published, %d written)", c.drain.lost, published-written, published, written)
+	}
+	if c.drain.records != 0 {
+		t.Errorf("reported %d records flushed, want 0", c.drain.records)
+	}
+}
+
+func TestCollectorReplayIsConnected(t *testing.T) {
+	capture := filepath.Join(t.TempDir(), "capture.sbs")
+	if err := os.WriteFile(capture, []byte(
+		"MSG,3,1,1,4CA2D6,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000,,37000,,,53.1,-6.2,,,0,0,0,0\n"+
+			"MSG,3,1,1,4CA2D6,1,2024/01/01,13:00:00.000,2024/01/01,13:00:00.000,,37000,,,53.2,-6.3,,,0,0,0,0\n",
+	), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.Sources = []config.SourceConfig{{Name: "replay", Files: []string{capture}, Location: time.UTC}}
+	cfg.Sinks = nil
+	cfg.HTTP.ListenAddr = ""
+	cfg.Replay.Speed = 1 // the second line is due in an hour
+	cfg.Pipeline.ShutdownTimeout = 5 * time.Second
+
+	c, err := New(cfg, WithWriter(&fakeWriter{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := runCollector(t, c)
+	waitFor(t, 10*time.Second, "the replay to count as connected", c.telemetry.Connected)
+	stop()
+	if c.telemetry.Connected() {
+		t.Error("still connected after the replay stopped")
+	}
+}
+
+func TestCollectorCountsStageDrops(t *testing.T) {
+	capture := filepath.Join(t.TempDir(), "capture.sbs")
+	if err := os.WriteFile(capture, []byte(
+		"MSG,3,1,1,4CA2D6,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000,,37000,,,53.1,-6.2,,,0,0,0,0\n"+
+			"MSG,3,1,1,4CA2D6,1,2024/01/01,12:00:01.000,2024/01/01,12:00:01.000,,3000,,,53.2,-6.3,,,0,0,0,0\n"+
+			"MSG,4,1,1,4CA2D6,1,2024/01/01,12:00:02.000,2024/01/01,12:00:02.000,,,450,270,,,0,,,,,0\n"+
+			"MSG,3,1,1,3C6586,1,2024/01/01,12:00:03.000,2024/01/01,12:00:03.000,,2000,,,53.3,-6.4,,,0,0,0,0\n",
+	), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		onMissing string
+		low, hex  int64 // records dropped by each stage
+		written   int
+	}{
+		{"keep", 1, 2, 1},
+		{"drop", 2, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.onMissing, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Sources = []config.SourceConfig{{Name: "replay", Files: []string{capture}, Location: time.UTC}}
+			cfg.Sinks = nil
+			cfg.HTTP.ListenAddr = ""
+			cfg.Pipeline.Batch = config.BatchConfig{Size: 25, Interval: 20 * time.Millisecond}
+			cfg.Pipeline.ShutdownTimeout = 5 * time.Second
+			cfg.Pipeline.Stages = []config.StageConfig{
+				{Type: "filter", Name: "low", Expression: "altitude < 10000", OnMissing: tt.onMissing},
+				{Type: "filter", Name: "hex", Expression: `hex_ident == "3C6586"`},
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			w := &fakeWriter{}
+			c, err := New(cfg, WithWriter(w))
+			if err != nil {
+				t.Fatal(err)
+			}
+			stop := runCollector(t, c)
+			waitFor(t, 10*time.Second, "the replay to be processed", func() bool {
+				stages := c.telemetry.Stages()
+				return stages["low"].Dropped+stages["hex"].Dropped == 4-int64(tt.written) && w.written() == tt.written
+			})
+			stop()
+			stages := c.telemetry.Stages()
+			if stages["low"].Dropped != tt.low || stages["hex"].Dropped != tt.hex {
+				t.Errorf("dropped %d by low and %d by hex, want %d and %d", stages["low"].Dropped, stages["hex"].Dropped, tt.low, tt.hex)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+	"github.com/m03315/go-dump1090-timeseries-collector/pipeline"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Writer receives every batch of records, in addition to the configured sinks.
+type Writer interface {
+	WriteBatch(ctx context.Context, batch []models.AircraftData) error
+	Close() error
+}
+
+// Option adjusts a collector created by New.
+type Option func(*options)
+
+type options struct {
+	sources        []config.SourceConfig
+	sourcesSet     bool
+	sinks          []config.SinkConfig
+	sinksSet       bool
+	writers        []Writer
+	stages         []pipeline.Stage
+	onRecord       func(*models.AircraftData)
+	onBatchWritten func([]models.AircraftData, error)
+	onError        func(error)
+	tracerProvider trace.TracerProvider
+}
+
+// WithSources replaces the sources of the configuration. They are kept when
+// the configuration is reloaded.
+func WithSources(sources ...config.SourceConfig) Option {
+	return func(o *options) {
+		o.sources, o.sourcesSet = sources, true
+	}
+}
+
+// WithSinks replaces the sinks of the configuration. They are kept when the
+// configuration is reloaded. WithSinks() without arguments removes every
+// sink, for a collector that only writes to the writers given with WithWriter.
+func WithSinks(sinks ...config.SinkConfig) Option {
+	return func(o *options) {
+		o.sinks, o.sinksSet = sinks, true
+	}
+}
+
+// WithWriter adds a writer that receives every batch alongside the sinks. It
+// is closed when the collector stops.
+func WithWriter(w Writer) Option {
+	return func(o *options) {
+		o.writers = append(o.writers, w)
+	}
+}
+
+// WithStage adds a processing stage after those of the configuration. It is
+// reported under name in logs and metrics, and kept when the configuration is
+// reloaded.
+func WithStage(name string, p pipeline.Processor) Option {
+	return func(o *options) {
+		o.stages = append(o.stages, pipeline.Stage{Name: name, Processor: p})
+	}
+}
+
+// OnRecord calls fn with every record kept by the stages, and every record
+// they emit, before it is batched. fn runs on the pipeline goroutine, so it
+// must be quick, and must not keep the record: copy it if needed.
+func OnRecord(fn func(data *models.AircraftData)) Option {
+	return func(o *options) {
+		o.onRecord = fn
+	}
+}
+
+// OnBatchWritten calls fn after every batch write with the records and the
+// write error, if any. fn must not modify or keep the records.
+func OnBatchWritten(fn func(batch []models.AircraftData, err error)) Option {
+	return func(o *options) {
+		o.onBatchWritten = fn
+	}
+}
+
+// OnError calls fn with every error the collector reports, such as connection,
+// stage and write errors, besides logging it. Lines that fail to parse are
+// only logged and counted in the metrics, as a bad feed produces one per line.
+func OnError(fn func(err error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// WithTracerProvider sends the trace of every batch (read, parse, queue and
+// write) to tp, instead of the OTLP exporter configured in telemetry.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracerProvider = tp
+	}
+}
+
+// configure returns a validated copy of cfg with the options applied.
+func (o *options) configure(base *config.Config) (*config.Config, error) {
+	cfg := *base
+	cfg.Sources = slices.Clone(cfg.Sources)
+	if o.sourcesSet {
+		cfg.Sources = slices.Clone(o.sources)
+	}
+	if o.sinksSet {
+		cfg.Sinks = slices.Clone(o.sinks)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	if len(cfg.Sinks) == 0 && len(o.writers) == 0 {
+		return nil, errors.New("invalid configuration: at least one sink is required")
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,336 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/clockskew"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/parser"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+	"github.com/m03315/go-dump1090-timeseries-collector/pipeline"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rawLine is a line read from dump1090 together with the time it was received.
+type rawLine struct {
+	source   string // name of the dump1090 source the line came from
+	text     string
+	received time.Time
+}
+
+// pendingBatch is a batch on its way to the writer. ctx carries the span that
+// covers the batch from the first line read until it is written.
+type pendingBatch struct {
+	ctx     context.Context
+	records []models.AircraftData
+	flushed time.Time
+}
+
+// pipelineUpdate carries a reloaded configuration to parseAndBatchData.
+type pipelineUpdate struct {
+	cfg    *config.Config
+	stages []pipeline.Stage // the new configured stages; nil when they did not change
+}
+
+// drainStats counts what happened to the batches still pending when shutdown began.
+type drainStats struct {
+	batches int // batches written
+	records int // records written
+	lost    int // records whose write failed or was abandoned at the deadline
+}
+
+// setPipeline creates the parsers and the clock skew detector for cfg,
+// replacing those created for old that no longer match. Parsers of sources
+// that are gone are kept for the lines they may still have queued.
+func (c *Collector) setPipeline(old, cfg *config.Config) {
+	oldSources := make(map[string]config.SourceConfig)
+	if old != nil {
+		for _, src := range old.Sources {
+			oldSources[src.Name] = src
+		}
+	}
+	for _, src := range cfg.Sources {
+		if prev, ok := oldSources[src.Name]; ok && prev.Timezone == src.Timezone && old.Pipeline.Parser == cfg.Pipeline.Parser {
+			continue
+		}
+		c.parsers[src.Name] = parser.NewParser(parser.Options{
+			Location:        src.Location,
+			TimestampSource: parser.TimestampSource(cfg.Pipeline.Parser.TimestampSource),
+			Strict:          cfg.Pipeline.Parser.Strict,
+			OnFieldErrors: func(err *parser.ParseError) {
+				c.telemetry.FieldErrors(context.Background(), src.Name, err.FieldNames())
+				log.Printf("[%s] Warning: dropped fields from message '%s': %v", src.Name, err.Line, err)
+			},
+		})
+	}
+
+	threshold := cfg.Pipeline.Parser.ClockSkewThreshold
+	if old != nil && old.Pipeline.Parser.ClockSkewThreshold == threshold {
+		return
+	}
+	c.clockSkew = nil
+	c.telemetry.ObserveClockSkew(nil)
+	if threshold > 0 {
+		c.clockSkew = clockskew.NewDetector(threshold)
+		c.telemetry.ObserveClockSkew(c.clockSkew.Skews)
+	}
+}
+
+// parseAndBatchData parses every line until dataChan is closed, which happens
+// once all sources have stopped, and then flushes the last batch.
+func (c *Collector) parseAndBatchData(cfg *config.Config) {
+	defer func() {
+		c.closeStages(c.stages)
+		close(c.batchChan)
+		log.Println("parseAndBatchData goroutine stopped.")
+		c.workers.Done()
+	}()
+
+	batchCfg := cfg.Pipeline.Batch
+	batch := make([]models.AircraftData, 0, batchCfg.Size) // !!! Use config.Pipeline.Batch.Size !!!
+	ticker := time.NewTicker(batchCfg.Interval)            // !!! Use config.Pipeline.Batch.Interval !!!
+	defer ticker.Stop()
+
+	// Trace state for the batch currently being assembled.
+	var batchCtx context.Context
+	var assembleSpan trace.Span
+	linesInBatch, parseErrorsInBatch := 0, 0
+
+	// flush queues the batch for writing. The spans of a batch that the
+	// stages or parse errors left empty end here, as it is not written.
+	flush := func() {
+		if batchCtx == nil {
+			return // nothing read since the last flush
+		}
+		assembleSpan.SetAttributes(
+			attribute.Int("lines", linesInBatch),
+			attribute.Int("parse_errors", parseErrorsInBatch),
+			attribute.Int("records", len(batch)),
+		)
+		assembleSpan.End()
+		if len(batch) > 0 {
+			c.telemetry.BatchQueued()
+			c.batchChan <- pendingBatch{ctx: batchCtx, records: batch, flushed: time.Now()}
+			batch = make([]models.AircraftData, 0, batchCfg.Size)
+		} else {
+			batchSpan := trace.SpanFromContext(batchCtx)
+			batchSpan.SetAttributes(attribute.Int("records", 0))
+			batchSpan.End()
+		}
+		batchCtx, assembleSpan = nil, nil
+		linesInBatch, parseErrorsInBatch = 0, 0
+	}
+
+	for {
+		select {
+		case line, ok := <-c.dataChan:
+			if !ok {
+				if len(batch) > 0 {
+					log.Println("Parse and Batch: Flushing final data after raw data channel closed.")
+				}
+				flush()
+				return
+			}
+
+			if batchCtx == nil {
+				batchCtx, _ = c.telemetry.Tracer().Start(context.Background(), "collector.batch", trace.WithTimestamp(line.received))
+				batchCtx = pipeline.WithEmitter(batchCtx, c.emit)
+				batchCtx = pipeline.WithCounter(batchCtx, c.count)
+				_, assembleSpan = c.telemetry.Tracer().Start(batchCtx, "collector.read_parse", trace.WithTimestamp(line.received))
+			}
+			linesInBatch++
+
+			data, err := c.parsers[line.source].Parse(line.text, line.received)
+			if err != nil {
+				parseErrorsInBatch++
+				c.telemetry.ParseError(batchCtx)
+				var perr *parser.ParseError
+				if errors.As(err, &perr) {
+					c.telemetry.FieldErrors(batchCtx, line.source, perr.FieldNames())
+				}
+				log.Printf("[%s] Parse error for message '%s': %v", line.source, line.text, err)
+				continue
+			}
+			if data != nil {
+				data.Receiver = line.source
+				if c.clockSkew != nil {
+					// The logged time is when dump1090 wrote the line, the closest to the collector reading it.
+					c.clockSkew.Observe(line.source, data.LoggedTimestamp, line.received)
+				}
+				if c.process(batchCtx, data) {
+					batch = c.publish(batch, data)
+				}
+				// The store, the stream and the batch all hold copies, so the record can be reused.
+				parser.Release(data)
+				// Records emitted by the stages, such as zone events, follow the record.
+				for i := range c.emitted {
+					batch = c.publish(batch, &c.emitted[i])
+				}
+				clear(c.emitted)
+				c.emitted = c.emitted[:0]
+				if len(batch) >= batchCfg.Size { // !!! Use config.Pipeline.Batch.Size !!!
+					flush()
+					ticker.Reset(batchCfg.Interval)
+				}
+			}
+		case update := <-c.reloadChan:
+			c.setPipeline(cfg, update.cfg)
+			if update.stages != nil {
+				configured := c.stages[:len(c.stages)-len(c.options.stages)]
+				c.closeStages(configured)
+				c.stages = append(update.stages, c.options.stages...)
+			}
+			cfg, batchCfg = update.cfg, update.cfg.Pipeline.Batch
+			if len(batch) >= batchCfg.Size {
+				flush()
+			}
+			ticker.Reset(batchCfg.Interval)
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// process runs a record through the stages and reports whether it is kept.
+func (c *Collector) process(ctx context.Context, data *models.AircraftData) bool {
+	for _, stage := range c.stages {
+		c.processing = stage.Name
+		keep, err := stage.Processor.Process(ctx, data)
+		if err != nil {
+			c.telemetry.StageError(ctx, stage.Name)
+			c.errorChan <- fmt.Errorf("[%s] pipeline stage %s: %w", data.Receiver, stage.Name, err)
+		}
+		if !keep {
+			c.telemetry.StageDropped(ctx, stage.Name)
+			return false
+		}
+	}
+	return true
+}
+
+// publish passes a record to the live state, the stream and the OnRecord hook,
+// and appends it to batch.
+func (c *Collector) publish(batch []models.AircraftData, data *models.AircraftData) []models.AircraftData {
+	// Coverage summaries describe no aircraft: they are only written.
+	if !data.IsCoverage() {
+		merged := *data
+		if c.state != nil {
+			merged = c.state.Update(data)
+		}
+		if c.stream != nil {
+			c.stream.Publish(data, &merged)
+		}
+	}
+	if c.options.onRecord != nil {
+		c.options.onRecord(data)
+	}
+	return append(batch, *data)
+}
+
+// emit collects a record emitted by a stage; see pipeline.Emit.
+func (c *Collector) emit(data models.AircraftData) {
+	c.emitted = append(c.emitted, data)
+}
+
+// count adds to a counter of the stage processing the record; see pipeline.Count.
+func (c *Collector) count(ctx context.Context, counter string, n int64) {
+	c.telemetry.StageCount(ctx, c.processing, counter, n)
+}
+
+// closeStages closes the processors of stages, logging any error.
+func (c *Collector) closeStages(stages []pipeline.Stage) {
+	if err := pipeline.Close(stages); err != nil {
+		log.Printf("Error closing pipeline stages: %v", err)
+	}
+}
+
+// batchWriter writes every batch until batchChan is closed. During shutdown it
+// counts what it flushes, and once the deadline has passed it abandons the
+// remaining batches instead of writing them.
+func (c *Collector) batchWriter() {
+	defer func() {
+		defer c.workers.Done()
+		defer close(c.stopped)
+		if c.writer != nil {
+			err := c.writer.Close()
+			if err != nil {
+				log.Printf("Error closing time-series writer: %v", err)
+			} else {
+				log.Println("Time-series writer closed.")
+			}
+		}
+		log.Println("batchWriter goroutine stopped.")
+	}()
+
+	for batch := range c.batchChan {
+		if c.writeCtx.Err() != nil {
+			c.drain.lost += len(batch.records)
+			batchSpan := trace.SpanFromContext(batch.ctx)
+			batchSpan.SetStatus(codes.Error, "abandoned at shutdown deadline")
+			batchSpan.End()
+			continue
+		}
+		err := c.writeBatch(batch)
+		if c.ctx.Err() == nil {
+			continue
+		}
+		if err != nil {
+			c.drain.lost += len(batch.records)
+		} else {
+			c.drain.batches++
+			c.drain.records += len(batch.records)
+		}
+	}
+}
+
+// writeBatch writes a single batch, recording its latency and closing its trace.
+func (c *Collector) writeBatch(batch pendingBatch) error {
+	tracer := c.telemetry.Tracer()
+	batchSpan := trace.SpanFromContext(batch.ctx)
+	_, queueSpan := tracer.Start(batch.ctx, "collector.queue", trace.WithTimestamp(batch.flushed))
+	queueSpan.End()
+
+	writeCtx, writeSpan := tracer.Start(batch.ctx, "collector.write", trace.WithAttributes(attribute.Int("records", len(batch.records))))
+	ctx, cancel := context.WithTimeout(writeCtx, 10*time.Second)
+	stopCancel := context.AfterFunc(c.writeCtx, cancel)
+	start := time.Now()
+	err := c.writer.WriteBatch(ctx, batch.records)
+	latency := time.Since(start)
+	stopCancel()
+	cancel()
+
+	if err != nil {
+		writeSpan.RecordError(err)
+		writeSpan.SetStatus(codes.Error, "write failed")
+		batchSpan.SetStatus(codes.Error, "write failed")
+		c.telemetry.WriteFailed(batch.ctx, latency)
+		c.errorChan <- fmt.Errorf("time-series write error: %w", err)
+	} else {
+		c.telemetry.BatchWritten(batch.ctx, len(batch.records), latency)
+	}
+	writeSpan.End()
+	batchSpan.End()
+	if c.options.onBatchWritten != nil {
+		c.options.onBatchWritten(batch.records, err)
+	}
+	return err
+}
+
+// errorHandler logs every error until errorChan is closed.
+func (c *Collector) errorHandler() {
+	defer close(c.errorsDone)
+	defer log.Println("errorHandler goroutine stopped.")
+	for err := range c.errorChan {
+		log.Printf("ERROR: %v", err)
+		if c.options.onError != nil {
+			c.options.onError(err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+	"github.com/m03315/go-dump1090-timeseries-collector/pipeline"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spanInt returns the value of an integer attribute of span, or -1.
+func spanInt(span sdktrace.ReadOnlySpan, key string) int64 {
+	for _, kv := range span.Attributes() {
+		if kv.Key == attribute.Key(key) {
+			return kv.Value.AsInt64()
+		}
+	}
+	return -1
+}
+
+func TestBatchSpansEndForEmptyBatches(t *testing.T) {
+	const lines = 30
+	capture := filepath.Join(t.TempDir(), "capture.sbs")
+	line := "MSG,3,1,1,4CA2D6,1,2008/11/28,14:53:50.594,2008/11/28,14:58:51.153,,37000,,,51.45735,-1.02826,,,0,0,0,0\n"
+	if err := os.WriteFile(capture, []byte(strings.Repeat(line, lines)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dropAll := pipeline.ProcessorFunc(func(context.Context, *models.AircraftData) (bool, error) { return false, nil })
+	tests := []struct {
+		name    string
+		stages  []Option
+		records int64 // per batch
+		spans   int64 // batch spans
+		written int   // batches written
+	}{
+		{"kept", nil, 10, lines / 10, lines / 10},
+		// The empty batch grows until shutdown, which ends its spans.
+		{"dropped by a stage", []Option{WithStage("drop", dropAll)}, 0, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Sources = []config.SourceConfig{{Name: "replay", Files: []string{capture}}}
+			cfg.Sinks = nil
+			cfg.HTTP.ListenAddr = ""
+			// A batch is flushed every 10 records, or at shutdown.
+			cfg.Pipeline.Batch = config.BatchConfig{Size: 10, Interval: time.Hour}
+
+			recorder := tracetest.NewSpanRecorder()
+			w := &fakeWriter{}
+			opts := append([]Option{WithWriter(w), WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))}, tt.stages...)
+			c, err := New(cfg, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Run returns by itself once the capture has been replayed.
+			if err := c.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if started, ended := len(recorder.Started()), len(recorder.Ended()); started != ended {
+				t.Errorf("%d spans started but %d ended", started, ended)
+			}
+			var batches, lineCount int64
+			for _, span := range recorder.Ended() {
+				switch span.Name() {
+				case "collector.read_parse":
+					lineCount += spanInt(span, "lines")
+					if got := spanInt(span, "records"); got != tt.records {
+						t.Errorf("read_parse span has records=%d, want %d", got, tt.records)
+					}
+				case "collector.batch":
+					batches++
+					if tt.records == 0 && spanInt(span, "records") != 0 {
+						t.Errorf("empty batch span has records=%d, want 0", spanInt(span, "records"))
+					}
+				}
+			}
+			if lineCount != lines {
+				t.Errorf("read_parse spans cover %d lines, want %d", lineCount, lines)
+			}
+			if batches != tt.spans {
+				t.Errorf("%d batch spans, want %d", batches, tt.spans)
+			}
+			if len(w.batches) != tt.written {
+				t.Errorf("%d batches written, want %d", len(w.batches), tt.written)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package collector
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/pipeline"
+)
+
+// reload loads the configuration again and applies it without stopping the
+// pipeline: only the sources and sinks whose settings changed are restarted,
+// and lines already read are still parsed and written. If the new
+// configuration is invalid, the current one is kept.
+func (c *Collector) reload() error {
+	changes, err := c.applyConfig()
+	c.telemetry.ConfigReloaded(context.Background(), err)
+	switch {
+	case err != nil:
+		log.Printf("ERROR: configuration reload failed, keeping the current configuration: %v", err)
+	case len(changes) == 0:
+		log.Println("Configuration reloaded: nothing changed.")
+	default:
+		log.Printf("Configuration reloaded: %s.", strings.Join(changes, ", "))
+	}
+	return err
+}
+
+// applyConfig loads and applies the configuration, returning a description of
+// what changed.
+func (c *Collector) applyConfig() ([]string, error) {
+	loaded, err := config.Load(c.config.File)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := c.options.configure(loaded)
+	if err != nil {
+		return nil, err
+	}
+	old := c.config
+
+	update := pipelineUpdate{cfg: cfg}
+	// Stages may depend on the receiver's settings, e.g. zones centred on it.
+	if !reflect.DeepEqual(old.Pipeline.Stages, cfg.Pipeline.Stages) || (len(cfg.Pipeline.Stages) > 0 && old.Receiver != cfg.Receiver) {
+		if update.stages, err = pipeline.Build(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	var changes []string
+	opened, closed, err := c.sinks.Reload(cfg)
+	if err != nil {
+		c.closeStages(update.stages)
+		return nil, err
+	}
+	for _, typ := range opened {
+		changes = append(changes, "opened "+typ+" sink")
+	}
+	for _, typ := range closed {
+		changes = append(changes, "closed "+typ+" sink")
+	}
+
+	select {
+	case c.reloadChan <- update:
+	case <-c.stopped:
+		c.closeStages(update.stages)
+		return nil, errors.New("the collector is shutting down")
+	}
+	if update.stages != nil {
+		changes = append(changes, "rebuilt pipeline stages")
+	}
+	if old.Pipeline.Parser != cfg.Pipeline.Parser || old.Pipeline.Batch != cfg.Pipeline.Batch || old.Pipeline.ShutdownTimeout != cfg.Pipeline.ShutdownTimeout {
+		changes = append(changes, "updated pipeline settings")
+	}
+
+	changes = append(changes, c.reloadSources(old, cfg)...)
+
+	for _, section := range restartRequired(old, cfg) {
+		log.Printf("Warning: %s settings changed; restart the collector to apply them.", section)
+	}
+	c.config = cfg
+	return changes, nil
+}
+
+// reloadSources stops the readers of sources that were removed or changed and
+// starts those of new or changed sources, leaving the others running.
+func (c *Collector) reloadSources(old, cfg *config.Config) []string {
+	c.sourcesMu.Lock()
+	if c.sourcesDone {
+		c.sourcesMu.Unlock()
+		return nil
+	}
+	// Hold dataChan open while sources are swapped, even if all of them restart.
+	c.activeSources++
+
+	wanted := make(map[string]config.SourceConfig, len(cfg.Sources))
+	for _, src := range cfg.Sources {
+		wanted[src.Name] = src
+	}
+	var changes []string
+	var stopping []*sourceRunner
+	restarted := make(map[string]bool)
+	for _, src := range old.Sources {
+		r := c.sources[src.Name]
+		if r == nil {
+			continue
+		}
+		next, ok := wanted[src.Name]
+		switch {
+		case !ok:
+			changes = append(changes, "removed source "+src.Name)
+		case sourceChanged(old, cfg, r.config, next):
+			restarted[src.Name] = true
+			changes = append(changes, "restarted source "+src.Name)
+		default:
+			continue
+		}
+		stopping = append(stopping, r)
+		delete(c.sources, src.Name)
+	}
+	c.sourcesMu.Unlock()
+
+	for _, r := range stopping {
+		r.stop()
+		<-r.done
+	}
+
+	c.sourcesMu.Lock()
+	defer c.sourcesMu.Unlock()
+	for _, src := range cfg.Sources {
+		if _, running := c.sources[src.Name]; running {
+			continue
+		}
+		if !restarted[src.Name] {
+			changes = append(changes, "added source "+src.Name)
+		}
+		c.startSource(src, cfg)
+	}
+	c.releaseSource()
+	return changes
+}
+
+// sourceChanged reports whether the reader of a source must be restarted to
+// apply the new settings.
+func sourceChanged(old, cfg *config.Config, prev, next config.SourceConfig) bool {
+	if prev.Host != next.Host || prev.Port != next.Port || !slices.Equal(prev.Files, next.Files) {
+		return true
+	}
+	if len(next.Files) > 0 {
+		// Replays read timestamps themselves; live lines are only read by the parser.
+		return prev.Timezone != next.Timezone || old.Replay != cfg.Replay
+	}
+	return old.Connect != cfg.Connect || old.Capture != cfg.Capture
+}
+
+// restartRequired returns the configuration sections that changed but cannot
+// be applied while the collector runs.
+func restartRequired(old, cfg *config.Config) []string {
+	var sections []string
+	for _, s := range []struct {
+		name     string
+		old, new any
+	}{
+		{"http", old.HTTP, cfg.HTTP},
+		{"api", old.API, cfg.API},
+		{"stream", old.Stream, cfg.Stream},
+		{"rebroadcast", old.Rebroadcast, cfg.Rebroadcast},
+		{"telemetry", old.Telemetry, cfg.Telemetry},
+		{"reload", old.Reload, cfg.Reload},
+	} {
+		if !reflect.DeepEqual(s.old, s.new) {
+			sections = append(sections, s.name)
+		}
+	}
+	return sections
+}
+
+// watchFile checks the file at path every interval and signals on the
+// returned channel when its content has changed, until done is closed.
+func watchFile(path string, interval time.Duration, done <-chan struct{}) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+	last, err := fileDigest(path)
+	if err != nil {
+		log.Printf("Warning: cannot watch configuration file: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			// A file that is being replaced may briefly be missing; check again later.
+			digest, err := fileDigest(path)
+			if err != nil || digest == last {
+				continue
+			}
+			last = digest
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return changed
+}
+
+// fileDigest returns a hash of the content of the file at path.
+func fileDigest(path string) ([sha256.Size]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return sha256.Sum256(data), nil
+}
@@ -0,0 +1,275 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/capture"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/replay"
+)
+
+// sourceRunner is the reader of a single source.
+type sourceRunner struct {
+	config config.SourceConfig
+	stop   func()        // asks the reader to stop
+	done   chan struct{} // closed once it has stopped
+}
+
+// connectToDump1090 dials a single dump1090 source, retrying until it succeeds,
+// the retry limit is reached, or the source is stopped.
+func (c *Collector) connectToDump1090(src config.SourceConfig, cfg *config.Config, stop <-chan struct{}) (net.Conn, error) {
+	address := net.JoinHostPort(src.Host, strconv.Itoa(src.Port))
+	log.Printf("[%s] Attempting to connect to dump1090 at %s...", src.Name, address)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var dialer net.Dialer
+	retries := 0
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			log.Printf("[%s] Error connecting to dump1090: %v. Retrying in %s...", src.Name, err, cfg.Connect.RetryDelay)
+			retries++
+			if cfg.Connect.MaxRetries > 0 && retries > cfg.Connect.MaxRetries {
+				return nil, fmt.Errorf("max connection retries (%d) exceeded to dump1090 at %s", cfg.Connect.MaxRetries, address)
+			}
+			select {
+			case <-stop:
+				return nil, fmt.Errorf("source stopped during dump1090 connection attempt")
+			case <-time.After(cfg.Connect.RetryDelay):
+				continue
+			}
+		}
+		c.telemetry.SetConnected(src.Name, true)
+		log.Printf("[%s] Successfully connected to dump1090 at %s.", src.Name, address)
+		return conn, nil
+	}
+}
+
+// readData starts one reader per configured dump1090 source. dataChan is
+// closed once all of them have stopped.
+func (c *Collector) readData() {
+	c.sourcesMu.Lock()
+	defer c.sourcesMu.Unlock()
+	for _, src := range c.config.Sources {
+		c.startSource(src, c.config)
+	}
+}
+
+// startSource starts the reader of src with the settings of cfg. It must be
+// called with sourcesMu held.
+func (c *Collector) startSource(src config.SourceConfig, cfg *config.Config) {
+	stop := make(chan struct{})
+	r := &sourceRunner{config: src, stop: sync.OnceFunc(func() { close(stop) }), done: make(chan struct{})}
+	c.sources[src.Name] = r
+	c.activeSources++
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			r.stop()
+		case <-r.done:
+		}
+	}()
+	go func() {
+		c.readSource(src, cfg, stop)
+		close(r.done)
+		c.sourcesMu.Lock()
+		defer c.sourcesMu.Unlock()
+		c.releaseSource()
+	}()
+}
+
+// releaseSource accounts for a reader that stopped, closing dataChan after the
+// last one. It must be called with sourcesMu held.
+func (c *Collector) releaseSource() {
+	c.activeSources--
+	if c.activeSources == 0 {
+		c.sourcesDone = true
+		close(c.dataChan)
+		log.Println("All sources stopped.")
+	}
+}
+
+// readSource keeps a connection to a single dump1090 source open and forwards
+// every line to the capture file, the rebroadcast server and the parser, until
+// stop is closed. Sources with capture files are replayed instead.
+func (c *Collector) readSource(src config.SourceConfig, cfg *config.Config, stop <-chan struct{}) {
+	if len(src.Files) > 0 {
+		c.replaySource(src, cfg, stop)
+		return
+	}
+
+	var conn net.Conn
+	var capt *capture.Writer
+	defer func() {
+		c.telemetry.SetConnected(src.Name, false)
+		if conn != nil {
+			log.Printf("[%s] Closing dump1090 connection...", src.Name)
+			_ = conn.Close()
+		}
+		if capt != nil {
+			if err := capt.Close(); err != nil {
+				log.Printf("[%s] Error closing capture file: %v", src.Name, err)
+			}
+		}
+	}()
+
+	if cfg.Capture.Dir != "" {
+		var err error
+		capt, err = capture.NewWriter(cfg.Capture.Dir, src.Name, cfg.Capture.RotateInterval, int64(cfg.Capture.MaxSizeMB)<<20, cfg.Capture.Compress)
+		if err != nil {
+			c.errorChan <- fmt.Errorf("[%s] capture disabled: %w", src.Name, err)
+		}
+	}
+
+	for {
+		if conn == nil {
+			var err error
+			if conn, err = c.connectToDump1090(src, cfg, stop); err != nil {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				c.errorChan <- fmt.Errorf("[%s] %w", src.Name, err)
+				select {
+				case <-stop:
+					return
+				case <-time.After(cfg.Connect.RetryDelay):
+					continue
+				}
+			}
+		}
+
+		// Interrupt a blocked read when the source is stopped.
+		connDone := make(chan struct{})
+		go func(conn net.Conn) {
+			select {
+			case <-stop:
+				_ = conn.SetReadDeadline(time.Now())
+			case <-connDone:
+			}
+		}(conn)
+
+		scanner := bufio.NewScanner(conn)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			text := scanner.Text()
+			received := time.Now()
+			c.telemetry.LineRead(context.Background())
+			if capt != nil {
+				if err := capt.WriteLine(text, received); err != nil {
+					c.errorChan <- fmt.Errorf("[%s] capture disabled: %w", src.Name, err)
+					_ = capt.Close()
+					capt = nil
+				}
+			}
+			if c.rebroadcast != nil {
+				c.rebroadcast.Broadcast(src.Name, text)
+			}
+			select {
+			case <-stop:
+				close(connDone)
+				log.Printf("[%s] Reader stopping.", src.Name)
+				return
+			case c.dataChan <- rawLine{source: src.Name, text: text, received: received}:
+			default:
+				c.telemetry.LineDropped(context.Background())
+				log.Println("Warning: Raw data channel full or slow consumer, dropping message to keep up with stream.")
+			}
+		}
+		close(connDone)
+		c.telemetry.SetConnected(src.Name, false)
+		_ = conn.Close()
+		conn = nil
+		select {
+		case <-stop:
+			log.Printf("[%s] Reader stopping.", src.Name)
+			return
+		default:
+		}
+		c.telemetry.Reconnect(context.Background())
+		if err := scanner.Err(); err != nil {
+			log.Printf("[%s] Error reading from dump1090 scanner: %v. Attempting to reconnect...", src.Name, err)
+		} else {
+			log.Printf("[%s] Dump1090 connection appears to be closed by remote. Attempting to reconnect...", src.Name)
+		}
+	}
+}
+
+// replaySource feeds the capture files of a source to the parser one after the
+// other, paced by REPLAY_SPEED. Unlike a live feed, a replay never drops lines:
+// it waits for the parser instead.
+func (c *Collector) replaySource(src config.SourceConfig, cfg *config.Config, stop <-chan struct{}) {
+	// An active replay counts as a connection, so a replay-only collector is ready.
+	c.telemetry.SetConnected(src.Name, true)
+	defer c.telemetry.SetConnected(src.Name, false)
+	pacer := replay.NewPacer(cfg.Replay.Speed)
+	for _, path := range src.Files {
+		if !c.replayFile(src, path, pacer, stop) {
+			log.Printf("[%s] Replay stopping.", src.Name)
+			return
+		}
+	}
+	log.Printf("[%s] Replay finished.", src.Name)
+}
+
+// replayFile replays a single capture file. It returns false if the source was stopped.
+func (c *Collector) replayFile(src config.SourceConfig, path string, pacer *replay.Pacer, stop <-chan struct{}) bool {
+	file, err := replay.Open(path, src.Location)
+	if err != nil {
+		c.errorChan <- fmt.Errorf("[%s] %w", src.Name, err)
+		return true
+	}
+	defer file.Close()
+	log.Printf("[%s] Replaying %s capture %s...", src.Name, file.Format(), path)
+
+	lines := 0
+	for {
+		line, err := file.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				c.errorChan <- fmt.Errorf("[%s] error reading capture %s: %w", src.Name, path, err)
+			}
+			break
+		}
+		if !pacer.Wait(line.Time, stop) {
+			return false
+		}
+		// Keep the original receive time, so that TIMESTAMP_SOURCE=received backfills correctly.
+		received := line.Time
+		if received.IsZero() {
+			received = time.Now()
+		}
+		c.telemetry.LineRead(context.Background())
+		if c.rebroadcast != nil {
+			c.rebroadcast.Broadcast(src.Name, line.Text)
+		}
+		select {
+		case <-stop:
+			return false
+		case c.dataChan <- rawLine{source: src.Name, text: line.Text, received: received}:
+		}
+		lines++
+	}
+	log.Printf("[%s] Replayed %d lines from %s.", src.Name, lines, path)
+	return true
+}
@@ -3,89 +3,373 @@ package config
 import (
 	"fmt"
 	"os"
-	"strconv"
 	"time"
 )
 
 // Config holds all the application configuration settings.
+//
+// Settings come from three layers, each overriding the previous one: the
+// defaults below, an optional YAML or TOML configuration file, and the
+// environment variables documented in the README.
 type Config struct {
-	Dump1090Host      string
-	Dump1090Port      string
-	InfluxHost        string
-	InfluxToken       string
-	InfluxDatabase    string
-	BatchSize         int
-	BatchInterval     time.Duration
-	ConnectRetryDelay time.Duration
-	ConnectMaxRetries int
-	OutputDBType      string // New field to select the output database type
+	Receiver    ReceiverConfig    `yaml:"receiver" toml:"receiver"`
+	Sources     []SourceConfig    `yaml:"sources" toml:"sources"` // every dump1090 feed to ingest
+	Connect     ConnectConfig     `yaml:"connect" toml:"connect"`
+	Pipeline    PipelineConfig    `yaml:"pipeline" toml:"pipeline"`
+	Sinks       []SinkConfig      `yaml:"sinks" toml:"sinks"` // every batch is written to all of them
+	HTTP        HTTPConfig        `yaml:"http" toml:"http"`
+	API         APIConfig         `yaml:"api" toml:"api"`
+	Stream      StreamConfig      `yaml:"stream" toml:"stream"`
+	Rebroadcast RebroadcastConfig `yaml:"rebroadcast" toml:"rebroadcast"`
+	Telemetry   TelemetryConfig   `yaml:"telemetry" toml:"telemetry"`
+	Replay      ReplayConfig      `yaml:"replay" toml:"replay"`
+	Capture     CaptureConfig     `yaml:"capture" toml:"capture"`
+	Reload      ReloadConfig      `yaml:"reload" toml:"reload"`
+
+	File string `yaml:"-" toml:"-"` // configuration file the settings were loaded from, if any
+}
+
+// ReceiverConfig describes the receiving station.
+type ReceiverConfig struct {
+	Name      string  `yaml:"name" toml:"name"`               // identifies the single source, and names Graphite paths
+	Timezone  string  `yaml:"timezone" toml:"timezone"`       // IANA time zone of the receivers' clocks, e.g. "Europe/Paris"
+	Latitude  float64 `yaml:"latitude" toml:"latitude"`       // position of the station in decimal degrees, for geofence zones and the sources without their own
+	Longitude float64 `yaml:"longitude" toml:"longitude"`     // 0 and 0 leave the position unset
+	Altitude  int     `yaml:"altitude_ft" toml:"altitude_ft"` // height of the antenna above mean sea level, for elevation angles
+}
+
+// HasPosition reports whether the station's position is configured.
+func (r ReceiverConfig) HasPosition() bool {
+	return r.Latitude != 0 || r.Longitude != 0
+}
+
+// SourceConfig describes a single dump1090 SBS-1 feed.
+type SourceConfig struct {
+	Name     string         `yaml:"name" toml:"name"` // identifies the receiver in logs, tags and metric names
+	Host     string         `yaml:"host" toml:"host"`
+	Port     int            `yaml:"port" toml:"port"`
+	Timezone string         `yaml:"timezone" toml:"timezone"` // overrides receiver.timezone for this source
+	Files    []string       `yaml:"files" toml:"files"`       // capture files replayed in order instead of connecting to Host:Port
+	Location *time.Location `yaml:"-" toml:"-"`               // time zone of the receiver's clock, used to read SBS-1 timestamps
+
+	Latitude  float64 `yaml:"latitude" toml:"latitude"`       // position of this receiver in decimal degrees, for kinematics and coverage
+	Longitude float64 `yaml:"longitude" toml:"longitude"`     // 0 and 0 use the station's position and altitude
+	Altitude  int     `yaml:"altitude_ft" toml:"altitude_ft"` // height of its antenna above mean sea level
+}
+
+// HasPosition reports whether the source's own position is configured.
+func (s SourceConfig) HasPosition() bool {
+	return s.Latitude != 0 || s.Longitude != 0
+}
+
+// ConnectConfig controls reconnecting to dump1090.
+type ConnectConfig struct {
+	RetryDelay time.Duration `yaml:"retry_delay" toml:"retry_delay"`
+	MaxRetries int           `yaml:"max_retries" toml:"max_retries"` // 0 retries forever
+}
+
+// PipelineConfig controls how lines are parsed and batched.
+type PipelineConfig struct {
+	Parser          ParserConfig  `yaml:"parser" toml:"parser"`
+	Batch           BatchConfig   `yaml:"batch" toml:"batch"`
+	Stages          []StageConfig `yaml:"stages" toml:"stages"`                     // run in order on every parsed record before it is batched
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"` // how long shutdown may take to write the pending batches before they are abandoned
+}
+
+// ParserConfig controls SBS-1 parsing and timestamp handling.
+type ParserConfig struct {
+	Strict             bool          `yaml:"strict" toml:"strict"`                             // reject SBS-1 messages with any undecodable field instead of dropping just that field
+	TimestampSource    string        `yaml:"timestamp_source" toml:"timestamp_source"`         // "generated", "logged" or "received": which timestamp becomes the point time
+	ClockSkewThreshold time.Duration `yaml:"clock_skew_threshold" toml:"clock_skew_threshold"` // warn when a receiver's clock drifts further than this from the collector's; 0 disables
+}
+
+// BatchConfig controls batching of writes.
+type BatchConfig struct {
+	Size     int           `yaml:"size" toml:"size"`
+	Interval time.Duration `yaml:"interval" toml:"interval"` // flush a partial batch after this long
+}
+
+// StageConfig describes one processing stage of the pipeline. Only the settings of its type may be set.
+type StageConfig struct {
+	Type    string            `yaml:"type" toml:"type"`                 // "filter", "geofence", "dedup", "icao", "registry", "airline", "kinematics", "coverage", or a type registered with pipeline.Register
+	Name    string            `yaml:"name,omitempty" toml:"name"`       // identifies the stage in logs and metrics; defaults to the type
+	Options map[string]string `yaml:"options,omitempty" toml:"options"` // settings of registered stage types
+
+	// Filter
+	Expression string `yaml:"expression,omitempty" toml:"expression"` // CEL expression over the record's fields; records for which it is false are dropped
+	OnMissing  string `yaml:"on_missing,omitempty" toml:"on_missing"` // "keep" or "drop" records lacking a field the expression needs
+
+	// Geofence
+	GeoJSON     string       `yaml:"geojson,omitempty" toml:"geojson"`           // file of Polygon and MultiPolygon features, named by their "name" property
+	Zones       []ZoneConfig `yaml:"zones,omitempty" toml:"zones"`               // cylinders centred on the station (receiver.latitude and receiver.longitude)
+	DropOutside bool         `yaml:"drop_outside,omitempty" toml:"drop_outside"` // drop the records of aircraft that are not inside any zone
+
+	// Dedup
+	Window time.Duration `yaml:"window,omitempty" toml:"window"` // drop a record repeating the previous one of the same aircraft and message within this window; for coverage, the rolling window of the maximum distances
+
+	// Registry
+	Path string `yaml:"path,omitempty" toml:"path"` // CSV file of aircraft keyed by address
+
+	// Airline
+	Airlines string `yaml:"airlines,omitempty" toml:"airlines"` // CSV file of airlines keyed by ICAO designator
+	Routes   string `yaml:"routes,omitempty" toml:"routes"`     // optional CSV file of routes keyed by callsign
+
+	// Registry and airline
+	WatchInterval time.Duration `yaml:"watch_interval,omitempty" toml:"watch_interval"` // how often the files are checked for changes
+
+	// Coverage
+	SectorDeg     int           `yaml:"sector_deg,omitempty" toml:"sector_deg"`               // width of the bearing sectors in degrees, a divisor of 360
+	AltitudeBands []int         `yaml:"altitude_bands_ft,omitempty" toml:"altitude_bands_ft"` // boundaries between the altitude bands, ascending
+	Interval      time.Duration `yaml:"interval,omitempty" toml:"interval"`                   // how often coverage records are emitted and the output written
+	Output        string        `yaml:"output,omitempty" toml:"output"`                       // optional GeoJSON file the coverage polygons are written to
+}
+
+// ZoneConfig describes a geofence zone: a cylinder centred on the station.
+// Sources with a position of their own share the station's zones, so that an
+// aircraft heard by several receivers is in the same zones whichever reports it.
+type ZoneConfig struct {
+	Name        string  `yaml:"name" toml:"name"`
+	RadiusKm    float64 `yaml:"radius_km" toml:"radius_km"`
+	MinAltitude int     `yaml:"min_altitude_ft,omitempty" toml:"min_altitude_ft"`
+	MaxAltitude int     `yaml:"max_altitude_ft,omitempty" toml:"max_altitude_ft"` // 0 sets no ceiling
+}
+
+// SinkConfig describes one time-series output. Only the settings of its type apply.
+type SinkConfig struct {
+	Type string `yaml:"type" toml:"type"` // "influxdb", "graphite" or "bst"
+
+	// InfluxDB
+	URL      string `yaml:"url,omitempty" toml:"url"`
+	Token    string `yaml:"token,omitempty" toml:"token" secret:"true"`
+	Database string `yaml:"database,omitempty" toml:"database"`
+
+	// Graphite / Carbon
+	Address  string `yaml:"address,omitempty" toml:"address"`   // host:port of the Carbon receiver
+	Protocol string `yaml:"protocol,omitempty" toml:"protocol"` // "plaintext" or "pickle"
+	Prefix   string `yaml:"prefix,omitempty" toml:"prefix"`     // metric path prefix, "{receiver}" is replaced by the receiver name
+
+	// BaseStation BST file
+	Path string `yaml:"path,omitempty" toml:"path"` // file the snapshots are appended to
+}
+
+// HTTPConfig controls the embedded HTTP server for /healthz, /readyz and /metrics.
+type HTTPConfig struct {
+	ListenAddr       string        `yaml:"listen_addr" toml:"listen_addr"`                 // empty disables the server
+	ReadyMaxWriteAge time.Duration `yaml:"ready_max_write_age" toml:"ready_max_write_age"` // how long a batch may wait for a successful write before /readyz reports not ready
+}
+
+// APIConfig controls the in-memory aircraft state served by the live REST API.
+type APIConfig struct {
+	Enabled        bool          `yaml:"enabled" toml:"enabled"`
+	StateTTL       time.Duration `yaml:"state_ttl" toml:"state_ttl"`               // aircraft not heard from for this long are forgotten
+	MaxTrackPoints int           `yaml:"max_track_points" toml:"max_track_points"` // positions kept per aircraft for /track
+}
+
+// StreamConfig controls the live SSE / WebSocket stream of parsed messages.
+type StreamConfig struct {
+	Enabled      bool `yaml:"enabled" toml:"enabled"`
+	ClientBuffer int  `yaml:"client_buffer" toml:"client_buffer"` // events queued per client
+	MaxDropped   int  `yaml:"max_dropped" toml:"max_dropped"`     // consecutive dropped events before a slow client is disconnected
+}
+
+// RebroadcastConfig controls the SBS-1 rebroadcast server, re-serving received lines to downstream clients.
+type RebroadcastConfig struct {
+	ListenAddr   string        `yaml:"listen_addr" toml:"listen_addr"`     // empty disables the server
+	QueueSize    int           `yaml:"queue_size" toml:"queue_size"`       // lines queued per client before it is disconnected
+	MessageTypes []string      `yaml:"message_types" toml:"message_types"` // only forward these SBS-1 message types; empty forwards all
+	DedupWindow  time.Duration `yaml:"dedup_window" toml:"dedup_window"`   // suppress identical messages from several receivers within this window; 0 disables
+}
+
+// TelemetryConfig controls OpenTelemetry export of the collector's own health metrics and traces.
+type TelemetryConfig struct {
+	OTLPEndpoint   string        `yaml:"otlp_endpoint" toml:"otlp_endpoint"` // base OTLP/HTTP URL, empty disables export
+	ExportInterval time.Duration `yaml:"export_interval" toml:"export_interval"`
+	Traces         bool          `yaml:"traces" toml:"traces"` // export a trace per batch (read→parse→batch→write)
+}
+
+// ReplayConfig controls replaying capture files (see SourceConfig.Files).
+type ReplayConfig struct {
+	Speed float64 `yaml:"speed" toml:"speed"` // pace replayed lines by their timestamps, this many times faster than real time; 0 replays as fast as possible
+}
+
+// CaptureConfig controls capturing the raw SBS-1 lines of every live source into rotating files.
+type CaptureConfig struct {
+	Dir            string        `yaml:"dir" toml:"dir"`                         // empty disables capture
+	RotateInterval time.Duration `yaml:"rotate_interval" toml:"rotate_interval"` // start a new file at every multiple of this interval; 0 disables
+	MaxSizeMB      int           `yaml:"max_size_mb" toml:"max_size_mb"`         // start a new file once this many MB have been written; 0 disables
+	Compress       bool          `yaml:"compress" toml:"compress"`               // gzip capture files
+}
+
+// ReloadConfig controls reloading the configuration file while running.
+type ReloadConfig struct {
+	WatchInterval time.Duration `yaml:"watch_interval" toml:"watch_interval"` // how often the file is checked for changes; 0 only reloads on SIGHUP
 }
 
 const (
-	defaultBatchSize     = 50
-	defaultBatchInterval = 5 * time.Second
-	defaultRetryDelay    = 5 * time.Second
-	defaultMaxRetries    = 0 // 0 means infinite retries
+	defaultDump1090Host = "localhost"
+	defaultDump1090Port = 30003
+
+	defaultBatchSize       = 50
+	defaultBatchInterval   = 5 * time.Second
+	defaultRetryDelay      = 5 * time.Second
+	defaultMaxRetries      = 0 // 0 means infinite retries
+	defaultShutdownTimeout = 30 * time.Second
+	defaultDedupWindow     = time.Second
+	defaultFilterOnMissing = "keep"
+	defaultStageFileWatch  = time.Minute
+	defaultCoverageWindow  = 24 * time.Hour
+	defaultCoverageSector  = 1
+	defaultCoverageEvery   = time.Minute
+
+	defaultReceiverTimezone   = "UTC"
+	defaultTimestampSource    = "generated"
+	defaultClockSkewThreshold = 5 * time.Second
+
+	defaultOutputDBType     = "influxdb"
+	defaultGraphiteProtocol = "plaintext"
+	defaultGraphitePrefix   = "adsb.{receiver}"
+
+	defaultOTLPExportInterval = 15 * time.Second
+
+	defaultHTTPListenAddr   = ":8080"
+	defaultReadyMaxWriteAge = 2 * time.Minute
+
+	defaultStateTTL            = 5 * time.Minute
+	defaultStateMaxTrackPoints = 500
+
+	defaultStreamClientBuffer = 256
+	defaultStreamMaxDropped   = 1000
+
+	defaultRebroadcastQueueSize = 1000
+
+	defaultCaptureRotateInterval = time.Hour
+
+	defaultReloadWatchInterval = 5 * time.Second
 )
 
-// LoadConfig loads configuration from environment variables and provides defaults.
-// In a more complex application, this might also read from a config file (e.g., YAML, JSON).
-func LoadConfig() (*Config, error) {
-	cfg := &Config{
-		Dump1090Host:   getEnv("DUMP1090_HOST", "localhost"),
-		Dump1090Port:   getEnv("DUMP1090_PORT", "30003"),
-		InfluxHost:     os.Getenv("INFLUX_URL"),              // No default, mandatory for InfluxDB type
-		InfluxToken:    os.Getenv("INFLUXDB_TOKEN"),          // No default
-		InfluxDatabase: os.Getenv("INFLUXDB_DATABASE"),       // No default
-		OutputDBType:   getEnv("OUTPUT_DB_TYPE", "influxdb"), // Default to influxdb
-
-		BatchSize:         getEnvAsInt("BATCH_SIZE", defaultBatchSize),
-		BatchInterval:     getEnvAsDuration("BATCH_INTERVAL", defaultBatchInterval),
-		ConnectRetryDelay: getEnvAsDuration("CONNECT_RETRY_DELAY", defaultRetryDelay),
-		ConnectMaxRetries: getEnvAsInt("CONNECT_MAX_RETRIES", defaultMaxRetries),
+// Default returns the configuration used when nothing is configured.
+func Default() *Config {
+	return &Config{
+		Receiver: ReceiverConfig{Timezone: defaultReceiverTimezone},
+		Connect:  ConnectConfig{RetryDelay: defaultRetryDelay, MaxRetries: defaultMaxRetries},
+		Pipeline: PipelineConfig{
+			Parser:          ParserConfig{TimestampSource: defaultTimestampSource, ClockSkewThreshold: defaultClockSkewThreshold},
+			Batch:           BatchConfig{Size: defaultBatchSize, Interval: defaultBatchInterval},
+			ShutdownTimeout: defaultShutdownTimeout,
+		},
+		Sinks:       []SinkConfig{{Type: defaultOutputDBType}},
+		HTTP:        HTTPConfig{ListenAddr: defaultHTTPListenAddr, ReadyMaxWriteAge: defaultReadyMaxWriteAge},
+		API:         APIConfig{Enabled: true, StateTTL: defaultStateTTL, MaxTrackPoints: defaultStateMaxTrackPoints},
+		Stream:      StreamConfig{Enabled: true, ClientBuffer: defaultStreamClientBuffer, MaxDropped: defaultStreamMaxDropped},
+		Rebroadcast: RebroadcastConfig{QueueSize: defaultRebroadcastQueueSize},
+		Telemetry:   TelemetryConfig{ExportInterval: defaultOTLPExportInterval},
+		Capture:     CaptureConfig{RotateInterval: defaultCaptureRotateInterval, Compress: true},
+		Reload:      ReloadConfig{WatchInterval: defaultReloadWatchInterval},
 	}
+}
 
-	// You could add validation logic here
-	if cfg.OutputDBType == "influxdb" {
-		if cfg.InfluxHost == "" || cfg.InfluxToken == "" || cfg.InfluxDatabase == "" {
-			return nil, fmt.Errorf("INFLUX_URL, INFLUXDB_TOKEN, and INFLUXDB_DATABASE must be set for InfluxDB output type")
+// LoadConfig loads the configuration file named by CONFIG_FILE, if any, and
+// the environment variables.
+func LoadConfig() (*Config, error) {
+	return Load("")
+}
+
+// Load reads the configuration file at path (CONFIG_FILE when empty; no file
+// when both are empty), applies the environment variables on top, and
+// validates the result. All problems are reported together.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	cfg := Default()
+	cfg.File = path
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, fmt.Errorf("invalid configuration file %s:\n%w", path, err)
 		}
-	} else {
-		// Add validation for other DB types here if they have mandatory fields
-		return nil, fmt.Errorf("unsupported OUTPUT_DB_TYPE: %s", cfg.OutputDBType)
 	}
-
+	if err := cfg.applyEnv(); err != nil {
+		return nil, fmt.Errorf("invalid environment:\n%w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
 	return cfg, nil
 }
 
-// Helper function to get environment variable or use a default.
-func getEnv(key string, defaultVal string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	return defaultVal
+// Validate fills in the settings that depend on other settings and checks the
+// whole configuration, reporting every problem at once. Load calls it; call it
+// again after changing a Config in code.
+func (c *Config) Validate() error {
+	c.applyDefaults()
+	return c.validate()
 }
 
-// Helper function to get environment variable as an integer.
-func getEnvAsInt(key string, defaultVal int) int {
-	strVal := getEnv(key, "")
-	if strVal == "" {
-		return defaultVal
+// applyDefaults fills in the settings that depend on other settings or that
+// cannot be defaulted before a file replaces a list.
+func (c *Config) applyDefaults() {
+	if len(c.Sources) == 0 {
+		c.Sources = []SourceConfig{{Host: defaultDump1090Host, Port: defaultDump1090Port}}
 	}
-	if intVal, err := strconv.Atoi(strVal); err == nil {
-		return intVal
+	if c.Receiver.Name == "" {
+		c.Receiver.Name = c.Sources[0].Host
+		if c.Receiver.Name == "" {
+			c.Receiver.Name = defaultDump1090Host
+		}
 	}
-	return defaultVal // Fallback if parsing fails
-}
-
-// Helper function to get environment variable as a time.Duration.
-func getEnvAsDuration(key string, defaultVal time.Duration) time.Duration {
-	strVal := getEnv(key, "")
-	if strVal == "" {
-		return defaultVal
+	for i := range c.Sources {
+		src := &c.Sources[i]
+		if src.Name == "" {
+			src.Name = c.Receiver.Name
+			if len(c.Sources) > 1 && src.Host != "" {
+				src.Name = src.Host
+			}
+		}
+		if src.Port == 0 && len(src.Files) == 0 {
+			src.Port = defaultDump1090Port
+		}
+		if src.Timezone == "" {
+			src.Timezone = c.Receiver.Timezone
+		}
+	}
+	for i := range c.Pipeline.Stages {
+		stage := &c.Pipeline.Stages[i]
+		if stage.Name == "" {
+			stage.Name = stage.Type
+		}
+		if stage.Type == "filter" && stage.OnMissing == "" {
+			stage.OnMissing = defaultFilterOnMissing
+		}
+		if stage.Type == "dedup" && stage.Window == 0 {
+			stage.Window = defaultDedupWindow
+		}
+		if stage.Type == "coverage" {
+			if stage.Window == 0 {
+				stage.Window = defaultCoverageWindow
+			}
+			if stage.SectorDeg == 0 {
+				stage.SectorDeg = defaultCoverageSector
+			}
+			if stage.AltitudeBands == nil {
+				stage.AltitudeBands = []int{10000, 20000, 30000}
+			}
+			if stage.Interval == 0 {
+				stage.Interval = defaultCoverageEvery
+			}
+		}
+		if (stage.Type == "registry" || stage.Type == "airline") && stage.WatchInterval == 0 {
+			stage.WatchInterval = defaultStageFileWatch
+		}
 	}
-	if durVal, err := time.ParseDuration(strVal); err == nil {
-		return durVal
+	for i := range c.Sinks {
+		sink := &c.Sinks[i]
+		if sink.Type == "graphite" {
+			if sink.Protocol == "" {
+				sink.Protocol = defaultGraphiteProtocol
+			}
+			if sink.Prefix == "" {
+				sink.Prefix = defaultGraphitePrefix
+			}
+		}
 	}
-	return defaultVal // Fallback if parsing fails
 }
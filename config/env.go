@@ -0,0 +1,276 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv overrides the configuration with every environment variable that
+// is set. Values that do not parse are reported rather than ignored.
+func (c *Config) applyEnv() error {
+	e := &envReader{}
+
+	e.string("RECEIVER_NAME", &c.Receiver.Name)
+	e.string("RECEIVER_TIMEZONE", &c.Receiver.Timezone)
+	e.float("RECEIVER_LATITUDE", &c.Receiver.Latitude)
+	e.float("RECEIVER_LONGITUDE", &c.Receiver.Longitude)
+	e.int("RECEIVER_ALTITUDE_FT", &c.Receiver.Altitude)
+	c.applySourceEnv(e)
+
+	e.duration("CONNECT_RETRY_DELAY", &c.Connect.RetryDelay)
+	e.int("CONNECT_MAX_RETRIES", &c.Connect.MaxRetries)
+
+	e.bool("PARSER_STRICT", &c.Pipeline.Parser.Strict)
+	e.string("TIMESTAMP_SOURCE", &c.Pipeline.Parser.TimestampSource)
+	e.duration("CLOCK_SKEW_THRESHOLD", &c.Pipeline.Parser.ClockSkewThreshold)
+	e.int("BATCH_SIZE", &c.Pipeline.Batch.Size)
+	e.duration("BATCH_INTERVAL", &c.Pipeline.Batch.Interval)
+	e.duration("SHUTDOWN_TIMEOUT", &c.Pipeline.ShutdownTimeout)
+
+	c.applySinkEnv(e)
+
+	e.string("HTTP_LISTEN_ADDR", &c.HTTP.ListenAddr)
+	e.duration("READY_MAX_WRITE_AGE", &c.HTTP.ReadyMaxWriteAge)
+
+	e.bool("API_ENABLED", &c.API.Enabled)
+	e.duration("STATE_TTL", &c.API.StateTTL)
+	e.int("STATE_MAX_TRACK_POINTS", &c.API.MaxTrackPoints)
+
+	e.bool("STREAM_ENABLED", &c.Stream.Enabled)
+	e.int("STREAM_CLIENT_BUFFER", &c.Stream.ClientBuffer)
+	e.int("STREAM_MAX_DROPPED", &c.Stream.MaxDropped)
+
+	e.string("REBROADCAST_LISTEN_ADDR", &c.Rebroadcast.ListenAddr)
+	e.int("REBROADCAST_QUEUE_SIZE", &c.Rebroadcast.QueueSize)
+	e.list("REBROADCAST_MESSAGE_TYPES", &c.Rebroadcast.MessageTypes)
+	e.duration("REBROADCAST_DEDUP_WINDOW", &c.Rebroadcast.DedupWindow)
+
+	e.string("OTEL_EXPORTER_OTLP_ENDPOINT", &c.Telemetry.OTLPEndpoint)
+	e.duration("OTLP_EXPORT_INTERVAL", &c.Telemetry.ExportInterval)
+	e.bool("OTLP_TRACES_ENABLED", &c.Telemetry.Traces)
+
+	e.float("REPLAY_SPEED", &c.Replay.Speed)
+
+	e.string("CAPTURE_DIR", &c.Capture.Dir)
+	e.duration("CAPTURE_ROTATE_INTERVAL", &c.Capture.RotateInterval)
+	e.int("CAPTURE_MAX_SIZE_MB", &c.Capture.MaxSizeMB)
+	e.bool("CAPTURE_COMPRESS", &c.Capture.Compress)
+
+	e.duration("CONFIG_WATCH_INTERVAL", &c.Reload.WatchInterval)
+
+	return errors.Join(e.errs...)
+}
+
+// applySourceEnv replaces the sources with those given by REPLAY_FILES,
+// DUMP1090_SOURCES or DUMP1090_HOST/DUMP1090_PORT, in that order of precedence.
+func (c *Config) applySourceEnv(e *envReader) {
+	var files, sources []string
+	e.list("REPLAY_FILES", &files)
+	e.list("DUMP1090_SOURCES", &sources)
+	host, hostSet := os.LookupEnv("DUMP1090_HOST")
+	_, portSet := os.LookupEnv("DUMP1090_PORT")
+	port := defaultDump1090Port
+	e.int("DUMP1090_PORT", &port)
+
+	switch {
+	case len(files) > 0:
+		c.Sources = replaySources(files, c.Receiver.Name)
+	case len(sources) > 0:
+		c.Sources = nil
+		for _, entry := range sources {
+			src, err := parseSource(entry)
+			if err != nil {
+				e.errs = append(e.errs, err)
+				continue
+			}
+			c.Sources = append(c.Sources, src)
+		}
+	case hostSet || portSet:
+		if host == "" {
+			host = defaultDump1090Host
+		}
+		c.Sources = []SourceConfig{{Host: host, Port: port}}
+	}
+}
+
+// applySinkEnv applies OUTPUT_DB_TYPE, which selects the sinks, and the
+// settings of each sink type, which apply to the first sink of that type.
+func (c *Config) applySinkEnv(e *envReader) {
+	var types []string
+	e.list("OUTPUT_DB_TYPE", &types)
+	if len(types) > 0 {
+		sinks := make([]SinkConfig, 0, len(types))
+		for _, typ := range types {
+			sink := SinkConfig{Type: typ}
+			if existing := c.sink(typ); existing != nil {
+				sink = *existing
+			}
+			sinks = append(sinks, sink)
+		}
+		c.Sinks = sinks
+	}
+
+	if sink := c.sink("influxdb"); sink != nil {
+		e.string("INFLUX_URL", &sink.URL)
+		e.string("INFLUXDB_TOKEN", &sink.Token)
+		e.string("INFLUXDB_DATABASE", &sink.Database)
+	}
+	if sink := c.sink("graphite"); sink != nil {
+		e.string("GRAPHITE_ADDRESS", &sink.Address)
+		e.string("GRAPHITE_PROTOCOL", &sink.Protocol)
+		e.string("GRAPHITE_PREFIX", &sink.Prefix)
+	}
+	if sink := c.sink("bst"); sink != nil {
+		e.string("BST_OUTPUT_PATH", &sink.Path)
+	}
+}
+
+// sink returns the first sink of the given type, or nil.
+func (c *Config) sink(typ string) *SinkConfig {
+	for i := range c.Sinks {
+		if c.Sinks[i].Type == typ {
+			return &c.Sinks[i]
+		}
+	}
+	return nil
+}
+
+// parseSource parses a DUMP1090_SOURCES entry of the form "name=host:port" or "host:port".
+// Without a name, the host is used as the source name.
+func parseSource(entry string) (SourceConfig, error) {
+	name, address, named := strings.Cut(entry, "=")
+	if !named {
+		address = entry
+	}
+	host, port, err := net.SplitHostPort(strings.TrimSpace(address))
+	if err != nil {
+		return SourceConfig{}, fmt.Errorf("invalid DUMP1090_SOURCES entry %q: %w", entry, err)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return SourceConfig{}, fmt.Errorf("invalid DUMP1090_SOURCES entry %q: port %q is not a number", entry, port)
+	}
+	name = strings.TrimSpace(name)
+	if !named {
+		name = host
+	}
+	if name == "" {
+		return SourceConfig{}, fmt.Errorf("invalid DUMP1090_SOURCES entry %q: empty source name", entry)
+	}
+	return SourceConfig{Name: name, Host: host, Port: portNumber}, nil
+}
+
+// replaySources groups REPLAY_FILES entries of the form "name=path" or "path"
+// into one source per receiver name, keeping the files in the order given.
+// Without a name, a file is replayed as defaultName (the receiver name, which
+// is filled in later when empty).
+func replaySources(entries []string, defaultName string) []SourceConfig {
+	var sources []SourceConfig
+	index := make(map[string]int)
+	for _, entry := range entries {
+		name, path, named := strings.Cut(entry, "=")
+		if !named {
+			name, path = defaultName, entry
+		}
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		if name == "" {
+			name = defaultName
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(sources)
+			index[name] = i
+			sources = append(sources, SourceConfig{Name: name})
+		}
+		sources[i].Files = append(sources[i].Files, path)
+	}
+	return sources
+}
+
+// envReader reads typed environment variables, leaving the target untouched
+// when a variable is unset and collecting an error when it does not parse.
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) fail(key, value string, err error) {
+	e.errs = append(e.errs, fmt.Errorf("%s=%q: %w", key, value, err))
+}
+
+func (e *envReader) string(key string, target *string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*target = value
+	}
+}
+
+func (e *envReader) int(key string, target *int) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		e.fail(key, value, errors.New("not an integer"))
+		return
+	}
+	*target = n
+}
+
+func (e *envReader) float(key string, target *float64) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		e.fail(key, value, errors.New("not a number"))
+		return
+	}
+	*target = f
+}
+
+func (e *envReader) bool(key string, target *bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		e.fail(key, value, errors.New("not a boolean (expected true or false)"))
+		return
+	}
+	*target = b
+}
+
+func (e *envReader) duration(key string, target *time.Duration) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		e.fail(key, value, errors.New("not a duration (e.g. 5s or 1m30s)"))
+		return
+	}
+	*target = d
+}
+
+// list reads a comma-separated list, ignoring empty items.
+func (e *envReader) list(key string, target *[]string) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*target = list
+}
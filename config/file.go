@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile decodes a YAML (.yaml, .yml) or TOML (.toml) file over the
+// current settings. Keys the collector does not know are errors, so that a
+// typo does not silently leave a default in place. ${VAR} references in
+// string values are replaced with environment variables.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			var typeErr *yaml.TypeError
+			if errors.As(err, &typeErr) {
+				errs := make([]error, len(typeErr.Errors))
+				for i, msg := range typeErr.Errors {
+					errs[i] = errors.New(msg)
+				}
+				return errors.Join(errs...)
+			}
+			return err
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), c)
+		if err != nil {
+			return err
+		}
+		var errs []error
+		for _, key := range md.Undecoded() {
+			errs = append(errs, fmt.Errorf("unknown key %s", key))
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+	default:
+		return fmt.Errorf("unsupported configuration file type %q (expected .yaml, .yml or .toml)", ext)
+	}
+
+	return interpolate(reflect.ValueOf(c).Elem(), "")
+}
+
+// varPattern matches ${VAR} and ${VAR:-default}.
+var varPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolate replaces ${VAR} references in every string of v, so that
+// secrets can be kept out of the file. ${VAR:-default} falls back to default
+// when VAR is unset or empty; an unset VAR without a default is an error.
+func interpolate(v reflect.Value, path string) error {
+	var errs []error
+	walk(v, path, func(field reflect.Value, path string, _ reflect.StructField) {
+		if field.Kind() != reflect.String {
+			return
+		}
+		value := varPattern.ReplaceAllStringFunc(field.String(), func(ref string) string {
+			m := varPattern.FindStringSubmatch(ref)
+			if value := os.Getenv(m[1]); value != "" {
+				return value
+			}
+			if strings.Contains(ref, ":-") {
+				return m[2]
+			}
+			if _, set := os.LookupEnv(m[1]); !set {
+				errs = append(errs, fmt.Errorf("%s: environment variable %s is not set", path, m[1]))
+			}
+			return ""
+		})
+		field.SetString(value)
+	})
+	return errors.Join(errs...)
+}
+
+// walk calls fn for every settable leaf value of v (strings, numbers, bools,
+// durations and list items), with its dotted path in the file and the struct
+// field it belongs to. Fields tagged yaml:"-" are skipped.
+func walk(v reflect.Value, path string, fn func(value reflect.Value, path string, field reflect.StructField)) {
+	var visit func(v reflect.Value, path string, field reflect.StructField)
+	visit = func(v reflect.Value, path string, field reflect.StructField) {
+		switch v.Kind() {
+		case reflect.Struct:
+			t := v.Type()
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				name := yamlName(f)
+				if name == "-" || !f.IsExported() {
+					continue
+				}
+				child := name
+				if path != "" {
+					child = path + "." + name
+				}
+				visit(v.Field(i), child, f)
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				visit(v.Index(i), fmt.Sprintf("%s[%d]", path, i), field)
+			}
+		default:
+			fn(v, path, field)
+		}
+	}
+	visit(v, path, reflect.StructField{})
+}
+
+// yamlName returns the key of a struct field in a configuration file.
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
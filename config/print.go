@@ -0,0 +1,114 @@
+package config
+
+import (
+	"io"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets in the printed configuration.
+const redacted = "<redacted>"
+
+// Print writes the effective configuration to w as YAML, in the format of a
+// configuration file. Fields tagged secret:"true" and passwords embedded in
+// URLs are redacted.
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(node(reflect.ValueOf(c).Elem(), reflect.StructField{})); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// node converts v into a YAML node, keeping the field order of the structs.
+func node(v reflect.Value, field reflect.StructField) *yaml.Node {
+	switch v.Kind() {
+	case reflect.Struct:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := yamlName(f)
+			if name == "-" || !f.IsExported() || (omitEmpty(f) && v.Field(i).IsZero()) {
+				continue
+			}
+			n.Content = append(n.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: name},
+				node(v.Field(i), f))
+		}
+		return n
+	case reflect.Map:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+		for _, key := range keys {
+			n.Content = append(n.Content, scalar(key, reflect.StructField{}), node(v.MapIndex(key), field))
+		}
+		return n
+	case reflect.Slice:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			n.Content = append(n.Content, node(v.Index(i), field))
+		}
+		return n
+	}
+	return scalar(v, field)
+}
+
+// scalar converts a leaf value into a YAML scalar node.
+func scalar(v reflect.Value, field reflect.StructField) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.ScalarNode}
+	switch value := v.Interface().(type) {
+	case time.Duration:
+		n.Value = value.String()
+	case string:
+		n.Value = value
+		switch {
+		case value != "" && field.Tag.Get("secret") == "true":
+			n.Value = redacted
+		case value != "":
+			n.Value = redactURL(value)
+		}
+		n.Tag = "!!str" // quoted when it would otherwise read as another type
+	case bool:
+		n.Value = strconv.FormatBool(value)
+	case int:
+		n.Value = strconv.Itoa(value)
+	case float64:
+		n.Value = strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	return n
+}
+
+// redactURL hides the password of a URL with user information, and returns
+// anything else unchanged. The password is replaced as written, so that a
+// percent-encoded one does not slip through.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if password, ok := u.User.Password(); !ok || password == "" {
+		return s
+	}
+	start := strings.Index(s, "//") + len("//")
+	authority := s[start:]
+	if end := strings.IndexAny(authority, "/?#"); end >= 0 {
+		authority = authority[:end]
+	}
+	at := strings.LastIndex(authority, "@")
+	user, _, _ := strings.Cut(authority[:at], ":")
+	return s[:start] + user + ":" + redacted + s[start+at:]
+}
+
+func omitEmpty(f reflect.StructField) bool {
+	_, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return opts == "omitempty"
+}
@@ -0,0 +1,66 @@
+package config
+
+import "sync"
+
+// builtinStages lists the settings of every built-in stage type, by key.
+// Stages of registered types take theirs under options.
+var builtinStages = map[string][]string{
+	"filter":     {"expression", "on_missing"},
+	"geofence":   {"geojson", "zones", "drop_outside"},
+	"dedup":      {"window"},
+	"icao":       {},
+	"registry":   {"path", "watch_interval"},
+	"airline":    {"airlines", "routes", "watch_interval"},
+	"kinematics": {},
+	"coverage":   {"sector_deg", "altitude_bands_ft", "window", "interval", "output"},
+}
+
+var (
+	stageTypesMu sync.RWMutex
+	stageTypes   = make(map[string]bool)
+)
+
+// RegisterStageType makes Validate accept stages of type typ, with their
+// settings under options. pipeline.Register calls it.
+func RegisterStageType(typ string) {
+	stageTypesMu.Lock()
+	defer stageTypesMu.Unlock()
+	stageTypes[typ] = true
+}
+
+// stageSettings returns the keys of the settings a stage of type typ takes,
+// and whether the type is known.
+func stageSettings(typ string) ([]string, bool) {
+	if keys, ok := builtinStages[typ]; ok {
+		return keys, true
+	}
+	stageTypesMu.RLock()
+	defer stageTypesMu.RUnlock()
+	return []string{"options"}, stageTypes[typ]
+}
+
+// settings returns the keys of the type-specific settings of s that are set.
+func (s StageConfig) settings() []string {
+	var keys []string
+	add := func(set bool, key string) {
+		if set {
+			keys = append(keys, key)
+		}
+	}
+	add(len(s.Options) > 0, "options")
+	add(s.Expression != "", "expression")
+	add(s.OnMissing != "", "on_missing")
+	add(s.GeoJSON != "", "geojson")
+	add(len(s.Zones) > 0, "zones")
+	add(s.DropOutside, "drop_outside")
+	add(s.Window != 0, "window")
+	add(s.Path != "", "path")
+	add(s.Airlines != "", "airlines")
+	add(s.Routes != "", "routes")
+	add(s.WatchInterval != 0, "watch_interval")
+	add(s.SectorDeg != 0, "sector_deg")
+	add(len(s.AltitudeBands) > 0, "altitude_bands_ft")
+	add(s.Interval != 0, "interval")
+	add(s.Output != "", "output")
+	return keys
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// validate checks the whole configuration, reporting every problem at once,
+// and resolves the time zone of each source.
+func (c *Config) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if _, err := time.LoadLocation(c.Receiver.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("receiver.timezone: unknown time zone %q", c.Receiver.Timezone))
+	}
+
+	check(c.Receiver.Latitude >= -90 && c.Receiver.Latitude <= 90, "receiver.latitude: must be between -90 and 90")
+	check(c.Receiver.Longitude >= -180 && c.Receiver.Longitude <= 180, "receiver.longitude: must be between -180 and 180")
+
+	seen := make(map[string]bool, len(c.Sources))
+	for i := range c.Sources {
+		src := &c.Sources[i]
+		path := fmt.Sprintf("sources[%d]", i)
+		check(src.Name != "", "%s.name: must not be empty", path)
+		check(!seen[src.Name], "%s.name: duplicate source name %q", path, src.Name)
+		seen[src.Name] = true
+		if len(src.Files) == 0 {
+			check(src.Host != "", "%s.host: must not be empty", path)
+			check(src.Port > 0 && src.Port < 65536, "%s.port: invalid port %d", path, src.Port)
+		}
+		location, err := time.LoadLocation(src.Timezone)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s.timezone: unknown time zone %q", path, src.Timezone))
+		}
+		src.Location = location
+		check(src.Latitude >= -90 && src.Latitude <= 90, "%s.latitude: must be between -90 and 90", path)
+		check(src.Longitude >= -180 && src.Longitude <= 180, "%s.longitude: must be between -180 and 180", path)
+	}
+
+	check(c.Connect.RetryDelay > 0, "connect.retry_delay: must be positive")
+	check(c.Connect.MaxRetries >= 0, "connect.max_retries: must not be negative")
+
+	parser := c.Pipeline.Parser
+	switch parser.TimestampSource {
+	case "generated", "logged", "received":
+	default:
+		errs = append(errs, fmt.Errorf("pipeline.parser.timestamp_source: unsupported value %q (expected generated, logged or received)", parser.TimestampSource))
+	}
+	check(parser.ClockSkewThreshold >= 0, "pipeline.parser.clock_skew_threshold: must not be negative")
+	check(c.Pipeline.Batch.Size > 0, "pipeline.batch.size: must be positive")
+	check(c.Pipeline.Batch.Interval > 0, "pipeline.batch.interval: must be positive")
+	stageNames := make(map[string]bool, len(c.Pipeline.Stages))
+	for i, stage := range c.Pipeline.Stages {
+		path := fmt.Sprintf("pipeline.stages[%d]", i)
+		check(stage.Type != "", "%s.type: must not be empty", path)
+		if keys, ok := stageSettings(stage.Type); !ok {
+			check(stage.Type == "", "%s.type: unknown stage type %q", path, stage.Type)
+		} else {
+			for _, key := range stage.settings() {
+				check(slices.Contains(keys, key), "%s.%s: does not apply to a %s stage", path, key, stage.Type)
+			}
+		}
+		check(!stageNames[stage.Name], "%s.name: duplicate stage name %q", path, stage.Name)
+		stageNames[stage.Name] = true
+		switch stage.Type {
+		case "filter":
+			check(stage.Expression != "", "%s.expression: must be set for a filter stage", path)
+			check(stage.OnMissing == "keep" || stage.OnMissing == "drop",
+				"%s.on_missing: unsupported value %q (expected keep or drop)", path, stage.OnMissing)
+		case "geofence":
+			check(stage.GeoJSON != "" || len(stage.Zones) > 0, "%s: a geojson file or zones must be set for a geofence stage", path)
+			check(len(stage.Zones) == 0 || c.Receiver.HasPosition(),
+				"%s.zones: receiver.latitude and receiver.longitude must be set for zones centred on the station", path)
+			for j, zone := range stage.Zones {
+				zonePath := fmt.Sprintf("%s.zones[%d]", path, j)
+				check(zone.Name != "", "%s.name: must not be empty", zonePath)
+				check(zone.RadiusKm > 0, "%s.radius_km: must be positive", zonePath)
+				check(zone.MaxAltitude == 0 || zone.MaxAltitude > zone.MinAltitude,
+					"%s.max_altitude_ft: must be above min_altitude_ft", zonePath)
+			}
+		case "dedup":
+			check(stage.Window > 0, "%s.window: must be positive", path)
+		case "registry":
+			check(stage.Path != "", "%s.path: must be set for a registry stage", path)
+			check(stage.WatchInterval > 0, "%s.watch_interval: must be positive", path)
+		case "kinematics", "coverage":
+			for j, src := range c.Sources {
+				check(src.HasPosition() || c.Receiver.HasPosition(),
+					"%s: sources[%d] (%s) has no position for a %s stage: set its latitude and longitude, or receiver.latitude and receiver.longitude", path, j, src.Name, stage.Type)
+			}
+		}
+		switch stage.Type {
+		case "coverage":
+			check(stage.SectorDeg > 0 && 360%stage.SectorDeg == 0, "%s.sector_deg: must be a divisor of 360", path)
+			for j, band := range stage.AltitudeBands {
+				check(band > 0 && (j == 0 || band > stage.AltitudeBands[j-1]),
+					"%s.altitude_bands_ft: boundaries must be positive and ascending", path)
+			}
+			check(stage.Window > 0, "%s.window: must be positive", path)
+			check(stage.Interval > 0, "%s.interval: must be positive", path)
+		case "airline":
+			check(stage.Airlines != "", "%s.airlines: must be set for an airline stage", path)
+			check(stage.WatchInterval > 0, "%s.watch_interval: must be positive", path)
+		}
+	}
+	check(c.Pipeline.ShutdownTimeout > 0, "pipeline.shutdown_timeout: must be positive")
+
+	for i, sink := range c.Sinks {
+		path := fmt.Sprintf("sinks[%d]", i)
+		switch sink.Type {
+		case "influxdb":
+			check(sink.URL != "", "%s.url: must be set for InfluxDB output (INFLUX_URL)", path)
+			check(sink.Token != "", "%s.token: must be set for InfluxDB output (INFLUXDB_TOKEN)", path)
+			check(sink.Database != "", "%s.database: must be set for InfluxDB output (INFLUXDB_DATABASE)", path)
+		case "graphite":
+			check(sink.Address != "", "%s.address: must be set for Graphite output (GRAPHITE_ADDRESS)", path)
+			check(sink.Protocol == "plaintext" || sink.Protocol == "pickle",
+				"%s.protocol: unsupported value %q (expected plaintext or pickle)", path, sink.Protocol)
+		case "bst":
+			check(sink.Path != "", "%s.path: must be set for BST output (BST_OUTPUT_PATH)", path)
+		case "prometheus":
+			errs = append(errs, fmt.Errorf("%s.type: prometheus output is not yet implemented", path))
+		default:
+			errs = append(errs, fmt.Errorf("%s.type: unsupported output type %q (expected influxdb, graphite or bst)", path, sink.Type))
+		}
+	}
+
+	if c.HTTP.ListenAddr != "" {
+		check(c.HTTP.ReadyMaxWriteAge > 0, "http.ready_max_write_age: must be positive")
+	}
+	if c.API.Enabled {
+		check(c.API.StateTTL > 0, "api.state_ttl: must be positive")
+		check(c.API.MaxTrackPoints >= 0, "api.max_track_points: must not be negative")
+	}
+	if c.Stream.Enabled {
+		check(c.Stream.ClientBuffer > 0, "stream.client_buffer: must be positive")
+		check(c.Stream.MaxDropped > 0, "stream.max_dropped: must be positive")
+	}
+	if c.Rebroadcast.ListenAddr != "" {
+		check(c.Rebroadcast.QueueSize > 0, "rebroadcast.queue_size: must be positive")
+		check(c.Rebroadcast.DedupWindow >= 0, "rebroadcast.dedup_window: must not be negative")
+	}
+	if c.Telemetry.OTLPEndpoint != "" {
+		check(c.Telemetry.ExportInterval > 0, "telemetry.export_interval: must be positive")
+	}
+	check(c.Replay.Speed >= 0, "replay.speed: must not be negative")
+	if c.Capture.Dir != "" {
+		check(c.Capture.RotateInterval >= 0, "capture.rotate_interval: must not be negative")
+		check(c.Capture.MaxSizeMB >= 0, "capture.max_size_mb: must not be negative")
+	}
+	check(c.Reload.WatchInterval >= 0, "reload.watch_interval: must not be negative")
+
+	return errors.Join(errs...)
+}
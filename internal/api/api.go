@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/state"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/stream"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// AircraftResponse is the JSON representation of an aircraft's merged current state.
+type AircraftResponse struct {
+	Hex              string       `json:"hex"`
+	MessageType      string       `json:"message_type,omitempty"`      // set on streamed records only
+	TransmissionType string       `json:"transmission_type,omitempty"` // set on streamed records only
+	Callsign         string       `json:"callsign,omitempty"`
+	Squawk           string       `json:"squawk,omitempty"`
+	Altitude         *int         `json:"altitude_ft,omitempty"`
+	GroundSpeed      *float64     `json:"ground_speed_kts,omitempty"`
+	Track            *float64     `json:"track_deg,omitempty"`
+	Latitude         *float64     `json:"latitude,omitempty"`
+	Longitude        *float64     `json:"longitude,omitempty"`
+	VerticalRate     *int         `json:"vertical_rate_fpm,omitempty"`
+	Alert            *bool        `json:"alert,omitempty"`
+	Emergency        *bool        `json:"emergency,omitempty"`
+	SPI              *bool        `json:"spi,omitempty"`
+	IsOnGround       *bool        `json:"is_on_ground,omitempty"`
+	Status           string       `json:"status,omitempty"` // last STA status, e.g. PL or SL
+	LastMessage      time.Time    `json:"last_message"`
+	FirstSeen        time.Time    `json:"first_seen,omitzero"`
+	LastSeen         time.Time    `json:"last_seen,omitzero"`
+	Messages         int          `json:"messages,omitempty"`
+	TrackHistory     []TrackPoint `json:"track_history,omitempty"`
+}
+
+// TrackPoint is the JSON representation of a single track history point.
+type TrackPoint struct {
+	Time         time.Time `json:"time"`
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	Altitude     *int      `json:"altitude_ft,omitempty"`
+	GroundSpeed  *float64  `json:"ground_speed_kts,omitempty"`
+	Track        *float64  `json:"track_deg,omitempty"`
+	VerticalRate *int      `json:"vertical_rate_fpm,omitempty"`
+}
+
+type listResponse struct {
+	Now      time.Time          `json:"now"`
+	Count    int                `json:"count"`
+	Aircraft []AircraftResponse `json:"aircraft"`
+}
+
+type trackResponse struct {
+	Hex   string       `json:"hex"`
+	Since time.Time    `json:"since"`
+	Track []TrackPoint `json:"track"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler returns an http.Handler serving the live aircraft API from the given store:
+//
+//	GET /api/aircraft                      all aircraft currently tracked
+//	GET /api/aircraft/{hex}                one aircraft, with its track history
+//	GET /api/aircraft/{hex}/track?since=   track history since an RFC 3339 time or a duration ago (e.g. 10m)
+//	GET /api/stream                        Server-Sent Events stream of parsed messages
+//	GET /api/stream/ws                     WebSocket stream of parsed messages
+//
+// The stream endpoints are only registered when hub is non-nil.
+func NewHandler(store *state.Store, hub *stream.Hub) http.Handler {
+	h := &handler{store: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/aircraft", h.listAircraft)
+	mux.HandleFunc("GET /api/aircraft/{hex}", h.getAircraft)
+	mux.HandleFunc("GET /api/aircraft/{hex}/track", h.getTrack)
+	if hub != nil {
+		sh := &streamHandler{hub: hub}
+		mux.HandleFunc("GET /api/stream", sh.serveSSE)
+		mux.HandleFunc("GET /api/stream/ws", sh.serveWebSocket)
+	}
+	return mux
+}
+
+type handler struct {
+	store *state.Store
+}
+
+func (h *handler) listAircraft(w http.ResponseWriter, _ *http.Request) {
+	list := h.store.List()
+	resp := listResponse{
+		Now:      time.Now().UTC(),
+		Count:    len(list),
+		Aircraft: make([]AircraftResponse, 0, len(list)),
+	}
+	for _, ac := range list {
+		resp.Aircraft = append(resp.Aircraft, newAircraftResponse(ac, false))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) getAircraft(w http.ResponseWriter, r *http.Request) {
+	ac, ok := h.store.Get(r.PathValue("hex"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "aircraft not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, newAircraftResponse(ac, true))
+}
+
+func (h *handler) getTrack(w http.ResponseWriter, r *http.Request) {
+	since, err := parseSince(r.URL.Query().Get("since"), time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	hex := r.PathValue("hex")
+	track, ok := h.store.Track(hex, since)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "aircraft not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, trackResponse{
+		Hex:   strings.ToUpper(hex),
+		Since: since.UTC(),
+		Track: newTrackPoints(track),
+	})
+}
+
+// parseSince accepts an RFC 3339 timestamp or a Go duration meaning "that long ago".
+// An empty value means the whole retained history.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since parameter %q: expected an RFC 3339 time or a duration such as 10m", value)
+	}
+	return now.Add(-d), nil
+}
+
+func newAircraftResponse(ac state.Aircraft, withTrack bool) AircraftResponse {
+	resp := stateToResponse(&ac.State)
+	resp.FirstSeen = ac.FirstSeen.UTC()
+	resp.LastSeen = ac.LastSeen.UTC()
+	resp.Messages = ac.Messages
+	if withTrack {
+		resp.TrackHistory = newTrackPoints(ac.Track)
+	}
+	return resp
+}
+
+// stateToResponse maps the merged aircraft fields; the bookkeeping fields are left to the caller.
+func stateToResponse(data *models.AircraftData) AircraftResponse {
+	return AircraftResponse{
+		Hex:          data.HexIdent,
+		Callsign:     data.Callsign,
+		Squawk:       data.Squawk,
+		Altitude:     data.Altitude,
+		GroundSpeed:  data.GroundSpeed,
+		Track:        data.Track,
+		Latitude:     data.Latitude,
+		Longitude:    data.Longitude,
+		VerticalRate: data.VerticalRate,
+		Alert:        data.Alert,
+		Emergency:    data.Emergency,
+		SPI:          data.SPI,
+		IsOnGround:   data.IsOnGround,
+		Status:       data.Status,
+		LastMessage:  data.Timestamp.UTC(),
+	}
+}
+
+func newTrackPoints(track []state.TrackPoint) []TrackPoint {
+	points := make([]TrackPoint, 0, len(track))
+	for _, p := range track {
+		points = append(points, TrackPoint{
+			Time:         p.Time.UTC(),
+			Latitude:     p.Latitude,
+			Longitude:    p.Longitude,
+			Altitude:     p.Altitude,
+			GroundSpeed:  p.GroundSpeed,
+			Track:        p.Track,
+			VerticalRate: p.VerticalRate,
+		})
+	}
+	return points
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding API response: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/state"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// testStore returns a store with 4CA2D6 seen at five positions a minute apart,
+// the last one just now, and 3C6586 without a position.
+func testStore(now time.Time) *state.Store {
+	store := state.NewStore(time.Hour, 10)
+	for i := range 5 {
+		lat, lon := 53+float64(i)/10, -6.0
+		store.Update(&models.AircraftData{
+			MessageType:      models.MessageTypeTransmission,
+			TransmissionType: "3",
+			HexIdent:         "4CA2D6",
+			Timestamp:        now.Add(time.Duration(i-4) * time.Minute),
+			Latitude:         &lat,
+			Longitude:        &lon,
+		})
+	}
+	store.Update(&models.AircraftData{
+		MessageType:      models.MessageTypeTransmission,
+		TransmissionType: "1",
+		HexIdent:         "3c6586",
+		Timestamp:        now,
+		Callsign:         "DLH4AB",
+	})
+	store.Update(&models.AircraftData{MessageType: models.MessageTypeNewID, HexIdent: "4CA2D6", Timestamp: now, Callsign: "EIN123"})
+	return store
+}
+
+// get serves a GET request for target and decodes the JSON response into v.
+func get(t *testing.T, h http.Handler, target string, v any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("GET %s: Content-Type %q, want application/json", target, ct)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("GET %s: %v in %q", target, err, rec.Body.String())
+	}
+	return rec.Code
+}
+
+func TestListAircraft(t *testing.T) {
+	h := NewHandler(testStore(time.Now()), nil)
+	var resp listResponse
+	if code := get(t, h, "/api/aircraft", &resp); code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", code, http.StatusOK)
+	}
+	if resp.Count != 2 || len(resp.Aircraft) != 2 {
+		t.Fatalf("listed %d (%d) aircraft, want 2", resp.Count, len(resp.Aircraft))
+	}
+	first, second := resp.Aircraft[0], resp.Aircraft[1]
+	if first.Hex != "3C6586" || first.Callsign != "DLH4AB" || first.Messages != 1 {
+		t.Errorf("first aircraft = %+v, want 3C6586 DLH4AB seen once", first)
+	}
+	if second.Hex != "4CA2D6" || second.Callsign != "EIN123" || second.Messages != 6 || second.Latitude == nil || *second.Latitude != 53.4 {
+		t.Errorf("second aircraft = %+v, want 4CA2D6 EIN123 at 53.4 from 6 messages", second)
+	}
+	if second.TrackHistory != nil {
+		t.Errorf("list has %d track points, want none", len(second.TrackHistory))
+	}
+}
+
+func TestGetAircraft(t *testing.T) {
+	h := NewHandler(testStore(time.Now()), nil)
+	var resp AircraftResponse
+	if code := get(t, h, "/api/aircraft/4ca2d6", &resp); code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", code, http.StatusOK)
+	}
+	if resp.Hex != "4CA2D6" || len(resp.TrackHistory) != 5 {
+		t.Errorf("got %s with %d track points, want 4CA2D6 with 5", resp.Hex, len(resp.TrackHistory))
+	}
+
+	var missing errorResponse
+	if code := get(t, h, "/api/aircraft/400000", &missing); code != http.StatusNotFound || missing.Error == "" {
+		t.Errorf("unknown aircraft: status %d, error %q; want %d and an error", code, missing.Error, http.StatusNotFound)
+	}
+}
+
+func TestGetTrack(t *testing.T) {
+	now := time.Now()
+	h := NewHandler(testStore(now), nil)
+	tests := []struct {
+		name   string
+		target string
+		code   int
+		points int
+	}{
+		{"whole history", "/api/aircraft/4CA2D6/track", http.StatusOK, 5},
+		{"duration ago", "/api/aircraft/4CA2D6/track?since=150s", http.StatusOK, 3},
+		{"RFC 3339 time", "/api/aircraft/4ca2d6/track?since=" + now.Add(-time.Minute).UTC().Format(time.RFC3339Nano), http.StatusOK, 2},
+		{"future", "/api/aircraft/4CA2D6/track?since=" + now.Add(time.Hour).UTC().Format(time.RFC3339), http.StatusOK, 0},
+		{"no position", "/api/aircraft/3C6586/track", http.StatusOK, 0},
+		{"invalid since", "/api/aircraft/4CA2D6/track?since=yesterday", http.StatusBadRequest, 0},
+		{"unknown aircraft", "/api/aircraft/400000/track", http.StatusNotFound, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp struct {
+				trackResponse
+				Error string `json:"error"`
+			}
+			code := get(t, h, tt.target, &resp)
+			if code != tt.code {
+				t.Fatalf("status = %d (%q), want %d", code, resp.Error, tt.code)
+			}
+			if code != http.StatusOK {
+				if resp.Error == "" {
+					t.Error("no error message")
+				}
+				return
+			}
+			if len(resp.Track) != tt.points {
+				t.Errorf("track has %d points, want %d", len(resp.Track), tt.points)
+			}
+			for i := 1; i < len(resp.Track); i++ {
+				if resp.Track[i].Time.Before(resp.Track[i-1].Time) {
+					t.Errorf("point %d at %s is before point %d", i, resp.Track[i].Time, i-1)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false},
+		{"10m", now.Add(-10 * time.Minute), false},
+		{"1h30m", now.Add(-90 * time.Minute), false},
+		{"2024-01-01T11:00:00Z", now.Add(-time.Hour), false},
+		{"2024-01-01T12:00:00+01:00", now.Add(-time.Hour), false},
+		{"10", time.Time{}, true},
+		{"2024-01-01", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.value, now)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %s, %v; want %s, error %t", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
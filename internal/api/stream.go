@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/stream"
+)
+
+const (
+	streamKeepAlive  = 15 * time.Second
+	streamWriteLimit = 10 * time.Second
+)
+
+// StreamMessage is one event pushed to streaming clients.
+type StreamMessage struct {
+	Mode     string           `json:"mode"` // "record" or "state"
+	Aircraft AircraftResponse `json:"aircraft"`
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	// The stream is read-only public data, so dashboards on other origins may connect.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+type streamHandler struct {
+	hub *stream.Hub
+}
+
+// subscribe parses the filter from the query string and registers a subscriber.
+func (h *streamHandler) subscribe(w http.ResponseWriter, r *http.Request) (*stream.Subscriber, bool) {
+	sub, err := stream.SubscriptionFromQuery(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return nil, false
+	}
+	filter, err := sub.Filter()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return nil, false
+	}
+	return h.hub.Subscribe(filter), true
+}
+
+// serveSSE streams events as Server-Sent Events ("event: aircraft").
+func (h *streamHandler) serveSSE(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.subscribe(w, r)
+	if !ok {
+		return
+	}
+	defer h.hub.Unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("SSE client %s does not support flushing: %v", r.RemoteAddr, err)
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	enc := json.NewEncoder(w)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Done():
+			log.Printf("Disconnecting slow SSE client %s.", r.RemoteAddr)
+			return
+		case <-keepAlive.C:
+			_ = rc.SetWriteDeadline(time.Now().Add(streamWriteLimit))
+			if _, err := w.Write([]byte(": keep-alive\n\n")); err != nil {
+				return
+			}
+		case ev := <-sub.Events():
+			_ = rc.SetWriteDeadline(time.Now().Add(streamWriteLimit))
+			if _, err := w.Write([]byte("event: aircraft\ndata: ")); err != nil {
+				return
+			}
+			// Encode terminates the JSON with a newline, completing the data line.
+			if err := enc.Encode(newStreamMessage(sub.Filter().Mode, ev)); err != nil {
+				return
+			}
+			if _, err := w.Write([]byte("\n")); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// serveWebSocket streams events as JSON text frames. Clients may send a
+// subscription object at any time to replace their filter.
+func (h *streamHandler) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.subscribe(w, r)
+	if !ok {
+		return
+	}
+	defer h.hub.Unsubscribe(sub)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error.
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	// Reader: applies filter updates and notices when the client goes away.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(64 * 1024)
+		for {
+			_, payload, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg stream.Subscription
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				log.Printf("Ignoring invalid subscription from WebSocket client %s: %v", r.RemoteAddr, err)
+				continue
+			}
+			filter, err := msg.Filter()
+			if err != nil {
+				_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseUnsupportedData, err.Error()), time.Now().Add(streamWriteLimit))
+				return
+			}
+			sub.SetFilter(filter)
+		}
+	}()
+
+	ping := time.NewTicker(streamKeepAlive)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-sub.Done():
+			log.Printf("Disconnecting slow WebSocket client %s.", r.RemoteAddr)
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "client too slow"), time.Now().Add(streamWriteLimit))
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteLimit)); err != nil {
+				return
+			}
+		case ev := <-sub.Events():
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteLimit))
+			if err := conn.WriteJSON(newStreamMessage(sub.Filter().Mode, ev)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func newStreamMessage(mode string, ev stream.Event) StreamMessage {
+	if mode == stream.ModeState {
+		return StreamMessage{Mode: mode, Aircraft: stateToResponse(&ev.State)}
+	}
+	resp := stateToResponse(&ev.Record)
+	resp.MessageType = ev.Record.MessageType
+	resp.TransmissionType = ev.Record.TransmissionType
+	return StreamMessage{Mode: stream.ModeRecord, Aircraft: resp}
+}
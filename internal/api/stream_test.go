@@ -0,0 +1,170 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/state"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/stream"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// startStream serves the API with a stream hub on a local server.
+func startStream(t *testing.T) (*httptest.Server, *stream.Hub) {
+	t.Helper()
+	hub := stream.NewHub(16, 0)
+	srv := httptest.NewServer(NewHandler(state.NewStore(time.Hour, 10), hub))
+	t.Cleanup(srv.Close)
+	return srv, hub
+}
+
+// publishAircraft publishes an identification message of hex with its merged state.
+func publishAircraft(hub *stream.Hub, hex, callsign string) {
+	altitude := 35000
+	hub.Publish(
+		&models.AircraftData{MessageType: models.MessageTypeTransmission, TransmissionType: "1", HexIdent: hex, Callsign: callsign},
+		&models.AircraftData{MessageType: models.MessageTypeTransmission, TransmissionType: "1", HexIdent: hex, Callsign: callsign, Altitude: &altitude},
+	)
+}
+
+func TestStreamSSE(t *testing.T) {
+	srv, hub := startStream(t)
+	resp, err := http.Get(srv.URL + "/api/stream?hex=3c6586")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, Content-Type %q; want 200 and text/event-stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	publishAircraft(hub, "4CA2D6", "EIN123")
+	publishAircraft(hub, "3C6586", "DLH4AB")
+
+	lines := bufio.NewReader(resp.Body)
+	var frame []string
+	for range 3 {
+		line, err := lines.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame = append(frame, line)
+	}
+	if frame[0] != "event: aircraft\n" || !strings.HasPrefix(frame[1], "data: {") || frame[2] != "\n" {
+		t.Fatalf("frame = %q, want an aircraft event with a single data line", frame)
+	}
+	var msg StreamMessage
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(frame[1], "data: ")), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Mode != stream.ModeRecord || msg.Aircraft.Hex != "3C6586" || msg.Aircraft.MessageType != "MSG" || msg.Aircraft.TransmissionType != "1" || msg.Aircraft.Altitude != nil {
+		t.Errorf("message = %+v, want the record of 3C6586", msg)
+	}
+}
+
+func TestStreamRejectsInvalidFilter(t *testing.T) {
+	srv, hub := startStream(t)
+	for _, path := range []string{
+		"/api/stream?mode=raw",
+		"/api/stream?bbox=53,-7",
+		"/api/stream/ws?min_alt=high",
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body errorResponse
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || err != nil || body.Error == "" {
+			t.Errorf("GET %s: status %d, error %q; want %d and an error", path, resp.StatusCode, body.Error, http.StatusBadRequest)
+		}
+	}
+	if hub.Len() != 0 {
+		t.Errorf("%d subscribers after rejected requests, want 0", hub.Len())
+	}
+}
+
+func TestStreamWebSocket(t *testing.T) {
+	srv, hub := startStream(t)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/stream/ws?mode=state&hex=4CA2D6"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	publishAircraft(hub, "3C6586", "DLH4AB")
+	publishAircraft(hub, "4CA2D6", "EIN123")
+	var msg StreamMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Mode != stream.ModeState || msg.Aircraft.Hex != "4CA2D6" || msg.Aircraft.Altitude == nil || msg.Aircraft.MessageType != "" {
+		t.Errorf("message = %+v, want the state of 4CA2D6", msg)
+	}
+
+	// Replace the filter, publishing until it applies.
+	if err := conn.WriteJSON(stream.Subscription{Hex: []string{"3C6586"}}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+				publishAircraft(hub, "4CA2D6", "EIN123")
+				publishAircraft(hub, "3C6586", "DLH4AB")
+			}
+		}
+	}()
+	for msg.Aircraft.Hex != "3C6586" {
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range 3 {
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Mode != stream.ModeRecord || msg.Aircraft.Hex != "3C6586" {
+			t.Errorf("message after the filter update = %+v, want the record of 3C6586", msg)
+		}
+	}
+}
+
+func TestStreamWebSocketClosesOnInvalidSubscription(t *testing.T) {
+	srv, hub := startStream(t)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/stream/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := conn.WriteJSON(stream.Subscription{Mode: "raw"}); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseUnsupportedData) {
+		t.Fatalf("read %v, want a close with code %d", err, websocket.CloseUnsupportedData)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("subscriber not removed after the connection closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,251 @@
+package beast
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// cprMaxAge is how far apart an even and an odd position frame may be to be decoded together.
+	cprMaxAge = 10 * time.Second
+	// addressTTL is how long an address heard in an all-call or squitter is
+	// trusted for recovering the address of address/parity replies.
+	addressTTL = time.Minute
+)
+
+// Decoder turns Mode S messages into SBS-1 MSG lines. It keeps the state
+// needed across messages of one stream, so use one Decoder per stream.
+type Decoder struct {
+	loc       *time.Location
+	addresses map[uint32]time.Time // aircraft heard in DF11/17/18, by address
+	positions map[uint32]*cprPair
+	lastPrune time.Time
+}
+
+type cprPair struct {
+	frames [2]cprFrame // even, odd
+}
+
+type cprFrame struct {
+	lat, lon int
+	at       time.Time
+}
+
+// NewDecoder returns a Decoder that writes SBS-1 timestamps in loc.
+func NewDecoder(loc *time.Location) *Decoder {
+	return &Decoder{
+		loc:       loc,
+		addresses: make(map[uint32]time.Time),
+		positions: make(map[uint32]*cprPair),
+	}
+}
+
+// sbsLine holds the 22 fields of an SBS-1 MSG line.
+type sbsLine [22]string
+
+// Decode returns the SBS-1 line for a Mode S message received at t, the way
+// dump1090 writes it on port 30003. It returns false for messages that fail
+// their parity check, come from an unknown address, or carry nothing that
+// SBS-1 can express.
+func (d *Decoder) Decode(msg []byte, t time.Time) (string, bool) {
+	if len(msg) != 7 && len(msg) != 14 {
+		return "", false
+	}
+	df := msg[0] >> 3
+	if (df >= 16) != (len(msg) == 14) {
+		return "", false
+	}
+	d.prune(t)
+
+	var line sbsLine
+	var addr uint32
+	switch df {
+	case 0, 16: // air-air surveillance
+		if addr = parity(msg); !d.known(addr, t) {
+			return "", false
+		}
+		line[1] = "7"
+		if alt, ok := altitude13(uint32(msg[2]&0x1F)<<8 | uint32(msg[3])); ok {
+			line[11] = strconv.Itoa(alt)
+		}
+		line[21] = flag(msg[0]&0x04 != 0)
+	case 4, 20, 5, 21: // surveillance altitude and identity replies
+		if addr = parity(msg); !d.known(addr, t) {
+			return "", false
+		}
+		field := uint32(msg[2]&0x1F)<<8 | uint32(msg[3])
+		if df == 4 || df == 20 {
+			line[1] = "5"
+			if alt, ok := altitude13(field); ok {
+				line[11] = strconv.Itoa(alt)
+			}
+		} else {
+			line[1] = "6"
+			line[17] = squawk(field)
+			line[19] = flag(line[17] == "7500" || line[17] == "7600" || line[17] == "7700")
+		}
+		d.flightStatus(&line, msg[0]&0x07)
+	case 11: // all-call reply
+		if parity(msg)&^0x7F != 0 { // only the interrogator code may remain
+			return "", false
+		}
+		addr = address(msg)
+		d.addresses[addr] = t
+		line[1] = "8"
+		line[21] = flag(msg[0]&0x07 == 4)
+	case 17, 18: // extended squitter
+		if parity(msg) != 0 || (df == 18 && msg[0]&0x07 != 0 && msg[0]&0x07 != 1 && msg[0]&0x07 != 6) {
+			return "", false
+		}
+		addr = address(msg)
+		d.addresses[addr] = t
+		if !d.extendedSquitter(&line, addr, msg[4:11], t) {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	line[0] = "MSG"
+	line[2], line[3], line[5] = "1", "1", "1"
+	line[4] = fmt.Sprintf("%06X", addr)
+	local := t.In(d.loc)
+	line[6], line[7] = local.Format("2006/01/02"), local.Format("15:04:05.000")
+	line[8], line[9] = line[6], line[7]
+	return strings.Join(line[:], ","), true
+}
+
+// extendedSquitter fills in an ADS-B message, returning false for message types SBS-1 does not carry.
+func (d *Decoder) extendedSquitter(line *sbsLine, addr uint32, me []byte, t time.Time) bool {
+	tc := me[0] >> 3
+	switch {
+	case tc >= 1 && tc <= 4: // identification
+		line[1] = "1"
+		line[10] = strings.TrimRight(strings.ReplaceAll(callsign(me), "#", ""), " ")
+	case tc >= 5 && tc <= 8: // surface position; the position itself needs a reference location
+		line[1] = "2"
+		if speed, ok := surfaceSpeed(int(me[0]&0x07)<<4 | int(me[1]>>4)); ok {
+			line[12] = strconv.FormatFloat(speed, 'f', 1, 64)
+		}
+		if me[1]&0x08 != 0 {
+			track := float64(int(me[1]&0x07)<<4|int(me[2]>>4)) * 360 / 128
+			line[13] = strconv.FormatFloat(track, 'f', 1, 64)
+		}
+		line[21] = flag(true)
+	case tc >= 9 && tc <= 18: // airborne position with barometric altitude
+		line[1] = "3"
+		if alt, ok := altitude12(uint32(me[1])<<4 | uint32(me[2]>>4)); ok {
+			line[11] = strconv.Itoa(alt)
+		}
+		odd := me[2]&0x04 != 0
+		lat := int(me[2]&0x03)<<15 | int(me[3])<<7 | int(me[4]>>1)
+		lon := int(me[4]&0x01)<<16 | int(me[5])<<8 | int(me[6])
+		if lat, lon, ok := d.position(addr, odd, lat, lon, t); ok {
+			line[14] = strconv.FormatFloat(lat, 'f', 5, 64)
+			line[15] = strconv.FormatFloat(lon, 'f', 5, 64)
+		}
+		line[21] = flag(false)
+	case tc == 19: // airborne velocity
+		subtype := me[0] & 0x07
+		if subtype != 1 && subtype != 2 {
+			return false // airspeed and heading, which SBS-1 has no fields for
+		}
+		line[1] = "4"
+		ew := int(me[1]&0x03)<<8 | int(me[2])
+		ns := int(me[3]&0x7F)<<3 | int(me[4]>>5)
+		if ew > 0 && ns > 0 {
+			vx, vy := float64(ew-1), float64(ns-1)
+			if subtype == 2 { // supersonic
+				vx, vy = vx*4, vy*4
+			}
+			if me[1]&0x04 != 0 {
+				vx = -vx
+			}
+			if me[3]&0x80 != 0 {
+				vy = -vy
+			}
+			track := math.Atan2(vx, vy) * 180 / math.Pi
+			if track < 0 {
+				track += 360
+			}
+			line[12] = strconv.FormatFloat(math.Hypot(vx, vy), 'f', 1, 64)
+			line[13] = strconv.FormatFloat(track, 'f', 1, 64)
+		}
+		if vr := int(me[4]&0x07)<<6 | int(me[5]>>2); vr > 0 {
+			rate := (vr - 1) * 64
+			if me[4]&0x08 != 0 {
+				rate = -rate
+			}
+			line[16] = strconv.Itoa(rate)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// flightStatus sets the alert, SPI and ground flags from the FS field of a surveillance reply.
+func (d *Decoder) flightStatus(line *sbsLine, fs byte) {
+	line[18] = flag(fs >= 2 && fs <= 4)
+	line[20] = flag(fs == 4 || fs == 5)
+	if fs <= 3 {
+		line[21] = flag(fs == 1 || fs == 3)
+	}
+}
+
+// position records a CPR frame and decodes it against the latest frame of the
+// other kind, if that one is recent enough.
+func (d *Decoder) position(addr uint32, odd bool, lat, lon int, t time.Time) (float64, float64, bool) {
+	pair := d.positions[addr]
+	if pair == nil {
+		pair = &cprPair{}
+		d.positions[addr] = pair
+	}
+	i := 0
+	if odd {
+		i = 1
+	}
+	pair.frames[i] = cprFrame{lat: lat, lon: lon, at: t}
+	other := pair.frames[1-i]
+	if other.at.IsZero() || t.Sub(other.at).Abs() > cprMaxAge {
+		return 0, 0, false
+	}
+	even, oddFrame := pair.frames[0], pair.frames[1]
+	return cprGlobal(even.lat, even.lon, oddFrame.lat, oddFrame.lon, odd)
+}
+
+// known reports whether addr has recently been heard in a message with a
+// verifiable address, so that the address recovered from the parity of a
+// reply is likely not a corrupted message.
+func (d *Decoder) known(addr uint32, t time.Time) bool {
+	last, ok := d.addresses[addr]
+	return ok && t.Sub(last) < addressTTL
+}
+
+// prune forgets aircraft that have not been heard from for a while.
+func (d *Decoder) prune(t time.Time) {
+	if t.Sub(d.lastPrune) < addressTTL {
+		return
+	}
+	d.lastPrune = t
+	for addr, last := range d.addresses {
+		if t.Sub(last) >= addressTTL {
+			delete(d.addresses, addr)
+			delete(d.positions, addr)
+		}
+	}
+}
+
+func address(msg []byte) uint32 {
+	return uint32(msg[1])<<16 | uint32(msg[2])<<8 | uint32(msg[3])
+}
+
+func flag(set bool) string {
+	if set {
+		return "-1"
+	}
+	return "0"
+}
@@ -0,0 +1,75 @@
+package beast
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	const (
+		identification = "8D4840D6202CC371C32CE0576098"
+		evenPosition   = "8D40621D58C382D690C8AC2863A7"
+		oddPosition    = "8D40621D58C386435CC412692AD6"
+		allCall        = "5D4840D6F8741A" // interrogator code 0x15
+		identity       = "28000AAA02E41F" // squawk 7700 from 4840D6
+		altitude       = "21001838723EDE" // 38000 ft, on the ground, from 4840D6
+	)
+	at := time.Date(2016, 3, 14, 23, 0, 0, 0, time.UTC)
+	const stamp = "2016/03/14,23:00:00.000,2016/03/14,23:00:00.000"
+
+	type heard struct {
+		msg string
+		ago time.Duration
+	}
+	tests := []struct {
+		name   string
+		before []heard // messages decoded earlier
+		msg    string
+		want   string // "" if the message is dropped
+	}{
+		{"identification", nil, identification,
+			"MSG,1,1,1,4840D6,1," + stamp + ",KLM1023,,,,,,,,,,,"},
+		{"position without a pair", nil, evenPosition,
+			"MSG,3,1,1,40621D,1," + stamp + ",,38000,,,,,,,,,,0"},
+		{"position pair", []heard{{oddPosition, time.Second}}, evenPosition,
+			"MSG,3,1,1,40621D,1," + stamp + ",,38000,,,52.25720,3.91937,,,,,,0"},
+		{"position pair, odd latest", []heard{{evenPosition, time.Second}}, oddPosition,
+			"MSG,3,1,1,40621D,1," + stamp + ",,38000,,,52.26578,3.93891,,,,,,0"},
+		{"position pair too far apart", []heard{{oddPosition, 11 * time.Second}}, evenPosition,
+			"MSG,3,1,1,40621D,1," + stamp + ",,38000,,,,,,,,,,0"},
+		{"all-call with interrogator code", nil, allCall,
+			"MSG,8,1,1,4840D6,1," + stamp + ",,,,,,,,,,,,0"},
+		{"all-call with corrupted parity", nil, "5D4840D6F8748F", ""},
+		{"corrupted squitter", nil, "8D4840D6202CC371C32CE0576099", ""},
+		{"identity from an unknown address", nil, identity, ""},
+		{"identity after an all-call", []heard{{allCall, time.Second}}, identity,
+			"MSG,6,1,1,4840D6,1," + stamp + ",,,,,,,,7700,0,-1,0,0"},
+		{"altitude after a squitter", []heard{{identification, time.Second}}, altitude,
+			"MSG,5,1,1,4840D6,1," + stamp + ",,38000,,,,,,,0,,0,-1"},
+		{"address forgotten", []heard{{allCall, 2 * time.Minute}}, identity, ""},
+		{"wrong length", nil, "8D4840D6202CC371C32CE05760", ""},
+		{"short squitter", nil, "8D4840D6202CC3", ""},
+		{"long format in a short message", nil, "A8000AAA02E41F", ""},
+		{"unsupported downlink format", nil, "40000AAA02E41F", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(time.UTC)
+			for _, h := range tt.before {
+				d.Decode(mustDecodeHex(t, h.msg), at.Add(-h.ago))
+			}
+			got, ok := d.Decode(mustDecodeHex(t, tt.msg), at)
+			if ok != (tt.want != "") || got != tt.want {
+				t.Errorf("Decode(%s) = %q, %t\nwant %q", tt.msg, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeLocation(t *testing.T) {
+	d := NewDecoder(time.FixedZone("UTC+2", 2*3600))
+	got, ok := d.Decode(mustDecodeHex(t, "8D4840D6202CC371C32CE0576098"), time.Date(2016, 3, 14, 23, 0, 0, 0, time.UTC))
+	if want := "MSG,1,1,1,4840D6,1,2016/03/15,01:00:00.000,2016/03/15,01:00:00.000,KLM1023,,,,,,,,,,,"; !ok || got != want {
+		t.Errorf("Decode = %q, %t; want %q", got, ok, want)
+	}
+}
@@ -0,0 +1,217 @@
+package beast
+
+import (
+	"fmt"
+	"math"
+)
+
+// crcTable holds the Mode S CRC-24 (generator polynomial 0xFFF409) of every byte value.
+var crcTable = func() (table [256]uint32) {
+	for i := range table {
+		c := uint32(i) << 16
+		for j := 0; j < 8; j++ {
+			if c&0x800000 != 0 {
+				c = c<<1 ^ 0xFFF409
+			} else {
+				c <<= 1
+			}
+		}
+		table[i] = c & 0xFFFFFF
+	}
+	return table
+}()
+
+// parity returns the CRC of msg xored with its last 24 bits: 0 for a valid
+// extended squitter, the interrogator code for DF11 and the aircraft address
+// for messages with address/parity.
+func parity(msg []byte) uint32 {
+	n := len(msg) - 3
+	var c uint32
+	for _, b := range msg[:n] {
+		c = (c<<8 ^ crcTable[byte(c>>16)^b]) & 0xFFFFFF
+	}
+	return c ^ (uint32(msg[n])<<16 | uint32(msg[n+1])<<8 | uint32(msg[n+2]))
+}
+
+// squawk decodes the 13-bit identity field of DF5/21 into the four octal digits of the Mode A code.
+func squawk(id uint32) string {
+	return fmt.Sprintf("%04x", gillham(id))
+}
+
+// gillham reorders the bits of a 13-bit identity or altitude field
+// (C1 A1 C2 A2 C4 A4 X B1 D1 B2 D2 B4 D4) into 0xABCD, with one octal digit per nibble.
+func gillham(field uint32) uint32 {
+	var code uint32
+	bits := [13]uint32{
+		0x0004, // D4
+		0x0400, // B4
+		0x0002, // D2
+		0x0200, // B2
+		0x0001, // D1
+		0x0100, // B1
+		0,      // X or M
+		0x4000, // A4
+		0x0040, // C4
+		0x2000, // A2
+		0x0020, // C2
+		0x1000, // A1
+		0x0010, // C1
+	}
+	for i, bit := range bits {
+		if field&(1<<i) != 0 {
+			code |= bit
+		}
+	}
+	return code
+}
+
+// altitude13 decodes the 13-bit altitude code of DF0/4/16/20 in feet.
+func altitude13(ac uint32) (int, bool) {
+	if ac == 0 || ac&0x40 != 0 { // unavailable, or metric
+		return 0, false
+	}
+	if ac&0x10 != 0 { // Q bit: 25 ft increments
+		n := (ac&0x1F80)>>2 | (ac&0x0020)>>1 | ac&0x000F
+		return int(n)*25 - 1000, true
+	}
+	return modeCAltitude(gillham(ac))
+}
+
+// altitude12 decodes the 12-bit altitude of an airborne position message in
+// feet. It is the 13-bit code without the M bit.
+func altitude12(ac uint32) (int, bool) {
+	return altitude13((ac&0x0FC0)<<1 | ac&0x003F)
+}
+
+// modeCAltitude decodes a Gillham-coded altitude (100 ft increments), given in the 0xABCD form.
+func modeCAltitude(code uint32) (int, bool) {
+	if code&0xFFFF8889 != 0 || code&0x00F0 == 0 { // D1 is never used, and the C bits cannot all be zero
+		return 0, false
+	}
+	var fiveHundreds, oneHundreds uint32
+	for _, step := range []struct{ bit, mask uint32 }{{0x0010, 7}, {0x0020, 3}, {0x0040, 1}} {
+		if code&step.bit != 0 {
+			oneHundreds ^= step.mask
+		}
+	}
+	if oneHundreds&5 == 5 { // 7 and 5 are swapped in the Gillham code
+		oneHundreds ^= 2
+	}
+	if oneHundreds > 5 {
+		return 0, false
+	}
+	for _, step := range []struct{ bit, mask uint32 }{
+		{0x0002, 0xFF}, {0x0004, 0x7F}, // D2, D4
+		{0x1000, 0x3F}, {0x2000, 0x1F}, {0x4000, 0x0F}, // A1, A2, A4
+		{0x0100, 0x07}, {0x0200, 0x03}, {0x0400, 0x01}, // B1, B2, B4
+	} {
+		if code&step.bit != 0 {
+			fiveHundreds ^= step.mask
+		}
+	}
+	if fiveHundreds&1 != 0 {
+		oneHundreds = 6 - oneHundreds
+	}
+	return (int(fiveHundreds*5+oneHundreds) - 13) * 100, true
+}
+
+// callsignChars maps the 6-bit characters of an identification message.
+const callsignChars = "#ABCDEFGHIJKLMNOPQRSTUVWXYZ##### ###############0123456789######"
+
+// callsign decodes the eight characters packed into the 48 bits of me[1:7].
+func callsign(me []byte) string {
+	var bits uint64
+	for _, b := range me[1:7] {
+		bits = bits<<8 | uint64(b)
+	}
+	out := make([]byte, 8)
+	for i := range out {
+		out[i] = callsignChars[bits>>(42-6*i)&0x3F]
+	}
+	return string(out)
+}
+
+// surfaceSpeed decodes the movement field of a surface position message in knots.
+func surfaceSpeed(movement int) (float64, bool) {
+	switch {
+	case movement == 1:
+		return 0, true
+	case movement >= 2 && movement <= 8:
+		return 0.125 + float64(movement-2)*0.125, true
+	case movement >= 9 && movement <= 12:
+		return 1 + float64(movement-9)*0.25, true
+	case movement >= 13 && movement <= 38:
+		return 2 + float64(movement-13)*0.5, true
+	case movement >= 39 && movement <= 93:
+		return 15 + float64(movement-39), true
+	case movement >= 94 && movement <= 108:
+		return 70 + float64(movement-94)*2, true
+	case movement >= 109 && movement <= 123:
+		return 100 + float64(movement-109)*5, true
+	case movement == 124:
+		return 175, true
+	}
+	return 0, false
+}
+
+// cprMax is 2^17, the scale of the encoded CPR coordinates.
+const cprMax = 131072.0
+
+// cprGlobal decodes an airborne position from an even and an odd CPR frame.
+// The result is the position of the most recent frame, which is the odd one
+// if oddLatest is set.
+func cprGlobal(evenLat, evenLon, oddLat, oddLon int, oddLatest bool) (lat, lon float64, ok bool) {
+	const dLatEven, dLatOdd = 360.0 / 60, 360.0 / 59
+	j := math.Floor((59*float64(evenLat)-60*float64(oddLat))/cprMax + 0.5)
+	latEven := dLatEven * (cprMod(j, 60) + float64(evenLat)/cprMax)
+	latOdd := dLatOdd * (cprMod(j, 59) + float64(oddLat)/cprMax)
+	if latEven >= 270 {
+		latEven -= 360
+	}
+	if latOdd >= 270 {
+		latOdd -= 360
+	}
+	if latEven < -90 || latEven > 90 || latOdd < -90 || latOdd > 90 {
+		return 0, 0, false
+	}
+	nl := cprNL(latEven)
+	if nl != cprNL(latOdd) {
+		return 0, 0, false // the frames straddle a longitude zone boundary
+	}
+
+	lat, cprLon, ni := latEven, float64(evenLon), max(nl, 1)
+	if oddLatest {
+		lat, cprLon, ni = latOdd, float64(oddLon), max(nl-1, 1)
+	}
+	m := math.Floor((float64(evenLon)*float64(nl-1)-float64(oddLon)*float64(nl))/cprMax + 0.5)
+	lon = 360 / float64(ni) * (cprMod(m, float64(ni)) + cprLon/cprMax)
+	if lon > 180 {
+		lon -= 360
+	}
+	return lat, lon, true
+}
+
+func cprMod(a, b float64) float64 {
+	r := math.Mod(a, b)
+	if r < 0 {
+		r += b
+	}
+	return r
+}
+
+// cprNL returns the number of longitude zones at a latitude.
+func cprNL(lat float64) int {
+	lat = math.Abs(lat)
+	switch {
+	case lat == 0:
+		return 59
+	case lat == 87:
+		return 2
+	case lat > 87:
+		return 1
+	}
+	const nz = 15
+	a := 1 - math.Cos(math.Pi/(2*nz))
+	b := math.Pow(math.Cos(math.Pi/180*lat), 2)
+	return int(math.Floor(2 * math.Pi / math.Acos(1-a/b)))
+}
@@ -0,0 +1,141 @@
+package beast
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+// mustDecodeHex returns the bytes of a message written in hex.
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	msg, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestParity(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want uint32
+	}{
+		{"extended squitter", "8D4840D6202CC371C32CE0576098", 0},
+		{"position", "8D40621D58C382D690C8AC2863A7", 0},
+		{"corrupted squitter", "8D4840D6202CC371C32CE0576099", 1},
+		{"all-call", "5D4840D6F8740F", 0},
+		{"all-call with interrogator code", "5D4840D6F8741A", 0x15},
+		{"identity reply", "28000AAA02E41F", 0x4840D6},
+		{"altitude reply", "21001838723EDE", 0x4840D6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parity(mustDecodeHex(t, tt.msg)); got != tt.want {
+				t.Errorf("parity(%s) = %#06x, want %#06x", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGillham(t *testing.T) {
+	// Identity fields are C1 A1 C2 A2 C4 A4 X B1 D1 B2 D2 B4 D4.
+	tests := []struct {
+		field uint32
+		want  string
+	}{
+		{0x0000, "0000"},
+		{0x0AAA, "7700"}, // A1 A2 A4 B1 B2 B4
+		{0x0808, "1200"}, // A1 B2
+		{0x1555, "0077"}, // C1 C2 C4 D1 D2 D4
+		{0x0040, "0000"}, // X is not part of the code
+		{0x0001, "0004"}, // D4
+		{0x1000, "0010"}, // C1
+	}
+	for _, tt := range tests {
+		if got := squawk(tt.field); got != tt.want {
+			t.Errorf("squawk(%#04x) = %s, want %s", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestAltitude13(t *testing.T) {
+	tests := []struct {
+		name string
+		ac   uint32
+		want int
+		ok   bool
+	}{
+		{"25 ft increments", 0x1838, 38000, true},
+		{"lowest with Q bit", 0x0010, -1000, true},
+		{"100 ft increments", 0x0C00, 30500, true},
+		{"100 ft increments, low", 0x0428, 1000, true},
+		{"invalid 100 ft code", 0x0208, 0, false},
+		{"C bits all zero", 0x1580, 0, false},
+		{"unavailable", 0, 0, false},
+		{"metric", 0x1878, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := altitude13(tt.ac)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("altitude13(%#04x) = %d, %t; want %d, %t", tt.ac, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+	// The 12-bit altitude of a position message lacks the M bit.
+	if got, ok := altitude12(0xC38); got != 38000 || !ok {
+		t.Errorf("altitude12(0xc38) = %d, %t; want 38000, true", got, ok)
+	}
+}
+
+func TestCPRGlobal(t *testing.T) {
+	// The CPR coordinates of 8D40621D58C382D690C8AC2863A7 (even) and
+	// 8D40621D58C386435CC412692AD6 (odd).
+	tests := []struct {
+		name      string
+		oddLatest bool
+		lat, lon  float64
+	}{
+		{"even latest", false, 52.2572, 3.9194},
+		{"odd latest", true, 52.2658, 3.9389},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lat, lon, ok := cprGlobal(93000, 51372, 74158, 50194, tt.oddLatest)
+			if !ok || math.Abs(lat-tt.lat) > 1e-4 || math.Abs(lon-tt.lon) > 1e-4 {
+				t.Errorf("cprGlobal = %.5f, %.5f, %t; want %.4f, %.4f", lat, lon, ok, tt.lat, tt.lon)
+			}
+		})
+	}
+	// Latitudes of 10.46° (even) and 10.48° (odd), either side of the NL boundary at 10.47047130°.
+	if lat, lon, ok := cprGlobal(97431, 0, 94051, 0, false); ok {
+		t.Errorf("cprGlobal across a longitude zone boundary = %.5f, %.5f, want no position", lat, lon)
+	}
+}
+
+func TestCPRNL(t *testing.T) {
+	tests := []struct {
+		lat  float64
+		want int
+	}{
+		{0, 59},
+		{10.47, 59},
+		{10.48, 58},
+		{14.82, 58},
+		{14.83, 57},
+		{52.2572, 36},
+		{-52.2572, 36},
+		{86.5, 3},
+		{86.6, 2},
+		{87, 2},
+		{87.1, 1},
+		{-90, 1},
+	}
+	for _, tt := range tests {
+		if got := cprNL(tt.lat); got != tt.want {
+			t.Errorf("cprNL(%v) = %d, want %d", tt.lat, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package beast reads the Mode-S Beast binary format written by dump1090 on
+// port 30005 and decodes the Mode S messages it carries into SBS-1 lines, the
+// same way dump1090 does for port 30003.
+package beast
+
+import (
+	"bufio"
+	"io"
+)
+
+// esc starts every frame; inside a frame, a literal 0x1a byte is doubled.
+const esc = 0x1a
+
+// Frame types.
+const (
+	FrameModeAC     = '1'
+	FrameModeSShort = '2'
+	FrameModeSLong  = '3'
+)
+
+// TimestampHz is the rate of the 48-bit MLAT counter in each frame.
+const TimestampHz = 12_000_000
+
+// frameLengths is the message length of each frame type, excluding the
+// 6-byte timestamp and the signal level byte.
+var frameLengths = map[byte]int{
+	FrameModeAC:     2,
+	FrameModeSShort: 7,
+	FrameModeSLong:  14,
+}
+
+// Frame is one message read from a Beast stream.
+type Frame struct {
+	Type      byte   // FrameModeAC, FrameModeSShort or FrameModeSLong
+	Timestamp uint64 // MLAT counter at TimestampHz; 0 if the receiver does not provide one
+	Signal    byte
+	Data      []byte // the raw message, only valid until the next call to Read
+}
+
+// Reader reads frames from a Beast stream.
+type Reader struct {
+	r      *bufio.Reader
+	buf    [7 + 14]byte
+	synced bool // an esc byte starting the next frame has already been consumed
+	frame  Frame
+}
+
+// NewReader returns a Reader for a Beast stream.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next Mode A/C or Mode S frame, or io.EOF at the end of the
+// stream. Status frames, unknown frame types and truncated frames are skipped.
+// The returned frame is only valid until the next call to Read.
+func (r *Reader) Read() (*Frame, error) {
+	for {
+		if !r.synced {
+			b, err := r.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if b != esc {
+				continue
+			}
+		}
+		r.synced = false
+
+		typ, err := r.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		n, ok := frameLengths[typ]
+		if !ok {
+			continue // a status frame, or an escaped data byte seen out of sync
+		}
+
+		body, complete, err := r.readBody(7 + n)
+		if err != nil {
+			return nil, err
+		}
+		if !complete {
+			continue
+		}
+
+		var ts uint64
+		for _, b := range body[:6] {
+			ts = ts<<8 | uint64(b)
+		}
+		r.frame = Frame{Type: typ, Timestamp: ts, Signal: body[6], Data: body[7:]}
+		return &r.frame, nil
+	}
+}
+
+// readBody reads n unescaped bytes. If an unescaped esc byte shows up first,
+// the frame was truncated and the esc byte starts the next one.
+func (r *Reader) readBody(n int) ([]byte, bool, error) {
+	body := r.buf[:0]
+	for len(body) < n {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			return nil, false, noEOF(err)
+		}
+		if b == esc {
+			next, err := r.r.ReadByte()
+			if err != nil {
+				return nil, false, noEOF(err)
+			}
+			if next != esc {
+				_ = r.r.UnreadByte()
+				r.synced = true
+				return nil, false, nil
+			}
+		}
+		body = append(body, b)
+	}
+	return body, true, nil
+}
+
+// noEOF turns an EOF in the middle of a frame into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,338 @@
+// Package bst reads and writes BaseStation BST log files: one quoted CSV line
+// of 17 fields per aircraft snapshot, as described in docs/sbs-bst-formats.md.
+package bst
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/parser"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// NumFields is the number of fields in a BST line.
+const NumFields = 17
+
+const (
+	dateLayout = "2006/01/02"
+	timeLayout = "15:04:05.000"
+)
+
+var fieldNames = [NumFields]string{
+	"Date", "Time", "Mode S Code (Decimal)", "Mode S Code (Hex)", "Callsign", "Country",
+	"IsOnGround", "Altitude", "Altitude (Future Dev)", "Latitude", "Longitude",
+	"Vertical Rate", "Vertical Rate (Adjusted)", "GroundSpeed", "Track",
+	"Squawk (Decimal)", "Squawk (Octal)",
+}
+
+var (
+	// ErrModeSMismatch is returned when the decimal and hex Mode S codes disagree.
+	ErrModeSMismatch = errors.New("decimal and hex Mode S codes differ")
+	// ErrSquawkMismatch describes lines whose decimal and octal squawks
+	// disagree; the octal squawk is kept and Reader counts them.
+	ErrSquawkMismatch = errors.New("decimal and octal squawks differ")
+)
+
+// Decode maps the fields of one BST line to a record with MessageType "BST".
+// Timestamps are read in loc, the time zone of the BaseStation that wrote the file.
+// Every field that fails to decode is reported in a *parser.ParseError.
+func Decode(fields []string, loc *time.Location) (*models.AircraftData, error) {
+	data, _, err := decode(fields, loc)
+	return data, err
+}
+
+// decode is Decode, also reporting whether the decimal and octal squawks disagree.
+func decode(fields []string, loc *time.Location) (*models.AircraftData, bool, error) {
+	if len(fields) != NumFields {
+		return nil, false, fmt.Errorf("expected %d fields in BST line, got %d", NumFields, len(fields))
+	}
+	d := decoder{fields: fields}
+
+	data := &models.AircraftData{
+		MessageType:  models.MessageTypeSnapshot,
+		HexIdent:     d.modeS(),
+		Callsign:     strings.TrimRight(d.text(4), "@ "),
+		IsOnGround:   d.flag(6),
+		Altitude:     d.int(7),
+		Latitude:     d.float(9),
+		Longitude:    d.float(10),
+		VerticalRate: d.int(11),
+		GroundSpeed:  d.float(13),
+		Track:        d.float(14),
+		Squawk:       d.squawk(),
+	}
+	if country := d.text(5); country != "" {
+		data.Tags = map[string]string{models.TagCountry: country}
+	}
+
+	date, clock := d.text(0), d.text(1)
+	ts, err := time.ParseInLocation(dateLayout+" 15:04:05.999", date+" "+clock, loc)
+	if err != nil {
+		d.fail(0, date+" "+clock, err)
+	}
+	data.GeneratedTimestamp = ts
+	data.LoggedTimestamp = ts
+	data.Timestamp = ts
+
+	if len(d.errs) > 0 {
+		return nil, false, &parser.ParseError{Line: strings.Join(fields, ","), HexIdent: data.HexIdent, Fields: d.errs}
+	}
+	return data, d.squawkMismatch, nil
+}
+
+// Encode renders a record as the 17 fields of a BST line, with timestamps in loc.
+// Unknown values are left empty.
+func Encode(data *models.AircraftData, loc *time.Location) []string {
+	fields := make([]string, NumFields)
+	ts := data.Timestamp.In(loc)
+	fields[0] = ts.Format(dateLayout)
+	fields[1] = ts.Format(timeLayout)
+	hex := strings.ToUpper(data.HexIdent)
+	if code, err := strconv.ParseUint(hex, 16, 32); err == nil {
+		fields[2] = strconv.FormatUint(code, 10)
+	}
+	fields[3] = hex
+	fields[4] = data.Callsign
+	fields[5] = data.Tags[models.TagCountry]
+	if data.IsOnGround != nil {
+		fields[6] = "0"
+		if *data.IsOnGround {
+			fields[6] = "-1"
+		}
+	}
+	if data.Altitude != nil {
+		fields[7] = strconv.Itoa(*data.Altitude)
+		fields[8] = fields[7]
+	}
+	if data.Latitude != nil {
+		fields[9] = strconv.FormatFloat(*data.Latitude, 'f', 5, 64)
+	}
+	if data.Longitude != nil {
+		fields[10] = strconv.FormatFloat(*data.Longitude, 'f', 5, 64)
+	}
+	if data.VerticalRate != nil {
+		fields[11] = strconv.Itoa(*data.VerticalRate)
+		fields[12] = fields[11]
+	}
+	if data.GroundSpeed != nil {
+		fields[13] = strconv.FormatFloat(*data.GroundSpeed, 'f', 1, 64)
+	}
+	if data.Track != nil {
+		fields[14] = strconv.FormatFloat(*data.Track, 'f', 1, 64)
+	}
+	if data.Squawk != "" {
+		// The decimal squawk is the value of the octal code, e.g. 4032 for 7700.
+		if code, err := strconv.ParseUint(data.Squawk, 8, 12); err == nil {
+			fields[15] = strconv.FormatUint(code, 10)
+		}
+		fields[16] = data.Squawk
+	}
+	return fields
+}
+
+// Reader reads records from a BST file.
+type Reader struct {
+	csv  *csv.Reader
+	loc  *time.Location
+	line int
+
+	squawkMismatches int
+}
+
+// NewReader returns a Reader for BST lines written by a BaseStation in loc.
+func NewReader(r io.Reader, loc *time.Location) *Reader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = NumFields
+	cr.ReuseRecord = true
+	return &Reader{csv: cr, loc: loc}
+}
+
+// Read returns the next record, or io.EOF at the end of the file. A line that
+// does not decode is returned as an error that names its line number;
+// reading can continue with the next line.
+func (r *Reader) Read() (*models.AircraftData, error) {
+	fields, err := r.csv.Read()
+	r.line++
+	if err != nil {
+		return nil, err // csv errors already carry the line number
+	}
+	data, mismatch, err := decode(fields, r.loc)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", r.line, err)
+	}
+	if mismatch {
+		r.squawkMismatches++
+	}
+	return data, nil
+}
+
+// SquawkMismatches returns the number of lines read so far whose decimal and
+// octal squawks differ (see ErrSquawkMismatch).
+func (r *Reader) SquawkMismatches() int {
+	return r.squawkMismatches
+}
+
+// Writer writes records as quoted BST lines, the way BaseStation does.
+type Writer struct {
+	w   *bufio.Writer
+	loc *time.Location
+}
+
+// NewWriter returns a Writer that formats timestamps in loc.
+func NewWriter(w io.Writer, loc *time.Location) *Writer {
+	return &Writer{w: bufio.NewWriter(w), loc: loc}
+}
+
+// Write buffers one record; call Flush to write it out.
+func (w *Writer) Write(data *models.AircraftData) error {
+	for i, field := range Encode(data, w.loc) {
+		if i > 0 {
+			if err := w.w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		if _, err := w.w.WriteString(`"` + strings.ReplaceAll(field, `"`, `""`) + `"`); err != nil {
+			return err
+		}
+	}
+	_, err := w.w.WriteString("\r\n")
+	return err
+}
+
+// Flush writes any buffered lines to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// decoder reads typed values out of the fields of a BST line, collecting a
+// FieldError for every non-empty field that does not decode.
+type decoder struct {
+	fields []string
+	errs   []*parser.FieldError
+
+	squawkMismatch bool
+}
+
+func (d *decoder) text(i int) string {
+	return strings.TrimSpace(d.fields[i])
+}
+
+func (d *decoder) fail(i int, value string, err error) {
+	d.errs = append(d.errs, &parser.FieldError{Field: i + 1, Name: fieldNames[i], Value: value, Err: err})
+}
+
+func (d *decoder) int(i int) *int {
+	s := d.text(i)
+	if s == "" {
+		return nil
+	}
+	val, err := strconv.Atoi(s)
+	if err != nil {
+		d.fail(i, s, err)
+		return nil
+	}
+	return &val
+}
+
+func (d *decoder) float(i int) *float64 {
+	s := d.text(i)
+	if s == "" {
+		return nil
+	}
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		d.fail(i, s, err)
+		return nil
+	}
+	return &val
+}
+
+func (d *decoder) flag(i int) *bool {
+	s := d.text(i)
+	var val bool
+	switch s {
+	case "":
+		return nil
+	case "-1", "1":
+		val = true
+	case "0":
+		val = false
+	default:
+		d.fail(i, s, parser.ErrInvalidFlag)
+		return nil
+	}
+	return &val
+}
+
+// modeS returns the hex Mode S code, checked against (or, if the hex field is
+// empty, derived from) the decimal one.
+func (d *decoder) modeS() string {
+	dec, hex := d.text(2), strings.ToUpper(d.text(3))
+	if dec == "" {
+		return hex
+	}
+	code, err := strconv.ParseUint(dec, 10, 32)
+	if err != nil {
+		d.fail(2, dec, err)
+		return hex
+	}
+	fromDec := fmt.Sprintf("%06X", code)
+	if hex == "" {
+		return fromDec
+	}
+	if hexCode, err := strconv.ParseUint(hex, 16, 32); err != nil || hexCode != code {
+		d.fail(3, hex, ErrModeSMismatch)
+	}
+	return hex
+}
+
+// squawk returns the octal squawk, checked against (or, if the octal field is
+// empty, derived from) the decimal one, which is the value of the octal code.
+// BaseStation itself writes the octal digits read as a hex number (8726 for
+// 2216), which is accepted too. A mismatch is recorded rather than failing
+// the line, since the octal squawk is the one set in the cockpit.
+func (d *decoder) squawk() string {
+	dec, oct := d.text(15), d.text(16)
+	if (oct == "" || oct == "0") && (dec == "" || dec == "0") {
+		return "" // BaseStation writes 0 when no squawk has been received
+	}
+	if oct != "" {
+		if _, err := strconv.ParseUint(oct, 8, 12); err != nil {
+			d.fail(16, oct, err)
+			return ""
+		}
+		oct = fmt.Sprintf("%04s", oct)
+	}
+	if dec == "" {
+		return oct
+	}
+	code, err := strconv.ParseUint(dec, 10, 16)
+	if err != nil {
+		d.fail(15, dec, err)
+		return oct
+	}
+	var fromDec, fromHex string
+	if code <= 0o7777 {
+		fromDec = fmt.Sprintf("%04s", strconv.FormatUint(code, 8))
+	}
+	if hex := fmt.Sprintf("%04X", code); strings.Trim(hex, "01234567") == "" {
+		fromHex = hex
+	}
+	switch {
+	case oct == "" && fromDec != "":
+		return fromDec
+	case oct == "" && fromHex != "":
+		return fromHex
+	case oct == "":
+		d.fail(15, dec, strconv.ErrRange)
+	case oct != fromDec && oct != fromHex:
+		d.squawkMismatch = true
+	}
+	return oct
+}
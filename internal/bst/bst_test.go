@@ -0,0 +1,188 @@
+package bst
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/parser"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// capturedLines are BST lines written by BaseStation (see docs/sbs-bst-formats.md).
+var capturedLines = []string{
+	`"2018/07/05","02:44:34.126","9004131","896463","ETD44A","United Arab Emirates","0","39000","39000","52.05327","-3.81704","-64","-64","484.6","102.0","8726","2216"`,
+	`"2018/07/05","02:44:34.142","4736069","484445","KLM656","Netherlands","0","41000","41000","55.11269","-3.75159","0","0","480.8","122.2","25347","6303"`,
+}
+
+// captureLog returns the log output written until the end of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	out := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(out) })
+	return &buf
+}
+
+// fields returns the 17 fields of a BST line, in the order Encode returns them.
+func fields(line string) []string {
+	return strings.Split(strings.ReplaceAll(line, `"`, ""), ",")
+}
+
+func TestRoundTripCapturedLines(t *testing.T) {
+	logs := captureLog(t)
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	var file bytes.Buffer
+	w := NewWriter(&file, london)
+	r := NewReader(strings.NewReader(strings.Join(capturedLines, "\r\n")+"\r\n"), london)
+	for i, line := range capturedLines {
+		data, err := r.Read()
+		if err != nil {
+			t.Fatalf("Read line %d: %v", i+1, err)
+		}
+		want := fields(line)
+		if data.HexIdent != want[3] || data.Squawk != want[16] || data.Tags[models.TagCountry] != want[5] {
+			t.Errorf("line %d decoded as %s squawk %s country %q", i+1, data.HexIdent, data.Squawk, data.Tags[models.TagCountry])
+		}
+
+		got := Encode(data, london)
+		for j := range want {
+			if j == 15 {
+				continue // BaseStation's own decimal squawk is the octal digits read as hex
+			}
+			if got[j] != want[j] {
+				t.Errorf("line %d: %s encoded as %q, want %q", i+1, fieldNames[j], got[j], want[j])
+			}
+		}
+		if err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Read after the last line: %v, want io.EOF", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// What was written reads back to the same records.
+	first := NewReader(strings.NewReader(strings.Join(capturedLines, "\r\n")), london)
+	again := NewReader(&file, london)
+	for i := range capturedLines {
+		want, _ := first.Read()
+		got, err := again.Read()
+		if err != nil {
+			t.Fatalf("Read written line %d: %v", i+1, err)
+		}
+		if !got.Timestamp.Equal(want.Timestamp) {
+			t.Errorf("line %d: timestamp %s, want %s", i+1, got.Timestamp, want.Timestamp)
+		}
+		got.Timestamp, got.GeneratedTimestamp, got.LoggedTimestamp = want.Timestamp, want.GeneratedTimestamp, want.LoggedTimestamp
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("line %d read back as\n  %+v\nwant\n  %+v", i+1, got, want)
+		}
+	}
+	if logs.Len() > 0 {
+		t.Errorf("unexpected log output: %s", logs)
+	}
+}
+
+func TestEncodeSquawk(t *testing.T) {
+	tests := []struct {
+		squawk  string
+		decimal string
+	}{
+		{"7700", "4032"},
+		{"7600", "3968"},
+		{"7500", "3904"},
+		{"2216", "1166"},
+		{"0001", "1"},
+		{"7777", "4095"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.squawk, func(t *testing.T) {
+			fields := Encode(&models.AircraftData{Squawk: tt.squawk}, time.UTC)
+			if fields[15] != tt.decimal || fields[16] != tt.squawk {
+				t.Errorf("squawk %q encoded as %q, %q; want %q, %q", tt.squawk, fields[15], fields[16], tt.decimal, tt.squawk)
+			}
+		})
+	}
+}
+
+func TestDecodeSquawk(t *testing.T) {
+	tests := []struct {
+		name     string
+		decimal  string
+		octal    string
+		want     string
+		mismatch bool
+		errField int // index of the field that fails, or 0
+	}{
+		{"decimal", "4032", "7700", "7700", false, 0},
+		{"BaseStation decimal", "30464", "7700", "7700", false, 0},
+		{"octal only", "", "7700", "7700", false, 0},
+		{"short octal", "", "1", "0001", false, 0},
+		{"from decimal", "4032", "", "7700", false, 0},
+		{"from BaseStation decimal", "8726", "", "2216", false, 0},
+		{"none", "0", "0", "", false, 0},
+		{"mismatch keeps octal", "1234", "7700", "7700", true, 0},
+		{"invalid decimal", "x", "7700", "", false, 15},
+		{"decimal out of range", "65535", "", "", false, 15},
+		{"octal with 8s", "", "8888", "", false, 16},
+		{"octal with 8s and decimal", "4032", "7780", "", false, 16},
+		{"octal too long", "", "17777", "", false, 16},
+		{"octal not a number", "", "77a0", "", false, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := fields(capturedLines[0])
+			line[15], line[16] = tt.decimal, tt.octal
+			data, mismatch, err := decode(line, time.UTC)
+			if tt.errField != 0 {
+				var perr *parser.ParseError
+				if !errors.As(err, &perr) || perr.FieldNames()[0] != fieldNames[tt.errField] {
+					t.Fatalf("err = %v, want a ParseError for %s", err, fieldNames[tt.errField])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data.Squawk != tt.want {
+				t.Errorf("squawk = %q, want %q", data.Squawk, tt.want)
+			}
+			if mismatch != tt.mismatch {
+				t.Errorf("mismatch = %t, want %t", mismatch, tt.mismatch)
+			}
+		})
+	}
+}
+
+func TestReaderCountsSquawkMismatches(t *testing.T) {
+	mismatched := strings.Replace(capturedLines[1], `"25347","6303"`, `"1234","6303"`, 1)
+	logs := captureLog(t)
+	r := NewReader(strings.NewReader(strings.Join([]string{mismatched, capturedLines[0], mismatched}, "\r\n")), time.UTC)
+	for {
+		if _, err := r.Read(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := r.SquawkMismatches(); n != 2 {
+		t.Errorf("SquawkMismatches = %d, want 2", n)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("logged %q while reading, want nothing", logs.String())
+	}
+}
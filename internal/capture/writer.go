@@ -0,0 +1,202 @@
+// Package capture records the raw SBS-1 lines of a source into rotating
+// files, which can later be fed back to the collector with REPLAY_FILES.
+package capture
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// flushInterval bounds how long a written line may sit in memory.
+const flushInterval = time.Second
+
+// Writer appends lines to capture files named <source>-<UTC start time>.sbs
+// (.sbs.gz when compressed). A new file is started at every multiple of the
+// rotation interval and whenever the current one reaches its size limit.
+//
+// Buffered lines are flushed every flushInterval by a background goroutine,
+// as well as at rotation and on Close, so that a file still being written can
+// be read back even when the feed goes quiet. WriteLine and Close must not be
+// called concurrently with each other.
+type Writer struct {
+	dir      string
+	source   string
+	interval time.Duration // 0 disables time based rotation
+	maxBytes int64         // uncompressed; 0 disables size based rotation
+	compress bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	mu       sync.Mutex // guards the fields below against the flusher
+	file     *os.File
+	gz       *gzip.Writer
+	buf      *bufio.Writer
+	period   time.Time // start of the rotation period of the current file
+	size     int64
+	unsaved  bool  // lines were written since the last flush
+	flushErr error // error of the last background flush
+}
+
+// NewWriter returns a Writer for the lines of source, creating dir if needed.
+// No file is created until the first line is written.
+func NewWriter(dir, source string, interval time.Duration, maxBytes int64, compress bool) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	w := &Writer{
+		dir:      dir,
+		source:   sanitize(source),
+		interval: interval,
+		maxBytes: maxBytes,
+		compress: compress,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.flushLoop()
+	return w, nil
+}
+
+// WriteLine appends a line received at t. It also returns the error of a
+// failed background flush.
+func (w *Writer) WriteLine(text string, t time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.flushErr; err != nil {
+		return err
+	}
+	if w.file == nil || w.due(t) {
+		if err := w.rotate(t); err != nil {
+			return err
+		}
+	}
+	n, err := w.buf.WriteString(text + "\n")
+	w.size += int64(n)
+	w.unsaved = true
+	if err != nil {
+		return fmt.Errorf("failed to write capture: %w", err)
+	}
+	return nil
+}
+
+// Close stops the background flushes, then flushes and closes the current file.
+func (w *Writer) Close() error {
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closeFile()
+}
+
+// flushLoop flushes buffered lines every flushInterval until Close.
+func (w *Writer) flushLoop() {
+	defer close(w.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			if w.file != nil && w.unsaved && w.flushErr == nil {
+				w.flushErr = w.flush()
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// closeFile flushes and closes the current file.
+func (w *Writer) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.flush()
+	if w.gz != nil {
+		err = errors.Join(err, w.gz.Close())
+	}
+	err = errors.Join(err, w.file.Close())
+	w.file, w.gz, w.buf = nil, nil, nil
+	return err
+}
+
+// due reports whether a line received at t belongs in a new file.
+func (w *Writer) due(t time.Time) bool {
+	if w.maxBytes > 0 && w.size >= w.maxBytes {
+		return true
+	}
+	return w.interval > 0 && !t.Truncate(w.interval).Equal(w.period)
+}
+
+func (w *Writer) rotate(t time.Time) error {
+	if err := w.closeFile(); err != nil {
+		return fmt.Errorf("failed to close capture: %w", err)
+	}
+	ext := ".sbs"
+	if w.compress {
+		ext += ".gz"
+	}
+	stamp := t.UTC().Format("20060102T150405Z")
+	var file *os.File
+	var err error
+	for seq := 0; ; seq++ {
+		name := fmt.Sprintf("%s-%s%s", w.source, stamp, ext)
+		if seq > 0 {
+			name = fmt.Sprintf("%s-%s-%d%s", w.source, stamp, seq, ext)
+		}
+		file, err = os.OpenFile(filepath.Join(w.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if !errors.Is(err, os.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create capture: %w", err)
+	}
+
+	var out io.Writer = file
+	if w.compress {
+		w.gz = gzip.NewWriter(file)
+		out = w.gz
+	}
+	w.file, w.buf = file, bufio.NewWriter(out)
+	w.size = 0
+	if w.interval > 0 {
+		w.period = t.Truncate(w.interval)
+	}
+	return nil
+}
+
+// flush pushes buffered lines to disk, completing a gzip block so that a file
+// still being written can already be read back.
+func (w *Writer) flush() error {
+	w.unsaved = false
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write capture: %w", err)
+	}
+	if w.gz != nil {
+		if err := w.gz.Flush(); err != nil {
+			return fmt.Errorf("failed to write capture: %w", err)
+		}
+	}
+	return nil
+}
+
+// sanitize makes a source name safe to use in a file name.
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
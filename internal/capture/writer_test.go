@@ -0,0 +1,162 @@
+package capture
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 12, 59, 0, 0, time.UTC)
+
+// files returns the content of every capture file in dir by name, decompressing .gz files.
+func files(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make(map[string]string)
+	for _, e := range entries {
+		f, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(e.Name(), ".gz") {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		content, err := io.ReadAll(r)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[e.Name()] = string(content)
+	}
+	return contents
+}
+
+// partial returns what can be read back of the single capture file in dir
+// while it is still being written.
+func partial(t *testing.T, dir string) string {
+	t.Helper()
+	names, err := filepath.Glob(filepath.Join(dir, "rx-*"))
+	if err != nil || len(names) != 1 {
+		t.Fatalf("capture files %v, %v; want one", names, err)
+	}
+	content, err := os.ReadFile(names[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(names[0], ".gz") || len(content) == 0 {
+		return string(content)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return ""
+	}
+	content, _ = io.ReadAll(r) // no trailer until Close
+	return string(content)
+}
+
+func TestWriterRotation(t *testing.T) {
+	const line = "MSG,8,1,1,4CA2D6,1,,,,,,,,,,,,,,,,0" // 36 bytes with the newline
+	tests := []struct {
+		name     string
+		interval time.Duration
+		maxBytes int64
+		compress bool
+		writes   []time.Duration // receive times after start
+		want     map[string]int  // lines per file
+	}{
+		{"no rotation", 0, 0, false, []time.Duration{0, time.Minute, time.Hour},
+			map[string]int{"rx-20240101T125900Z.sbs": 3}},
+		{"by age", time.Hour, 0, false, []time.Duration{0, 59 * time.Second, time.Minute, time.Hour, time.Hour + time.Minute},
+			map[string]int{"rx-20240101T125900Z.sbs": 2, "rx-20240101T130000Z.sbs": 2, "rx-20240101T140000Z.sbs": 1}},
+		{"by size", 0, 72, false, []time.Duration{0, 0, 0, time.Second, time.Second},
+			map[string]int{"rx-20240101T125900Z.sbs": 2, "rx-20240101T125900Z-1.sbs": 2, "rx-20240101T125901Z.sbs": 1}},
+		{"by size below the limit", 0, 73, false, []time.Duration{0, 0, 0},
+			map[string]int{"rx-20240101T125900Z.sbs": 3}},
+		{"by age and size, compressed", time.Hour, 36, true, []time.Duration{0, 0, time.Minute},
+			map[string]int{"rx-20240101T125900Z.sbs.gz": 1, "rx-20240101T125900Z-1.sbs.gz": 1, "rx-20240101T130000Z.sbs.gz": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "captures")
+			w, err := NewWriter(dir, "rx", tt.interval, tt.maxBytes, tt.compress)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, at := range tt.writes {
+				if err := w.WriteLine(line, start.Add(at)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			got := files(t, dir)
+			if len(got) != len(tt.want) {
+				t.Errorf("files %v, want %v", slices.Sorted(maps.Keys(got)), tt.want)
+			}
+			for name, lines := range tt.want {
+				if content, ok := got[name]; !ok || content != strings.Repeat(line+"\n", lines) {
+					t.Errorf("%s = %q, want %d lines", name, content, lines)
+				}
+			}
+		})
+	}
+}
+
+func TestWriterFlushesInBackground(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		dir := t.TempDir()
+		w, err := NewWriter(dir, "rx", time.Hour, 0, compress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteLine("MSG,8,1,1,4CA2D6,1,,,,,,,,,,,,,,,,0", start); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(3 * flushInterval)
+		for {
+			content := partial(t, dir)
+			if content == "MSG,8,1,1,4CA2D6,1,,,,,,,,,,,,,,,,0\n" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("compressed %t: file holds %q after %s without further lines", compress, content, 3*flushInterval)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("second Close: %v", err)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"rx", "rx"},
+		{"roof-2_a.local", "roof-2_a.local"},
+		{"../etc/passwd", ".._etc_passwd"},
+		{"my receiver:30003", "my_receiver_30003"},
+		{"Côte", "C_te"},
+	}
+	for _, tt := range tests {
+		if got := sanitize(tt.in); got != tt.want {
+			t.Errorf("sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package clockskew
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// smoothing is the weight of a new sample in the moving average, so that
+	// network jitter and short queueing delays do not trigger a warning.
+	smoothing = 0.05
+	// warmupSamples is the number of samples a receiver needs before it can be flagged.
+	warmupSamples = 20
+)
+
+// Detector flags receivers whose clocks drift from the collector's. For every
+// message it compares the receiver's timestamp with the time the collector read
+// the line, and keeps a moving average of the difference per receiver.
+type Detector struct {
+	threshold time.Duration
+
+	mu        sync.Mutex
+	receivers map[string]*receiverSkew
+}
+
+type receiverSkew struct {
+	skew    float64 // seconds the receiver's clock is behind the collector's; negative when ahead
+	samples int
+	skewed  bool
+}
+
+// NewDetector creates a Detector that warns when a receiver's average skew exceeds threshold.
+func NewDetector(threshold time.Duration) *Detector {
+	return &Detector{
+		threshold: threshold,
+		receivers: make(map[string]*receiverSkew),
+	}
+}
+
+// Observe records one message from source, stamped receiverTime by the receiver
+// and read by the collector at collectorTime. Zero receiver times are ignored.
+func (d *Detector) Observe(source string, receiverTime, collectorTime time.Time) {
+	if receiverTime.IsZero() {
+		return
+	}
+	sample := collectorTime.Sub(receiverTime).Seconds()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r := d.receivers[source]
+	if r == nil {
+		r = &receiverSkew{skew: sample}
+		d.receivers[source] = r
+	} else {
+		r.skew += smoothing * (sample - r.skew)
+	}
+	r.samples++
+	if r.samples < warmupSamples {
+		return
+	}
+
+	skew := time.Duration(r.skew * float64(time.Second))
+	switch exceeded := skew.Abs() > d.threshold; {
+	case exceeded && !r.skewed:
+		r.skewed = true
+		log.Printf("[%s] Warning: receiver clock is %s %s the collector's.%s", source, skew.Abs().Round(time.Millisecond), direction(skew), hint(skew))
+	case !exceeded && r.skewed:
+		r.skewed = false
+		log.Printf("[%s] Receiver clock is back within %s of the collector's (skew %s).", source, d.threshold, skew.Round(time.Millisecond))
+	}
+}
+
+// Skews returns the current average skew of every receiver seen so far. A
+// positive skew means the receiver's timestamps are behind the collector's clock.
+func (d *Detector) Skews() map[string]time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	skews := make(map[string]time.Duration, len(d.receivers))
+	for source, r := range d.receivers {
+		skews[source] = time.Duration(r.skew * float64(time.Second))
+	}
+	return skews
+}
+
+func direction(skew time.Duration) string {
+	if skew > 0 {
+		return "behind"
+	}
+	return "ahead of"
+}
+
+// hint points at the time zone setting when the skew is close to a multiple of
+// a quarter hour, which is what a receiver in local time without RECEIVER_TIMEZONE looks like.
+func hint(skew time.Duration) string {
+	quarters := skew.Hours() * 4
+	if math.Abs(quarters) >= 2 && math.Abs(quarters-math.Round(quarters)) < 0.1 {
+		return " This looks like a time zone offset; check RECEIVER_TIMEZONE."
+	}
+	return ""
+}
@@ -0,0 +1,150 @@
+package clockskew
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureLog returns the log output written until the end of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	out, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(out)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+// observe feeds n messages from source whose receiver clock is skew behind
+// the collector's, one second apart.
+func observe(d *Detector, source string, skew time.Duration, n int) {
+	start := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	for i := range n {
+		collector := start.Add(time.Duration(i) * time.Second)
+		d.Observe(source, collector.Add(-skew), collector)
+	}
+}
+
+func TestDetectorWarmup(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples int
+		warned  bool
+	}{
+		{"one sample", 1, false},
+		{"just before warm-up", warmupSamples - 1, false},
+		{"warmed up", warmupSamples, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			d := NewDetector(5 * time.Second)
+			observe(d, "rx", time.Minute, tt.samples)
+			if warned := strings.Contains(logs.String(), "Warning"); warned != tt.warned {
+				t.Errorf("warned = %t, want %t; log: %q", warned, tt.warned, logs.String())
+			}
+			if got := d.Skews()["rx"]; got != time.Minute {
+				t.Errorf("skew = %s, want 1m0s", got)
+			}
+		})
+	}
+}
+
+func TestDetectorThreshold(t *testing.T) {
+	tests := []struct {
+		name    string
+		skew    time.Duration
+		warning string // expected in the log, "" for no warning
+	}{
+		{"within", 4 * time.Second, ""},
+		{"at the threshold", 5 * time.Second, ""},
+		{"behind", 6 * time.Second, "receiver clock is 6s behind the collector's."},
+		{"ahead", -6 * time.Second, "receiver clock is 6s ahead of the collector's."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			d := NewDetector(5 * time.Second)
+			observe(d, "rx", tt.skew, 2*warmupSamples)
+			got := logs.String()
+			if tt.warning == "" {
+				if got != "" {
+					t.Errorf("log = %q, want nothing", got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.warning) || strings.Count(got, "Warning") != 1 {
+				t.Errorf("log = %q, want a single warning %q", got, tt.warning)
+			}
+		})
+	}
+}
+
+func TestDetectorRecovers(t *testing.T) {
+	logs := captureLog(t)
+	d := NewDetector(5 * time.Second)
+	observe(d, "rx", time.Minute, warmupSamples)
+	if !d.receivers["rx"].skewed {
+		t.Fatal("not flagged after warm-up")
+	}
+	// The moving average takes a while to come back below the threshold.
+	observe(d, "rx", 0, 200)
+	if d.receivers["rx"].skewed {
+		t.Errorf("still flagged with a skew of %s", d.Skews()["rx"])
+	}
+	if !strings.Contains(logs.String(), "Receiver clock is back within 5s") {
+		t.Errorf("log = %q, want a recovery message", logs.String())
+	}
+}
+
+func TestDetectorSourcesAreIndependent(t *testing.T) {
+	captureLog(t)
+	d := NewDetector(5 * time.Second)
+	observe(d, "skewed", time.Hour, warmupSamples)
+	observe(d, "fine", 100*time.Millisecond, warmupSamples)
+	d.Observe("fine", time.Time{}, time.Now()) // ignored
+
+	if !d.receivers["skewed"].skewed || d.receivers["fine"].skewed {
+		t.Errorf("flagged: skewed %t, fine %t; want true, false", d.receivers["skewed"].skewed, d.receivers["fine"].skewed)
+	}
+	if n := d.receivers["fine"].samples; n != warmupSamples {
+		t.Errorf("fine has %d samples, want %d: zero receiver times must be ignored", n, warmupSamples)
+	}
+}
+
+func TestHint(t *testing.T) {
+	tests := []struct {
+		skew time.Duration
+		want bool
+	}{
+		{2 * time.Hour, true},
+		{-2 * time.Hour, true},
+		{5*time.Hour + 30*time.Minute, true}, // India
+		{5*time.Hour + 45*time.Minute, true}, // Nepal
+		{time.Hour + 2*time.Second, true},    // plus network delay
+		{30 * time.Minute, true},             // the smallest offset flagged
+		{15 * time.Minute, false},            // too close to a plain drift
+		{time.Hour + 7*time.Minute, false},   // not a quarter hour
+		{6 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.skew.String(), func(t *testing.T) {
+			if got := hint(tt.skew) != ""; got != tt.want {
+				t.Errorf("hint(%s) = %q, want a hint: %t", tt.skew, hint(tt.skew), tt.want)
+			}
+		})
+	}
+
+	logs := captureLog(t)
+	observe(NewDetector(5*time.Second), "rx", 2*time.Hour, warmupSamples)
+	if !strings.Contains(logs.String(), "check RECEIVER_TIMEZONE") {
+		t.Errorf("log = %q, want the time zone hint", logs.String())
+	}
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+const (
+	// slabSize is the number of values allocated at once for each pointer type.
+	// Small enough that a slab pinned by a single long-lived record stays cheap.
+	slabSize = 128
+	// internerSize bounds the number of distinct strings remembered; the table
+	// is cleared when it fills up, e.g. after a day of mostly one-off callsigns.
+	internerSize = 16384
+)
+
+var aircraftPool = sync.Pool{
+	New: func() any { return new(models.AircraftData) },
+}
+
+// acquire takes a zeroed record from the pool.
+func acquire() *models.AircraftData {
+	data := aircraftPool.Get().(*models.AircraftData)
+	*data = models.AircraftData{}
+	return data
+}
+
+// Release returns a record obtained from a Parser to the pool. The caller must
+// not use the pointer afterwards, but copies of the record stay valid: the
+// values its pointer fields refer to are never reused.
+func Release(data *models.AircraftData) {
+	if data != nil {
+		aircraftPool.Put(data)
+	}
+}
+
+// slab hands out the values behind the optional pointer fields of
+// AircraftData from larger blocks, so that decoding a line costs a fraction of
+// an allocation instead of one per field. Values are never handed out twice.
+type slab struct {
+	ints   []int
+	floats []float64
+	bools  []bool
+}
+
+func (s *slab) int(v int) *int {
+	if len(s.ints) == 0 {
+		s.ints = make([]int, slabSize)
+	}
+	p := &s.ints[0]
+	s.ints = s.ints[1:]
+	*p = v
+	return p
+}
+
+func (s *slab) float(v float64) *float64 {
+	if len(s.floats) == 0 {
+		s.floats = make([]float64, slabSize)
+	}
+	p := &s.floats[0]
+	s.floats = s.floats[1:]
+	*p = v
+	return p
+}
+
+func (s *slab) bool(v bool) *bool {
+	if len(s.bools) == 0 {
+		s.bools = make([]bool, slabSize)
+	}
+	p := &s.bools[0]
+	s.bools = s.bools[1:]
+	*p = v
+	return p
+}
+
+// interner deduplicates the short strings that repeat from message to message:
+// hex idents, callsigns, squawks and transmission types.
+type interner struct {
+	strings map[string]string
+}
+
+func newInterner() interner {
+	return interner{strings: make(map[string]string, 1024)}
+}
+
+// intern returns the canonical copy of s. The copy never refers to the line
+// s was sliced from, so records do not keep whole lines alive.
+func intern[T string | []byte](in *interner, s T) string {
+	if len(s) == 0 {
+		return ""
+	}
+	// Looking up string(s) does not allocate.
+	if v, ok := in.strings[string(s)]; ok {
+		return v
+	}
+	if len(in.strings) >= internerSize {
+		clear(in.strings)
+	}
+	v := strings.Clone(string(s))
+	in.strings[v] = v
+	return v
+}
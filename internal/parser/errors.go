@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidFlag is returned for a boolean field that is not -1, 1 or 0.
+	ErrInvalidFlag = errors.New("invalid flag, expected -1, 1 or 0")
+	// ErrInvalidTimestamp is returned for a date and time pair that is not
+	// "YYYY/MM/DD" and "HH:MM:SS" with optional fractional seconds.
+	ErrInvalidTimestamp = errors.New("invalid timestamp, expected YYYY/MM/DD HH:MM:SS.fff")
+	// ErrMissingTimestamp is returned when the timestamp selected as the point
+	// time is absent; the collector's receive time is used in its place.
+	ErrMissingTimestamp = errors.New("missing timestamp")
+)
+
+// FieldError describes a single SBS-1 field that could not be decoded.
+type FieldError struct {
+	Field int    // 1-based field number, as numbered in docs/sbs-bst-formats.md
+	Name  string // field name, e.g. "Altitude"
+	Value string // the raw value as received
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s (field %d) %q: %v", e.Name, e.Field, e.Value, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError collects every field of a single message that could not be decoded.
+// In strict mode it is returned instead of the record; in lenient mode the record
+// is kept without the offending fields and the ParseError is passed to OnFieldErrors.
+type ParseError struct {
+	Line     string
+	HexIdent string
+	Fields   []*FieldError
+}
+
+func (e *ParseError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	hex := e.HexIdent
+	if hex == "" {
+		hex = "unknown aircraft"
+	}
+	return fmt.Sprintf("invalid fields in message for %s: %s", hex, strings.Join(msgs, "; "))
+}
+
+// Unwrap allows errors.Is and errors.As to match any of the field errors.
+func (e *ParseError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, f := range e.Fields {
+		errs[i] = f
+	}
+	return errs
+}
+
+// FieldNames returns the names of the fields that failed to decode.
+func (e *ParseError) FieldNames() []string {
+	names := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		names[i] = f.Name
+	}
+	return names
+}
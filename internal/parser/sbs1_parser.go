@@ -4,160 +4,217 @@ import (
 	"fmt"
 	"github.com/m03315/go-dump1090-timeseries-collector/models"
 	"log"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 )
 
-// ParseSBS1Message decodes a raw dump1090 message string into an AircraftData struct.
-// Returns (nil, nil) for non-"MSG" message types.
-func ParseSBS1Message(line string) (*models.AircraftData, error) { // Returns models.AircraftData
-	fields := strings.Split(strings.TrimSpace(line), ",")
+// sbs1FieldNames names the SBS-1 fields by their 0-based index in the line.
+var sbs1FieldNames = [sbs1MaxFields]string{
+	"Message Type", "Transmission Type", "Session ID", "Aircraft ID", "HexIdent", "Flight ID",
+	"Generated Timestamp", "Time Generated", "Logged Timestamp", "Time Logged",
+	"Callsign", "Altitude", "GroundSpeed", "Track", "Latitude", "Longitude", "VerticalRate",
+	"Squawk", "Alert", "Emergency", "SPI", "IsOnGround",
+}
 
-	if len(fields) < 10 {
-		return nil, fmt.Errorf("message too short, expected at least 10 fields for basic data: '%s'", line)
-	}
+// TimestampSource selects which timestamp becomes a record's point time.
+type TimestampSource string
 
-	msgType := strings.TrimSpace(fields[0])
-	if msgType != "MSG" {
-		return nil, nil // Not an aircraft data message we're interested in, skip silently
-	}
+const (
+	// TimestampGenerated uses the time the receiver heard the message (fields 7 and 8).
+	TimestampGenerated TimestampSource = "generated"
+	// TimestampLogged uses the time the receiver logged the message (fields 9 and 10).
+	TimestampLogged TimestampSource = "logged"
+	// TimestampReceived uses the time the collector read the line.
+	TimestampReceived TimestampSource = "received"
+)
 
-	var dateMsgGen, timeMsgGen, dateMsgLog, timeMsgLog string
-	if len(fields) > 6 {
-		dateMsgGen = strings.TrimSpace(fields[6])
-	}
-	if len(fields) > 7 {
-		timeMsgGen = strings.TrimSpace(fields[7])
-	}
-	if len(fields) > 8 {
-		dateMsgLog = strings.TrimSpace(fields[8])
-	}
-	if len(fields) > 9 {
-		timeMsgLog = strings.TrimSpace(fields[9])
-	}
+// Options configures a Parser.
+type Options struct {
+	// Location is the time zone of the receiver's clock. dump1090 writes its
+	// timestamps in local time without an offset. Defaults to UTC.
+	Location *time.Location
+	// TimestampSource selects the point time. Defaults to TimestampGenerated.
+	TimestampSource TimestampSource
 
-	var generatedTimestamp time.Time
-	if dateMsgGen != "" && timeMsgGen != "" {
-		parsedTime, err := time.Parse("2006/01/02 15:04:05", fmt.Sprintf("%s %s", dateMsgGen, timeMsgGen))
-		if err != nil {
-			log.Printf("Warning: Could not parse Generated Timestamp (Fields 7&8) '%s %s': %v. Using current time.", dateMsgGen, timeMsgGen, err)
-			generatedTimestamp = time.Now()
-		} else {
-			generatedTimestamp = parsedTime
-		}
-	} else {
-		generatedTimestamp = time.Now() // Fallback
-	}
+	// Strict rejects a message as soon as any of its fields fails to decode,
+	// returning a *ParseError instead of the record.
+	Strict bool
+	// OnFieldErrors is called in lenient mode for messages that were accepted
+	// with some fields dropped. It defaults to logging a warning.
+	OnFieldErrors func(*ParseError)
+}
 
-	var loggedTimestamp time.Time
-	if dateMsgLog != "" && timeMsgLog != "" {
-		parsedTime, err := time.Parse("2006/01/02 15:04:05", fmt.Sprintf("%s %s", dateMsgLog, timeMsgLog))
-		if err != nil {
-			log.Printf("Warning: Could not parse Logged Timestamp (Fields 9&10) '%s %s': %v. Using current time.", dateMsgLog, timeMsgLog, err)
-			loggedTimestamp = time.Now()
-		} else {
-			loggedTimestamp = parsedTime
-		}
-	} else {
-		loggedTimestamp = time.Now() // Fallback
-	}
+// Parser decodes SBS-1 lines. A Parser is safe for concurrent use.
+//
+// Decoding does not allocate for well-formed lines: fields are scanned in
+// place, the values behind the optional pointer fields are carved out of
+// shared slabs, repeated strings such as hex idents and callsigns are
+// interned, and records are taken from a pool (see Release).
+type Parser struct {
+	opts Options
 
-	data := &models.AircraftData{ // Create a models.AircraftData struct
-		MessageType:        msgType,
-		HexIdent:           strings.TrimSpace(fields[4]),
-		GeneratedTimestamp: generatedTimestamp,
-		LoggedTimestamp:    loggedTimestamp,
-	}
+	mu      sync.Mutex // guards values and strings
+	values  slab
+	strings interner
+}
 
-	if len(fields) > 1 && fields[1] != "" {
-		data.TransmissionType = strings.TrimSpace(fields[1])
-	}
-	if len(fields) > 2 && fields[2] != "" {
-		if val, err := strconv.Atoi(strings.TrimSpace(fields[2])); err == nil {
-			data.SessionID = &val
-		} else {
-			log.Printf("Warning: Could not parse SessionID '%s': %v", fields[2], err)
-		}
+// NewParser creates a Parser with the given options.
+func NewParser(opts Options) *Parser {
+	if opts.Location == nil {
+		opts.Location = time.UTC
 	}
-	if len(fields) > 3 && fields[3] != "" {
-		if val, err := strconv.Atoi(strings.TrimSpace(fields[3])); err == nil {
-			data.AircraftID = &val
-		} else {
-			log.Printf("Warning: Could not parse AircraftID '%s': %v", fields[3], err)
-		}
+	if opts.TimestampSource == "" {
+		opts.TimestampSource = TimestampGenerated
 	}
-	if len(fields) > 5 && fields[5] != "" {
-		if val, err := strconv.Atoi(strings.TrimSpace(fields[5])); err == nil {
-			data.FlightID = &val
-		} else {
-			log.Printf("Warning: Could not parse FlightID '%s': %v", fields[5], err)
+	if opts.OnFieldErrors == nil {
+		opts.OnFieldErrors = func(err *ParseError) {
+			log.Printf("Warning: %v", err)
 		}
 	}
-	if len(fields) > 10 && fields[10] != "" {
-		data.Callsign = strings.TrimSpace(fields[10])
-	}
-	if len(fields) > 11 && fields[11] != "" {
-		if val, err := strconv.Atoi(strings.TrimSpace(fields[11])); err == nil {
-			data.Altitude = &val
-		} else {
-			log.Printf("Warning: Could not parse Altitude '%s' for HexIdent %s: %v", fields[11], data.HexIdent, err)
-		}
+	return &Parser{opts: opts, strings: newInterner()}
+}
+
+var defaultParser = NewParser(Options{})
+
+// ParseSBS1Message decodes a raw dump1090 message string into an AircraftData struct
+// using a lenient parser that logs and drops fields it cannot decode, and treats
+// the timestamps as UTC.
+func ParseSBS1Message(line string) (*models.AircraftData, error) { // Returns models.AircraftData
+	return defaultParser.Parse(line, time.Now())
+}
+
+// Parse decodes a raw dump1090 message string, read by the collector at
+// received, into an AircraftData struct.
+// MSG lines carry the aircraft's transmitted data; AIR, ID, STA, SEL and CLK lines
+// are returned as events with only the header fields and, for ID/SEL/CLK the
+// callsign or for STA the status, populated.
+// Returns (nil, nil) for unknown message types.
+//
+// The record is taken from a pool. Callers that are done with it may return it
+// with Release; copies of the record remain valid after that.
+func (p *Parser) Parse(line string, received time.Time) (*models.AircraftData, error) {
+	return parse(p, line, received)
+}
+
+// ParseBytes is like Parse but reads the line from a byte slice. The record
+// does not refer to line, so the caller may reuse the buffer straight away.
+func (p *Parser) ParseBytes(line []byte, received time.Time) (*models.AircraftData, error) {
+	return parse(p, line, received)
+}
+
+func parse[T string | []byte](p *Parser, line T, received time.Time) (*models.AircraftData, error) {
+	var f fieldScanner[T]
+	f.scan(line)
+
+	if f.n < 10 {
+		return nil, fmt.Errorf("message too short, expected at least 10 fields for basic data: '%s'", line)
 	}
-	if len(fields) > 12 && fields[12] != "" {
-		if val, err := strconv.ParseFloat(strings.TrimSpace(fields[12]), 64); err == nil {
-			data.GroundSpeed = &val
-		} else {
-			log.Printf("Warning: Could not parse GroundSpeed '%s' for HexIdent %s: %v", fields[12], data.HexIdent, err)
-		}
+
+	msgType := messageType(f.text(0))
+	if msgType == "" {
+		return nil, nil // Not a message type we know about, skip silently
 	}
-	if len(fields) > 13 && fields[13] != "" {
-		if val, err := strconv.ParseFloat(strings.TrimSpace(fields[13]), 64); err == nil {
-			data.Track = &val
-		} else {
-			log.Printf("Warning: Could not parse Track '%s' for HexIdent %s: %v", fields[13], data.HexIdent, err)
-		}
+
+	p.mu.Lock()
+	f.loc, f.values, f.strings = p.opts.Location, &p.values, &p.strings
+
+	data := acquire()
+	data.MessageType = msgType
+	data.TransmissionType = f.str(1)
+	data.SessionID = f.int(2)
+	data.AircraftID = f.int(3)
+	data.HexIdent = f.str(4)
+	data.FlightID = f.int(5)
+	data.GeneratedTimestamp = f.timestamp(6)
+	data.LoggedTimestamp = f.timestamp(8)
+	data.ReceivedTimestamp = received
+	if missing := p.selectTimestamp(data); missing >= 0 && !f.failed(missing) {
+		f.fail(missing, "", ErrMissingTimestamp)
 	}
-	if len(fields) > 14 && fields[14] != "" {
-		if val, err := strconv.ParseFloat(strings.TrimSpace(fields[14]), 64); err == nil {
-			data.Latitude = &val
+
+	if msgType != models.MessageTypeTransmission {
+		// Event messages carry at most one data field after the header.
+		if msgType == models.MessageTypeStatus {
+			data.Status = f.status(10)
 		} else {
-			log.Printf("Warning: Could not parse Latitude '%s' for HexIdent %s: %v", fields[14], data.HexIdent, err)
+			data.Callsign = f.callsign(10)
 		}
+	} else {
+		data.Callsign = f.callsign(10)
+		data.Altitude = f.int(11)
+		data.GroundSpeed = f.float(12)
+		data.Track = f.float(13)
+		data.Latitude = f.float(14)
+		data.Longitude = f.float(15)
+		data.VerticalRate = f.int(16)
+		data.Squawk = f.str(17)
+		data.Alert = f.flag(18)
+		data.Emergency = f.flag(19)
+		data.SPI = f.flag(20)
+		data.IsOnGround = f.flag(21)
+	}
+	p.mu.Unlock()
+
+	if len(f.errs) == 0 {
+		return data, nil
 	}
-	if len(fields) > 15 && fields[15] != "" {
-		if val, err := strconv.ParseFloat(strings.TrimSpace(fields[15]), 64); err == nil {
-			data.Longitude = &val
-		} else {
-			log.Printf("Warning: Could not parse Longitude '%s' for HexIdent %s: %v", fields[15], data.HexIdent, err)
+	return p.finish(string(line), data, f.errs)
+}
+
+// messageType maps the first field to the corresponding constant, or "" for
+// message types we do not know about.
+func messageType[T string | []byte](s T) string {
+	switch string(s) {
+	case models.MessageTypeTransmission:
+		return models.MessageTypeTransmission
+	case models.MessageTypeNewAircraft:
+		return models.MessageTypeNewAircraft
+	case models.MessageTypeNewID:
+		return models.MessageTypeNewID
+	case models.MessageTypeStatus:
+		return models.MessageTypeStatus
+	case models.MessageTypeSelection:
+		return models.MessageTypeSelection
+	case models.MessageTypeClick:
+		return models.MessageTypeClick
+	}
+	return ""
+}
+
+// selectTimestamp sets the record's point time from the configured source. A
+// missing receiver timestamp is replaced by the collector's receive time rather
+// than silently becoming the current time, and the index of the missing field
+// is returned so that it can be reported; otherwise it returns -1.
+func (p *Parser) selectTimestamp(data *models.AircraftData) int {
+	missing := -1
+	switch p.opts.TimestampSource {
+	case TimestampReceived:
+		data.Timestamp = data.ReceivedTimestamp
+		return missing
+	case TimestampLogged:
+		data.Timestamp = data.LoggedTimestamp
+		if data.Timestamp.IsZero() {
+			missing = 8
 		}
-	}
-	if len(fields) > 16 && fields[16] != "" {
-		if val, err := strconv.Atoi(strings.TrimSpace(fields[16])); err == nil {
-			data.VerticalRate = &val
-		} else {
-			log.Printf("Warning: Could not parse VerticalRate '%s' for HexIdent %s: %v", fields[16], data.HexIdent, err)
+	default:
+		data.Timestamp = data.GeneratedTimestamp
+		if data.Timestamp.IsZero() {
+			missing = 6
 		}
 	}
-	if len(fields) > 17 && fields[17] != "" {
-		data.Squawk = strings.TrimSpace(fields[17])
-	}
-	if len(fields) > 18 && fields[18] != "" {
-		val := strings.TrimSpace(fields[18]) == "1"
-		data.Alert = &val
-	}
-	if len(fields) > 19 && fields[19] != "" {
-		val := strings.TrimSpace(fields[19]) == "1"
-		data.Emergency = &val
-	}
-	if len(fields) > 20 && fields[20] != "" {
-		val := strings.TrimSpace(fields[20]) == "1"
-		data.SPI = &val
-	}
-	if len(fields) > 21 && fields[21] != "" {
-		val := strings.TrimSpace(fields[21]) == "1"
-		data.IsOnGround = &val
+	if data.Timestamp.IsZero() {
+		data.Timestamp = data.ReceivedTimestamp
 	}
+	return missing
+}
 
+// finish applies the strict/lenient policy to the field errors of a decoded message.
+func (p *Parser) finish(line string, data *models.AircraftData, errs []*FieldError) (*models.AircraftData, error) {
+	perr := &ParseError{Line: line, HexIdent: data.HexIdent, Fields: errs}
+	if p.opts.Strict {
+		Release(data)
+		return nil, perr
+	}
+	p.opts.OnFieldErrors(perr)
 	return data, nil
 }
@@ -0,0 +1,324 @@
+package parser_test
+
+import (
+	"bufio"
+	"flag"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/parser"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// benchInput replaces the built-in sample lines, to benchmark captured traffic:
+//
+//	go test -run '^$' -bench . ./internal/parser -args -input capture.sbs
+var benchInput = flag.String("input", "", "file of SBS-1 lines to benchmark instead of the built-in sample")
+
+// sampleLines is a representative mix of dump1090 output.
+var sampleLines = []string{
+	"MSG,1,111,11111,4CA2D6,111111,2008/11/28,14:53:49.986,2008/11/28,14:58:51.153,RYR1427 ,,,,,,,,,,,0",
+	"MSG,3,111,11111,4CA2D6,111111,2008/11/28,14:53:50.594,2008/11/28,14:58:51.153,,37000,,,51.45735,-1.02826,,,0,0,0,0",
+	"MSG,4,111,11111,4CA2D6,111111,2008/11/28,14:53:49.986,2008/11/28,14:58:51.153,,,408.3,146.4,,,64,,,,,",
+	"MSG,5,111,11111,400AE7,111111,2008/11/28,14:58:51.153,2008/11/28,14:58:51.153,,36975,,,,,,,-1,0,0,0",
+	"MSG,6,111,11111,400AE7,111111,2008/11/28,14:58:51.153,2008/11/28,14:58:51.153,,,,,,,,7700,-1,-1,0,0",
+	"MSG,7,111,11111,3C6DD1,111111,2008/11/28,14:58:51.153,2008/11/28,14:58:51.153,,12500,,,,,,,,,,0",
+	"MSG,8,111,11111,3C6DD1,111111,2008/11/28,14:58:51.153,2008/11/28,14:58:51.153,,,,,,,,,,,,0",
+	"STA,,5,179,400AE7,10103,2008/11/28,14:58:51.153,2008/11/28,14:58:51.153,RM",
+	"AIR,,333,1,4CA2D6,1,2008/11/28,14:53:49.986,2008/11/28,14:53:49.986,",
+	"ID,,333,7,4CA2D6,7,2008/11/28,14:53:49.986,2008/11/28,14:53:49.986,RYR1427@",
+}
+
+// benchLines returns the lines to benchmark: those of -input, or the sample.
+func benchLines(tb testing.TB) []string {
+	tb.Helper()
+	if *benchInput == "" {
+		return sampleLines
+	}
+	file, err := os.Open(*benchInput)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		tb.Fatal(err)
+	}
+	if len(lines) == 0 {
+		tb.Fatalf("no lines in %s", *benchInput)
+	}
+	return lines
+}
+
+// quietParser returns a lenient parser that does not log field errors.
+func quietParser() *parser.Parser {
+	return parser.NewParser(parser.Options{OnFieldErrors: func(*parser.ParseError) {}})
+}
+
+func BenchmarkParse(b *testing.B) {
+	lines := benchLines(b)
+	p := quietParser()
+	received := time.Now()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, _ := p.Parse(lines[i%len(lines)], received)
+		parser.Release(data)
+	}
+}
+
+func BenchmarkParseBytes(b *testing.B) {
+	lines := benchLines(b)
+	byteLines := make([][]byte, len(lines))
+	for i, line := range lines {
+		byteLines[i] = []byte(line)
+	}
+	p := quietParser()
+	received := time.Now()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, _ := p.ParseBytes(byteLines[i%len(byteLines)], received)
+		parser.Release(data)
+	}
+}
+
+func BenchmarkParseBaseline(b *testing.B) {
+	lines := benchLines(b)
+	received := time.Now()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = baselineParse(lines[i%len(lines)], time.UTC, received)
+	}
+}
+
+// TestParseDoesNotAllocate checks that decoding well-formed lines and
+// releasing the records does not allocate, once the interned strings are
+// known. The slabs allocate once per many values, which averages to zero.
+func TestParseDoesNotAllocate(t *testing.T) {
+	if testing.CoverMode() != "" || raceEnabled {
+		t.Skip("instrumented builds allocate")
+	}
+	p := quietParser()
+	received := time.Now()
+	for _, line := range sampleLines {
+		data, err := p.Parse(line, received)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", line, err)
+		}
+		parser.Release(data)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		parse func(i int) (*models.AircraftData, error)
+	}{
+		{"Parse", func(i int) (*models.AircraftData, error) {
+			return p.Parse(sampleLines[i%len(sampleLines)], received)
+		}},
+		{"ParseBytes", func() func(int) (*models.AircraftData, error) {
+			byteLines := make([][]byte, len(sampleLines))
+			for i, line := range sampleLines {
+				byteLines[i] = []byte(line)
+			}
+			return func(i int) (*models.AircraftData, error) {
+				return p.ParseBytes(byteLines[i%len(byteLines)], received)
+			}
+		}()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			i := 0
+			allocs := testing.AllocsPerRun(1000, func() {
+				data, _ := tt.parse(i)
+				parser.Release(data)
+				i++
+			})
+			if allocs != 0 {
+				t.Errorf("%s allocates %.0f times per line, want 0", tt.name, allocs)
+			}
+		})
+	}
+}
+
+// TestParseMatchesBaseline checks that the parser decodes the same records as
+// the strings.Split based parser it replaced.
+func TestParseMatchesBaseline(t *testing.T) {
+	p := quietParser()
+	received := time.Now()
+	for _, line := range benchLines(t) {
+		want, wantErr := baselineParse(line, time.UTC, received)
+		got, gotErr := p.Parse(line, received)
+		if (wantErr != nil) != (gotErr != nil) || !sameRecord(want, got) {
+			t.Errorf("Parse(%q):\n  baseline: %+v (err %v)\n  parser:   %+v (err %v)", line, want, wantErr, got, gotErr)
+		}
+	}
+}
+
+// sameRecord compares two records field by field, following pointers and
+// comparing timestamps as instants.
+func sameRecord(a, b *models.AircraftData) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	x, y := *a, *b
+	for _, ts := range [][2]*time.Time{
+		{&x.GeneratedTimestamp, &y.GeneratedTimestamp},
+		{&x.LoggedTimestamp, &y.LoggedTimestamp},
+		{&x.ReceivedTimestamp, &y.ReceivedTimestamp},
+		{&x.Timestamp, &y.Timestamp},
+	} {
+		if !ts[0].Equal(*ts[1]) {
+			return false
+		}
+		*ts[0], *ts[1] = time.Time{}, time.Time{}
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// baselineParse is the strings.Split based SBS-1 parser that Parser replaced,
+// kept unchanged in behaviour as the reference the benchmarks compare
+// against. It decodes in lenient mode and uses the generated timestamp.
+func baselineParse(line string, loc *time.Location, received time.Time) (*models.AircraftData, error) {
+	fields := strings.Split(strings.TrimSpace(line), ",")
+
+	if len(fields) < 10 {
+		return nil, strconv.ErrSyntax
+	}
+
+	msgType := strings.TrimSpace(fields[0])
+	switch msgType {
+	case models.MessageTypeTransmission, models.MessageTypeNewAircraft, models.MessageTypeNewID,
+		models.MessageTypeStatus, models.MessageTypeSelection, models.MessageTypeClick:
+	default:
+		return nil, nil
+	}
+
+	f := &baselineDecoder{fields: fields, loc: loc}
+
+	data := &models.AircraftData{
+		MessageType:        msgType,
+		TransmissionType:   f.text(1),
+		SessionID:          f.int(2),
+		AircraftID:         f.int(3),
+		HexIdent:           f.text(4),
+		FlightID:           f.int(5),
+		GeneratedTimestamp: f.timestamp(6),
+		LoggedTimestamp:    f.timestamp(8),
+		ReceivedTimestamp:  received,
+	}
+	data.Timestamp = data.GeneratedTimestamp
+	if data.Timestamp.IsZero() {
+		data.Timestamp = received
+	}
+
+	if msgType != models.MessageTypeTransmission {
+		if msgType == models.MessageTypeStatus {
+			data.Status = strings.ToUpper(f.text(10))
+		} else {
+			data.Callsign = f.callsign(10)
+		}
+		return data, nil
+	}
+
+	data.Callsign = f.callsign(10)
+	data.Altitude = f.int(11)
+	data.GroundSpeed = f.float(12)
+	data.Track = f.float(13)
+	data.Latitude = f.float(14)
+	data.Longitude = f.float(15)
+	data.VerticalRate = f.int(16)
+	data.Squawk = f.text(17)
+	data.Alert = f.flag(18)
+	data.Emergency = f.flag(19)
+	data.SPI = f.flag(20)
+	data.IsOnGround = f.flag(21)
+
+	return data, nil
+}
+
+type baselineDecoder struct {
+	fields []string
+	loc    *time.Location
+	errs   []*parser.FieldError
+}
+
+func (f *baselineDecoder) text(i int) string {
+	if i >= len(f.fields) {
+		return ""
+	}
+	return strings.TrimSpace(f.fields[i])
+}
+
+func (f *baselineDecoder) fail(i int, value string, err error) {
+	f.errs = append(f.errs, &parser.FieldError{Field: i + 1, Value: value, Err: err})
+}
+
+func (f *baselineDecoder) int(i int) *int {
+	s := f.text(i)
+	if s == "" {
+		return nil
+	}
+	val, err := strconv.Atoi(s)
+	if err != nil {
+		f.fail(i, s, err)
+		return nil
+	}
+	return &val
+}
+
+func (f *baselineDecoder) float(i int) *float64 {
+	s := f.text(i)
+	if s == "" {
+		return nil
+	}
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		f.fail(i, s, err)
+		return nil
+	}
+	return &val
+}
+
+func (f *baselineDecoder) flag(i int) *bool {
+	s := f.text(i)
+	var val bool
+	switch s {
+	case "":
+		return nil
+	case "-1", "1":
+		val = true
+	case "0":
+		val = false
+	default:
+		f.fail(i, s, parser.ErrInvalidFlag)
+		return nil
+	}
+	return &val
+}
+
+func (f *baselineDecoder) callsign(i int) string {
+	return strings.TrimRight(f.text(i), "@ ")
+}
+
+func (f *baselineDecoder) timestamp(i int) time.Time {
+	date, clock := f.text(i), f.text(i+1)
+	if date == "" || clock == "" {
+		return time.Time{}
+	}
+	value := date + " " + clock
+	parsedTime, err := time.ParseInLocation("2006/01/02 15:04:05.999", value, f.loc)
+	if err != nil {
+		f.fail(i, value, err)
+		return time.Time{}
+	}
+	return parsedTime
+}
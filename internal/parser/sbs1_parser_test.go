@@ -0,0 +1,365 @@
+package parser_test
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/parser"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// line builds an MSG,3 line with the given generated and logged date and time fields.
+func line(genDate, genTime, logDate, logTime string) string {
+	return "MSG,3,1,1,4CA2D6,1," + genDate + "," + genTime + "," + logDate + "," + logTime + ",,37000,,,51.45735,-1.02826,,,0,0,0,0"
+}
+
+func TestParseTimestampTimeZone(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	tests := []struct {
+		name     string
+		location *time.Location
+		date     string
+		clock    string
+		want     time.Time
+	}{
+		{"UTC by default", nil, "2024/07/01", "14:00:00.123", time.Date(2024, 7, 1, 14, 0, 0, 123e6, time.UTC)},
+		{"summer time", paris, "2024/07/01", "14:00:00.123", time.Date(2024, 7, 1, 12, 0, 0, 123e6, time.UTC)},
+		{"winter time", paris, "2024/01/15", "00:30:00.000", time.Date(2024, 1, 14, 23, 30, 0, 0, time.UTC)},
+		{"fixed offset", time.FixedZone("UTC-5", -5*3600), "2024/12/31", "22:00:00.500", time.Date(2025, 1, 1, 3, 0, 0, 500e6, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.NewParser(parser.Options{Location: tt.location, Strict: true})
+			data, err := p.Parse(line(tt.date, tt.clock, tt.date, tt.clock), time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !data.Timestamp.Equal(tt.want) {
+				t.Errorf("Timestamp = %s, want %s", data.Timestamp.UTC(), tt.want)
+			}
+			if !data.LoggedTimestamp.Equal(tt.want) {
+				t.Errorf("LoggedTimestamp = %s, want %s", data.LoggedTimestamp.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimestampFraction(t *testing.T) {
+	tests := []struct {
+		clock string
+		nsec  int
+		back  string // the clock formatted back with milliseconds
+	}{
+		{"14:53:49.986", 986e6, "14:53:49.986"},
+		{"14:53:49.001", 1e6, "14:53:49.001"},
+		{"14:53:49.9", 900e6, "14:53:49.900"},
+		{"14:53:49.98", 980e6, "14:53:49.980"},
+		{"14:53:49.123456", 123456e3, "14:53:49.123"},
+		{"14:53:49.123456789", 123456789, "14:53:49.123"},
+		{"14:53:49", 0, "14:53:49.000"},
+	}
+	p := parser.NewParser(parser.Options{Strict: true})
+	for _, tt := range tests {
+		t.Run(tt.clock, func(t *testing.T) {
+			data, err := p.Parse(line("2008/11/28", tt.clock, "2008/11/28", tt.clock), time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := data.Timestamp.Nanosecond(); got != tt.nsec {
+				t.Errorf("nanoseconds = %d, want %d", got, tt.nsec)
+			}
+			if got := data.Timestamp.Format("15:04:05.000"); got != tt.back {
+				t.Errorf("formatted back as %s, want %s", got, tt.back)
+			}
+		})
+	}
+}
+
+func TestParseInvalidTimestamp(t *testing.T) {
+	for _, clock := range []string{"14:53", "14-53-49.986", "24:00:00", "14:60:00", "14:53:49.", "14:53:49,986", "14:53:49.98x", "14:53:49.1234567890"} {
+		t.Run(clock, func(t *testing.T) {
+			p := parser.NewParser(parser.Options{Strict: true})
+			_, err := p.Parse(line("2008/11/28", clock, "2008/11/28", "14:53:49.986"), time.Now())
+			if !errors.Is(err, parser.ErrInvalidTimestamp) {
+				t.Errorf("err = %v, want ErrInvalidTimestamp", err)
+			}
+		})
+	}
+	for _, date := range []string{"2008/02/30", "2008/13/01", "2008-11-28", "08/11/28"} {
+		t.Run(date, func(t *testing.T) {
+			p := parser.NewParser(parser.Options{Strict: true})
+			_, err := p.Parse(line(date, "14:53:49.986", "2008/11/28", "14:53:49.986"), time.Now())
+			if !errors.Is(err, parser.ErrInvalidTimestamp) {
+				t.Errorf("err = %v, want ErrInvalidTimestamp", err)
+			}
+		})
+	}
+}
+
+func TestParseTimestampSource(t *testing.T) {
+	generated := time.Date(2008, 11, 28, 14, 53, 49, 986e6, time.UTC)
+	logged := time.Date(2008, 11, 28, 14, 58, 51, 153e6, time.UTC)
+	received := time.Date(2008, 11, 28, 14, 58, 52, 0, time.UTC)
+	full := line("2008/11/28", "14:53:49.986", "2008/11/28", "14:58:51.153")
+	noGenerated := line("", "", "2008/11/28", "14:58:51.153")
+	noLogged := line("2008/11/28", "14:53:49.986", "", "")
+	none := line("", "", "", "")
+
+	tests := []struct {
+		name    string
+		source  parser.TimestampSource
+		line    string
+		want    time.Time
+		missing string // name of the field reported missing, if any
+	}{
+		{"default", "", full, generated, ""},
+		{"generated", parser.TimestampGenerated, full, generated, ""},
+		{"logged", parser.TimestampLogged, full, logged, ""},
+		{"received", parser.TimestampReceived, full, received, ""},
+		{"generated missing", parser.TimestampGenerated, noGenerated, received, "Generated Timestamp"},
+		{"logged missing", parser.TimestampLogged, noLogged, received, "Logged Timestamp"},
+		{"other than generated missing", parser.TimestampGenerated, noLogged, generated, ""},
+		{"other than logged missing", parser.TimestampLogged, noGenerated, logged, ""},
+		{"received with none", parser.TimestampReceived, none, received, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reported *parser.ParseError
+			lenient := parser.NewParser(parser.Options{
+				TimestampSource: tt.source,
+				OnFieldErrors:   func(err *parser.ParseError) { reported = err },
+			})
+			data, err := lenient.Parse(tt.line, received)
+			if err != nil {
+				t.Fatalf("lenient: %v", err)
+			}
+			if !data.Timestamp.Equal(tt.want) {
+				t.Errorf("Timestamp = %s, want %s", data.Timestamp, tt.want)
+			}
+			if !data.ReceivedTimestamp.Equal(received) {
+				t.Errorf("ReceivedTimestamp = %s, want %s", data.ReceivedTimestamp, received)
+			}
+
+			strict := parser.NewParser(parser.Options{TimestampSource: tt.source, Strict: true})
+			_, strictErr := strict.Parse(tt.line, received)
+
+			if tt.missing == "" {
+				if reported != nil {
+					t.Errorf("lenient reported %v, want nothing", reported)
+				}
+				if strictErr != nil {
+					t.Errorf("strict: %v, want no error", strictErr)
+				}
+				return
+			}
+			if !errors.Is(reported, parser.ErrMissingTimestamp) {
+				t.Errorf("lenient reported %v, want ErrMissingTimestamp", reported)
+			} else if names := reported.FieldNames(); len(names) != 1 || names[0] != tt.missing {
+				t.Errorf("lenient reported fields %v, want [%s]", names, tt.missing)
+			}
+			if !errors.Is(strictErr, parser.ErrMissingTimestamp) {
+				t.Errorf("strict: %v, want ErrMissingTimestamp", strictErr)
+			}
+		})
+	}
+}
+
+func TestParseInvalidTimestampNotReportedMissing(t *testing.T) {
+	var reported *parser.ParseError
+	p := parser.NewParser(parser.Options{OnFieldErrors: func(err *parser.ParseError) { reported = err }})
+	received := time.Date(2008, 11, 28, 15, 0, 0, 0, time.UTC)
+	data, err := p.Parse(line("2008/11/28", "25:00:00", "2008/11/28", "14:58:51.153"), received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !data.Timestamp.Equal(received) {
+		t.Errorf("Timestamp = %s, want the receive time %s", data.Timestamp, received)
+	}
+	if reported == nil || len(reported.Fields) != 1 {
+		t.Fatalf("reported %v, want a single field error", reported)
+	}
+	if !errors.Is(reported, parser.ErrInvalidTimestamp) || errors.Is(reported, parser.ErrMissingTimestamp) {
+		t.Errorf("reported %v, want only ErrInvalidTimestamp", reported)
+	}
+}
+
+func TestParseEvents(t *testing.T) {
+	const header = ",,333,5,4CA2D6,1458,2008/11/28,14:53:49.986,2008/11/28,14:53:50.010"
+	tests := []struct {
+		name     string
+		line     string
+		msgType  string
+		callsign string
+		status   string
+		gone     bool
+	}{
+		{"new aircraft", "AIR" + header, models.MessageTypeNewAircraft, "", "", false},
+		{"new ID", "ID" + header + ",RYR1427", models.MessageTypeNewID, "RYR1427", "", false},
+		{"status", "STA" + header + ",PL", models.MessageTypeStatus, "", models.StatusPositionLost, false},
+		{"status in lower case", "STA" + header + ",sl", models.MessageTypeStatus, "", models.StatusSignalLost, false},
+		{"removed", "STA" + header + ",RM", models.MessageTypeStatus, "", models.StatusRemoved, true},
+		{"deleted", "STA" + header + ",AD", models.MessageTypeStatus, "", models.StatusDeleted, true},
+		{"selection", "SEL" + header + ",RYR1427", models.MessageTypeSelection, "RYR1427", "", false},
+		{"click", "CLK" + header, models.MessageTypeClick, "", "", false},
+	}
+	want := time.Date(2008, 11, 28, 14, 53, 49, 986e6, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.NewParser(parser.Options{Strict: true})
+			data, err := p.Parse(tt.line, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data.MessageType != tt.msgType || data.TransmissionType != "" || data.HexIdent != "4CA2D6" {
+				t.Errorf("header = %s/%q %s, want %s/\"\" 4CA2D6", data.MessageType, data.TransmissionType, data.HexIdent, tt.msgType)
+			}
+			if data.SessionID == nil || *data.SessionID != 333 || data.AircraftID == nil || *data.AircraftID != 5 || data.FlightID == nil || *data.FlightID != 1458 {
+				t.Errorf("IDs = %v %v %v, want 333 5 1458", data.SessionID, data.AircraftID, data.FlightID)
+			}
+			if !data.Timestamp.Equal(want) {
+				t.Errorf("Timestamp = %s, want %s", data.Timestamp, want)
+			}
+			if data.Callsign != tt.callsign || data.Status != tt.status {
+				t.Errorf("callsign %q status %q, want %q and %q", data.Callsign, data.Status, tt.callsign, tt.status)
+			}
+			if !data.IsEvent() || data.IsGone() != tt.gone {
+				t.Errorf("IsEvent = %t, IsGone = %t; want true and %t", data.IsEvent(), data.IsGone(), tt.gone)
+			}
+			if data.Altitude != nil || data.Latitude != nil || data.Squawk != "" || data.IsOnGround != nil {
+				t.Errorf("event has transmission data: %+v", data)
+			}
+		})
+	}
+}
+
+func TestParseUnknownMessageType(t *testing.T) {
+	p := parser.NewParser(parser.Options{Strict: true})
+	data, err := p.Parse("XYZ,,333,5,4CA2D6,1458,2008/11/28,14:53:49.986,2008/11/28,14:53:50.010", time.Now())
+	if data != nil || err != nil {
+		t.Errorf("Parse = %+v, %v; want nil, nil", data, err)
+	}
+}
+
+// msg builds an MSG,1 line with the given callsign and alert, emergency, SPI
+// and on-ground flags.
+func msg(callsign, alert, emergency, spi, ground string) string {
+	return "MSG,1,1,1,4CA2D6,1,2008/11/28,14:53:49.986,2008/11/28,14:53:49.986," + callsign + ",,,,,,,," + alert + "," + emergency + "," + spi + "," + ground
+}
+
+func TestParseFlags(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		value string
+		want  *bool
+	}{
+		{"-1", &yes},
+		{"1", &yes},
+		{"0", &no},
+		{"", nil},
+		{" -1 ", &yes},
+	}
+	p := parser.NewParser(parser.Options{Strict: true})
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			data, err := p.Parse(msg("", tt.value, tt.value, tt.value, tt.value), time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, got := range map[string]*bool{"Alert": data.Alert, "Emergency": data.Emergency, "SPI": data.SPI, "IsOnGround": data.IsOnGround} {
+				if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+					t.Errorf("%s = %v, want %v", name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCallsignPadding(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"RYR1427", "RYR1427"},
+		{"EIN12@@@", "EIN12"},
+		{"EIN12   ", "EIN12"},
+		{"EIN12 @@", "EIN12"},
+		{"@@@@@@@@", ""},
+		{"", ""},
+		{"A@B", "A@B"},
+	}
+	p := parser.NewParser(parser.Options{Strict: true})
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			data, err := p.Parse(msg(tt.value, "", "", "", ""), time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data.Callsign != tt.want {
+				t.Errorf("Callsign = %q, want %q", data.Callsign, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStrictFieldErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		fields []string
+		errs   []error
+	}{
+		{"valid", "MSG,3,1,1,4CA2D6,1,2008/11/28,14:53:49.986,2008/11/28,14:53:49.986,,37000,,,51.45735,-1.02826,,,0,0,0,0", nil, nil},
+		{"invalid flag", msg("", "2", "0", "0", "0"), []string{"Alert"}, []error{parser.ErrInvalidFlag}},
+		{"several fields",
+			"MSG,3,1,1,4CA2D6,1,2008/11/28,14:53:49.986,2008/11/28,14:53:49.986,,37k,,,north,-1.02826,,,0,yes,0,true",
+			[]string{"Altitude", "Latitude", "Emergency", "IsOnGround"},
+			[]error{strconv.ErrSyntax, strconv.ErrSyntax, parser.ErrInvalidFlag, parser.ErrInvalidFlag}},
+		{"timestamp and numbers",
+			"MSG,4,x,1,4CA2D6,1,2008/11/28,14:53:49.986,2008/13/28,14:53:49.986,,,450.5,fast,,,-64.5,,,,,",
+			[]string{"Session ID", "Logged Timestamp", "Track", "VerticalRate"},
+			[]error{strconv.ErrSyntax, parser.ErrInvalidTimestamp, strconv.ErrSyntax, strconv.ErrSyntax}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reported *parser.ParseError
+			lenient := parser.NewParser(parser.Options{OnFieldErrors: func(err *parser.ParseError) { reported = err }})
+			data, err := lenient.Parse(tt.line, time.Now())
+			if err != nil || data == nil {
+				t.Fatalf("lenient: %v, %v; want the record", data, err)
+			}
+
+			strict := parser.NewParser(parser.Options{Strict: true})
+			data, err = strict.Parse(tt.line, time.Now())
+			if tt.fields == nil {
+				if err != nil || reported != nil {
+					t.Errorf("strict: %v, lenient reported %v; want no errors", err, reported)
+				}
+				return
+			}
+			var perr *parser.ParseError
+			if data != nil || !errors.As(err, &perr) {
+				t.Fatalf("strict: %v, %v; want a *ParseError", data, err)
+			}
+			if perr.HexIdent != "4CA2D6" || perr.Line != tt.line {
+				t.Errorf("ParseError for %s of %q, want 4CA2D6 and the line", perr.HexIdent, perr.Line)
+			}
+			if got := perr.FieldNames(); !slices.Equal(got, tt.fields) {
+				t.Errorf("strict fields = %v, want %v", got, tt.fields)
+			}
+			for i, f := range perr.Fields {
+				if i < len(tt.errs) && !errors.Is(f, tt.errs[i]) {
+					t.Errorf("field %s: %v, want %v", f.Name, f.Err, tt.errs[i])
+				}
+			}
+			if reported == nil || !slices.Equal(reported.FieldNames(), tt.fields) {
+				t.Errorf("lenient reported %v, want the fields %v", reported, tt.fields)
+			}
+		})
+	}
+}
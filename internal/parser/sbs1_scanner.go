@@ -0,0 +1,244 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sbs1MaxFields is the number of fields in a complete MSG line.
+const sbs1MaxFields = 22
+
+// fieldScanner locates the fields of one SBS-1 line in place and decodes them
+// into typed values. It collects a FieldError for every non-empty field that
+// does not decode. Missing and empty fields are not errors: most MSG subtypes
+// only fill in a few of them.
+type fieldScanner[T string | []byte] struct {
+	line   T
+	bounds [sbs1MaxFields][2]int // start and end of each field in line
+	n      int                   // number of fields in the line, which may exceed sbs1MaxFields
+
+	loc     *time.Location
+	values  *slab
+	strings *interner
+	errs    []*FieldError
+}
+
+// scan splits the line on commas, ignoring the line terminator and any
+// surrounding whitespace.
+func (f *fieldScanner[T]) scan(line T) {
+	f.line = trim(line)
+	start := 0
+	for i := 0; i < len(f.line); i++ {
+		if f.line[i] != ',' {
+			continue
+		}
+		if f.n < sbs1MaxFields {
+			f.bounds[f.n] = [2]int{start, i}
+		}
+		f.n++
+		start = i + 1
+	}
+	if f.n < sbs1MaxFields {
+		f.bounds[f.n] = [2]int{start, len(f.line)}
+	}
+	f.n++
+}
+
+// text returns the trimmed field at index i, or an empty value if the line is shorter.
+func (f *fieldScanner[T]) text(i int) T {
+	if i >= f.n || i >= sbs1MaxFields {
+		return f.line[:0]
+	}
+	b := f.bounds[i]
+	return trim(f.line[b[0]:b[1]])
+}
+
+func (f *fieldScanner[T]) fail(i int, value string, err error) {
+	f.errs = append(f.errs, &FieldError{Field: i + 1, Name: sbs1FieldNames[i], Value: value, Err: err})
+}
+
+// failed reports whether the field at index i has already been recorded as an error.
+func (f *fieldScanner[T]) failed(i int) bool {
+	for _, e := range f.errs {
+		if e.Field == i+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// str returns the field at index i as an interned string.
+func (f *fieldScanner[T]) str(i int) string {
+	return intern(f.strings, f.text(i))
+}
+
+func (f *fieldScanner[T]) int(i int) *int {
+	s := f.text(i)
+	if len(s) == 0 {
+		return nil
+	}
+	val, ok := atoi(s)
+	if !ok {
+		// Let strconv describe the problem; this path may allocate.
+		_, err := strconv.Atoi(string(s))
+		f.fail(i, string(s), err)
+		return nil
+	}
+	return f.values.int(val)
+}
+
+func (f *fieldScanner[T]) float(i int) *float64 {
+	s := f.text(i)
+	if len(s) == 0 {
+		return nil
+	}
+	val, err := strconv.ParseFloat(string(s), 64)
+	if err != nil {
+		f.fail(i, string(s), err)
+		return nil
+	}
+	return f.values.float(val)
+}
+
+// flag decodes a boolean field. The SBS-1 format uses -1 for true and 0 for
+// false; some feeders send 1 for true, which is accepted as well.
+func (f *fieldScanner[T]) flag(i int) *bool {
+	s := f.text(i)
+	switch string(s) {
+	case "":
+		return nil
+	case "-1", "1":
+		return f.values.bool(true)
+	case "0":
+		return f.values.bool(false)
+	}
+	f.fail(i, string(s), ErrInvalidFlag)
+	return nil
+}
+
+// callsign returns the callsign without padding. BaseStation shows NULL
+// characters as '@', so a callsign of only '@'s means no callsign.
+func (f *fieldScanner[T]) callsign(i int) string {
+	s := f.text(i)
+	end := len(s)
+	for end > 0 && (s[end-1] == '@' || s[end-1] == ' ') {
+		end--
+	}
+	return intern(f.strings, s[:end])
+}
+
+// status returns an STA status such as "RM" in upper case.
+func (f *fieldScanner[T]) status(i int) string {
+	s := intern(f.strings, f.text(i))
+	for j := 0; j < len(s); j++ {
+		if 'a' <= s[j] && s[j] <= 'z' {
+			return intern(f.strings, strings.ToUpper(s))
+		}
+	}
+	return s
+}
+
+// timestamp parses the date field at index i ("2006/01/02") and the time
+// field after it ("15:04:05.000", with optional fractional seconds) in the
+// receiver's time zone. It returns the zero time if either is missing or invalid.
+func (f *fieldScanner[T]) timestamp(i int) time.Time {
+	date, clock := f.text(i), f.text(i+1)
+	if len(date) == 0 || len(clock) == 0 {
+		return time.Time{}
+	}
+	t, ok := parseTimestamp(date, clock, f.loc)
+	if !ok {
+		f.fail(i, string(date)+" "+string(clock), ErrInvalidTimestamp)
+		return time.Time{}
+	}
+	return t
+}
+
+func parseTimestamp[T string | []byte](date, clock T, loc *time.Location) (time.Time, bool) {
+	if len(date) != 10 || date[4] != '/' || date[7] != '/' ||
+		len(clock) < 8 || clock[2] != ':' || clock[5] != ':' {
+		return time.Time{}, false
+	}
+	year, ok1 := digits(date[0:4])
+	month, ok2 := digits(date[5:7])
+	day, ok3 := digits(date[8:10])
+	hour, ok4 := digits(clock[0:2])
+	minute, ok5 := digits(clock[3:5])
+	sec, ok6 := digits(clock[6:8])
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || !ok6 ||
+		month < 1 || month > 12 || day < 1 || hour > 23 || minute > 59 || sec > 59 {
+		return time.Time{}, false
+	}
+
+	nsec := 0
+	if frac := clock[8:]; len(frac) > 0 {
+		if frac[0] != '.' || len(frac) < 2 || len(frac) > 10 {
+			return time.Time{}, false
+		}
+		n, ok := digits(frac[1:])
+		if !ok {
+			return time.Time{}, false
+		}
+		for j := len(frac) - 1; j < 9; j++ {
+			n *= 10
+		}
+		nsec = n
+	}
+
+	t := time.Date(year, time.Month(month), day, hour, minute, sec, nsec, loc)
+	if t.Day() != day { // e.g. February 30th, which time.Date normalises
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// digits decodes an unsigned decimal made of ASCII digits only.
+func digits[T string | []byte](s T) (int, bool) {
+	if len(s) == 0 {
+		return 0, false
+	}
+	n := 0
+	for j := 0; j < len(s); j++ {
+		c := s[j]
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+// atoi decodes an optionally signed decimal integer of up to 18 digits, which
+// cannot overflow; anything else is left to strconv.
+func atoi[T string | []byte](s T) (int, bool) {
+	neg := false
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if len(s) > 18 {
+		return 0, false
+	}
+	n, ok := digits(s)
+	if neg {
+		n = -n
+	}
+	return n, ok
+}
+
+// trim removes leading and trailing ASCII whitespace, including the CR of a CRLF line ending.
+func trim[T string | []byte](s T) T {
+	start, end := 0, len(s)
+	for start < end && isSpace(s[start]) {
+		start++
+	}
+	for end > start && isSpace(s[end-1]) {
+		end--
+	}
+	return s[start:end]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
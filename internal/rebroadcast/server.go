@@ -0,0 +1,248 @@
+package rebroadcast
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const clientWriteTimeout = 10 * time.Second
+
+// Server re-serves received SBS-1 lines on a TCP port, so that downstream
+// tools such as Virtual Radar Server or PlanePlotter can use the collector as
+// a hub in front of several dump1090 instances.
+//
+// Broadcast never blocks the caller: every client has its own bounded queue,
+// and a client whose queue is full is disconnected.
+type Server struct {
+	addr         string
+	queueSize    int
+	messageTypes map[string]bool // nil forwards every message type
+	dedupWindow  time.Duration
+
+	listener net.Listener
+
+	mu        sync.Mutex
+	clients   map[*client]struct{}
+	recent    map[string]recentLine // dedup key -> last forwarded
+	lastPrune time.Time
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// recentLine records which source a deduplicated line was last forwarded from, and when.
+type recentLine struct {
+	source string
+	at     time.Time
+}
+
+type client struct {
+	conn  net.Conn
+	queue chan []byte
+	once  sync.Once
+}
+
+func (c *client) close() {
+	c.once.Do(func() {
+		close(c.queue)
+		_ = c.conn.Close()
+	})
+}
+
+// NewServer creates a rebroadcast server. messageTypes restricts forwarding to
+// the given SBS-1 message types (e.g. "MSG", "STA"); an empty list forwards all.
+// A non-zero dedupWindow suppresses messages whose content was already forwarded
+// from another source within the window, which removes duplicates heard by more
+// than one receiver. Repeated messages from a single source are all forwarded.
+func NewServer(addr string, queueSize int, messageTypes []string, dedupWindow time.Duration) *Server {
+	s := &Server{
+		addr:        addr,
+		queueSize:   queueSize,
+		dedupWindow: dedupWindow,
+		clients:     make(map[*client]struct{}),
+		recent:      make(map[string]recentLine),
+	}
+	if len(messageTypes) > 0 {
+		s.messageTypes = make(map[string]bool, len(messageTypes))
+		for _, t := range messageTypes {
+			s.messageTypes[strings.ToUpper(strings.TrimSpace(t))] = true
+		}
+	}
+	return s
+}
+
+// Start binds the listener and accepts clients in the background.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	s.listener = ln
+	log.Printf("SBS-1 rebroadcast server listening on %s.", ln.Addr())
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("ERROR: SBS-1 rebroadcast accept failed: %v", err)
+			}
+			return
+		}
+
+		c := &client{conn: conn, queue: make(chan []byte, s.queueSize)}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.clients[c] = struct{}{}
+		s.mu.Unlock()
+		log.Printf("SBS-1 rebroadcast client connected: %s", conn.RemoteAddr())
+
+		s.wg.Add(2)
+		go s.writeLoop(c)
+		go s.drainInput(c)
+	}
+}
+
+// writeLoop sends queued lines to a single client.
+func (s *Server) writeLoop(c *client) {
+	defer s.wg.Done()
+	defer s.remove(c)
+	for line := range c.queue {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+		if _, err := c.conn.Write(line); err != nil {
+			return
+		}
+	}
+}
+
+// drainInput discards anything the client sends and notices when it disconnects.
+func (s *Server) drainInput(c *client) {
+	defer s.wg.Done()
+	_, _ = io.Copy(io.Discard, c.conn)
+	s.remove(c)
+}
+
+func (s *Server) remove(c *client) {
+	s.mu.Lock()
+	_, present := s.clients[c]
+	delete(s.clients, c)
+	s.mu.Unlock()
+	c.close()
+	if present {
+		log.Printf("SBS-1 rebroadcast client disconnected: %s", c.conn.RemoteAddr())
+	}
+}
+
+// Broadcast queues a raw SBS-1 line (without line terminator) received from
+// the named source for every client.
+func (s *Server) Broadcast(source, line string) {
+	if s.messageTypes != nil {
+		msgType, _, _ := strings.Cut(line, ",")
+		if !s.messageTypes[strings.TrimSpace(msgType)] {
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients) == 0 {
+		return
+	}
+
+	if s.dedupWindow > 0 {
+		now := time.Now()
+		key := dedupKey(line)
+		if last, ok := s.recent[key]; ok && last.source != source && now.Sub(last.at) < s.dedupWindow {
+			return
+		}
+		s.recent[key] = recentLine{source: source, at: now}
+		if now.Sub(s.lastPrune) > s.dedupWindow {
+			for k, last := range s.recent {
+				if now.Sub(last.at) >= s.dedupWindow {
+					delete(s.recent, k)
+				}
+			}
+			s.lastPrune = now
+		}
+	}
+
+	// BaseStation terminates lines with CRLF.
+	payload := []byte(line + "\r\n")
+	for c := range s.clients {
+		select {
+		case c.queue <- payload:
+		default:
+			log.Printf("Warning: SBS-1 rebroadcast client %s is too slow, disconnecting.", c.conn.RemoteAddr())
+			delete(s.clients, c)
+			c.close()
+		}
+	}
+}
+
+// Clients returns the number of connected clients.
+func (s *Server) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// Close stops accepting clients and disconnects everyone.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.clients {
+		delete(s.clients, c)
+		c.close()
+	}
+	s.mu.Unlock()
+
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.wg.Wait()
+	return err
+}
+
+// dedupKey identifies the content of an SBS-1 line independently of which
+// receiver logged it: session/aircraft/flight IDs and the timestamps differ
+// between receivers, so only the message type, subtype, hex ident and the
+// data fields (11 onwards) are compared.
+func dedupKey(line string) string {
+	fields := strings.Split(line, ",")
+	if len(fields) < 10 {
+		return line
+	}
+	var b strings.Builder
+	b.Grow(len(line))
+	b.WriteString(fields[0])
+	b.WriteByte(',')
+	b.WriteString(fields[1])
+	b.WriteByte(',')
+	b.WriteString(strings.ToUpper(fields[4]))
+	for _, f := range fields[10:] {
+		b.WriteByte(',')
+		b.WriteString(strings.TrimSpace(f))
+	}
+	return b.String()
+}
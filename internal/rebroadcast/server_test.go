@@ -0,0 +1,175 @@
+package rebroadcast
+
+import (
+	"bufio"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	msg3 = "MSG,3,1,1,4CA2D6,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000,,37000,,,53.1,-6.2,,,0,0,0,0"
+	// msg3Elsewhere is msg3 as logged by another receiver.
+	msg3Elsewhere = "MSG,3,5,7,4ca2d6,9,2024/01/01,12:00:00.120,2024/01/01,12:00:00.125,,37000,,,53.1,-6.2,,,0,0,0,0"
+	msg4          = "MSG,4,1,1,4CA2D6,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000,,,450,270,,,0,,,,,0"
+	sta           = "STA,,1,1,4CA2D6,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000,PL"
+)
+
+// addClient registers a client that is never written to, so that its queue
+// can be inspected.
+func addClient(s *Server) *client {
+	conn, _ := net.Pipe()
+	c := &client{conn: conn, queue: make(chan []byte, s.queueSize)}
+	s.clients[c] = struct{}{}
+	return c
+}
+
+// queued returns the lines queued for c.
+func queued(c *client) []string {
+	var lines []string
+	for len(c.queue) > 0 {
+		lines = append(lines, string(<-c.queue))
+	}
+	return lines
+}
+
+func TestDedupKey(t *testing.T) {
+	if dedupKey(msg3) != dedupKey(msg3Elsewhere) {
+		t.Errorf("keys of the same message from two receivers differ:\n  %s\n  %s", dedupKey(msg3), dedupKey(msg3Elsewhere))
+	}
+	for _, other := range []string{msg4, sta, "MSG,3,1,1,4CA2D7,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000,,37000,,,53.1,-6.2,,,0,0,0,0"} {
+		if dedupKey(msg3) == dedupKey(other) {
+			t.Errorf("key of %q equals the key of %q", other, msg3)
+		}
+	}
+	if got := dedupKey("MSG,3,1"); got != "MSG,3,1" {
+		t.Errorf("dedupKey of a short line = %q, want the line", got)
+	}
+}
+
+func TestBroadcastMessageTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []string
+		want  []string
+	}{
+		{"all", nil, []string{msg3, msg4, sta}},
+		{"MSG", []string{"MSG"}, []string{msg3, msg4}},
+		{"normalized", []string{" sta "}, []string{sta}},
+		{"none matching", []string{"AIR"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("127.0.0.1:0", 10, tt.types, 0)
+			c := addClient(s)
+			for _, line := range []string{msg3, msg4, sta} {
+				s.Broadcast("rx", line)
+			}
+			var want []string
+			for _, line := range tt.want {
+				want = append(want, line+"\r\n")
+			}
+			if got := queued(c); !slices.Equal(got, want) {
+				t.Errorf("queued %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestBroadcastDedup(t *testing.T) {
+	type broadcast struct {
+		source, line string
+		after        time.Duration // wait before broadcasting
+	}
+	tests := []struct {
+		name       string
+		window     time.Duration
+		broadcasts []broadcast
+		want       int
+	}{
+		{"disabled", 0, []broadcast{{"a", msg3, 0}, {"b", msg3Elsewhere, 0}}, 2},
+		{"another receiver", time.Hour, []broadcast{{"a", msg3, 0}, {"b", msg3Elsewhere, 0}}, 1},
+		{"repeated by one receiver", time.Hour, []broadcast{{"a", msg3, 0}, {"a", msg3, 0}, {"a", msg3, 0}}, 3},
+		{"repeated, then another receiver", time.Hour, []broadcast{{"a", msg3, 0}, {"a", msg3, 0}, {"b", msg3Elsewhere, 0}}, 2},
+		{"different messages", time.Hour, []broadcast{{"a", msg3, 0}, {"b", msg4, 0}}, 2},
+		{"window passed", 20 * time.Millisecond, []broadcast{{"a", msg3, 0}, {"b", msg3Elsewhere, 40 * time.Millisecond}}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("127.0.0.1:0", 10, nil, tt.window)
+			c := addClient(s)
+			for _, b := range tt.broadcasts {
+				time.Sleep(b.after)
+				s.Broadcast(b.source, b.line)
+			}
+			if got := len(queued(c)); got != tt.want {
+				t.Errorf("forwarded %d lines, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBroadcastDisconnectsFullQueue(t *testing.T) {
+	s := NewServer("127.0.0.1:0", 2, nil, 0)
+	slow := addClient(s)
+	fast := addClient(s)
+	for range 2 {
+		s.Broadcast("rx", msg3)
+	}
+	queued(fast)
+	if s.Clients() != 2 {
+		t.Fatalf("%d clients with full queues, want 2", s.Clients())
+	}
+	s.Broadcast("rx", msg3)
+	if s.Clients() != 1 {
+		t.Fatalf("%d clients after a queue overflowed, want 1", s.Clients())
+	}
+	if _, ok := s.clients[fast]; !ok {
+		t.Error("disconnected the client that kept up")
+	}
+	if _, err := slow.conn.Write([]byte("x")); err == nil {
+		t.Error("connection of the slow client still open")
+	}
+	if got := queued(fast); len(got) != 1 {
+		t.Errorf("queued %d lines for the client that kept up, want 1", len(got))
+	}
+}
+
+func TestServe(t *testing.T) {
+	s := NewServer("127.0.0.1:0", 10, []string{"MSG"}, 0)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Clients() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("client not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.Broadcast("rx", sta)
+	s.Broadcast("rx", msg3)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != msg3+"\r\n" {
+		t.Errorf("received %q, want %q", line, msg3+"\r\n")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Clients() != 0 {
+		t.Errorf("%d clients after Close, want 0", s.Clients())
+	}
+}
@@ -0,0 +1,44 @@
+package replay
+
+import "time"
+
+// Pacer spaces out replayed lines the way they were originally received,
+// sped up by a factor. A speed of 0 replays as fast as possible.
+type Pacer struct {
+	speed float64
+
+	start time.Time // wall clock time of the first paced line
+	first time.Time // capture time of the first paced line
+}
+
+// NewPacer returns a Pacer for the given speed multiplier: 1 is real time,
+// 10 is ten times faster, and 0 disables pacing.
+func NewPacer(speed float64) *Pacer {
+	return &Pacer{speed: speed}
+}
+
+// Wait blocks until the line captured at t is due. Lines without a timestamp,
+// and lines that are already late, are due immediately. Wait returns false if
+// done is closed first.
+func (p *Pacer) Wait(t time.Time, done <-chan struct{}) bool {
+	if p.speed <= 0 || t.IsZero() {
+		return true
+	}
+	if p.first.IsZero() {
+		p.start, p.first = time.Now(), t
+		return true
+	}
+	due := p.start.Add(time.Duration(float64(t.Sub(p.first)) / p.speed))
+	wait := time.Until(due)
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
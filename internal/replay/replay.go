@@ -0,0 +1,159 @@
+// Package replay reads recorded SBS-1 and Beast captures back as SBS-1 lines,
+// optionally paced by the timestamps they contain.
+package replay
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/beast"
+)
+
+// Capture formats.
+const (
+	FormatSBS1  = "sbs1"
+	FormatBeast = "beast"
+)
+
+// Line is an SBS-1 line read from a capture, with the time it was originally
+// received. Time is zero when the line does not carry a usable timestamp.
+type Line struct {
+	Text string
+	Time time.Time
+}
+
+// File is an open capture file. Gzip compression and the format are detected
+// from the content: Beast streams start with the 0x1a frame marker.
+type File struct {
+	file   *os.File
+	gz     *gzip.Reader
+	format string
+	loc    *time.Location
+
+	lines *bufio.Scanner
+
+	frames  *beast.Reader
+	decoder *beast.Decoder
+	base    time.Time // time of the first Beast frame
+	counter uint64    // MLAT counter of the frame at base
+	last    time.Time
+}
+
+// Open opens a capture file. SBS-1 timestamps are read in loc, and lines
+// decoded from Beast frames are written with timestamps in loc. Beast frames
+// only carry a relative counter, so their timeline starts at the time the file
+// is opened.
+func Open(path string, loc *time.Location) (*File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capture: %w", err)
+	}
+	f := &File{file: file, loc: loc}
+
+	in := bufio.NewReader(file)
+	if magic, _ := in.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		if f.gz, err = gzip.NewReader(in); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to open gzip capture %s: %w", path, err)
+		}
+		in = bufio.NewReader(f.gz)
+	}
+
+	if first, _ := in.Peek(1); len(first) == 1 && first[0] == 0x1a {
+		f.format = FormatBeast
+		f.frames = beast.NewReader(in)
+		f.decoder = beast.NewDecoder(loc)
+		f.base = time.Now()
+	} else {
+		f.format = FormatSBS1
+		f.lines = bufio.NewScanner(in)
+		f.lines.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	}
+	return f, nil
+}
+
+// Format returns FormatSBS1 or FormatBeast.
+func (f *File) Format() string {
+	return f.format
+}
+
+// Next returns the next SBS-1 line, or io.EOF at the end of the capture.
+// Beast frames that do not decode to an SBS-1 message are skipped.
+func (f *File) Next() (Line, error) {
+	if f.lines != nil {
+		for f.lines.Scan() {
+			text := strings.TrimRight(f.lines.Text(), "\r")
+			if text == "" {
+				continue
+			}
+			return Line{Text: text, Time: sbs1Time(text, f.loc)}, nil
+		}
+		if err := f.lines.Err(); err != nil {
+			return Line{}, err
+		}
+		return Line{}, io.EOF
+	}
+
+	for {
+		frame, err := f.frames.Read()
+		if err != nil {
+			return Line{}, err
+		}
+		if frame.Type == beast.FrameModeAC {
+			continue
+		}
+		t := f.frameTime(frame.Timestamp)
+		if text, ok := f.decoder.Decode(frame.Data, t); ok {
+			return Line{Text: text, Time: t}, nil
+		}
+	}
+}
+
+// frameTime converts a Beast MLAT counter to a time on the replay's timeline.
+func (f *File) frameTime(counter uint64) time.Time {
+	switch {
+	case counter == 0: // the receiver does not provide timestamps
+		if f.last.IsZero() {
+			f.last = f.base
+		}
+		return f.last
+	case f.counter == 0 || counter < f.counter: // first frame, or the receiver restarted
+		if !f.last.IsZero() {
+			f.base = f.last
+		}
+		f.counter = counter
+	}
+	f.last = f.base.Add(time.Duration(float64(counter-f.counter) / beast.TimestampHz * float64(time.Second)))
+	return f.last
+}
+
+// Close closes the capture file.
+func (f *File) Close() error {
+	var gzErr error
+	if f.gz != nil {
+		gzErr = f.gz.Close()
+	}
+	return errors.Join(gzErr, f.file.Close())
+}
+
+// sbs1Time returns the logged timestamp of an SBS-1 line (when the receiver
+// wrote it), falling back to the generated one, or the zero time.
+func sbs1Time(line string, loc *time.Location) time.Time {
+	fields := strings.SplitN(line, ",", 11)
+	for _, i := range []int{8, 6} {
+		if len(fields) <= i+1 {
+			continue
+		}
+		value := strings.TrimSpace(fields[i]) + " " + strings.TrimSpace(fields[i+1])
+		if t, err := time.ParseInLocation("2006/01/02 15:04:05.999999999", value, loc); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
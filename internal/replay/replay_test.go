@@ -0,0 +1,186 @@
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/beast"
+)
+
+// writeCapture writes content to a file in a temporary directory, gzipped if compress is set.
+func writeCapture(t *testing.T, content []byte, compress bool) string {
+	t.Helper()
+	if compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(content); err != nil {
+			t.Fatal(err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+		content = buf.Bytes()
+	}
+	path := filepath.Join(t.TempDir(), "capture")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readAll returns every line of the capture at path.
+func readAll(t *testing.T, path string, loc *time.Location) (string, []Line) {
+	t.Helper()
+	f, err := Open(path, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []Line
+	for {
+		line, err := f.Next()
+		if errors.Is(err, io.EOF) {
+			return f.Format(), lines
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+}
+
+// beastFrame encodes a Mode S message (in hex) as a Beast frame with the given MLAT counter.
+func beastFrame(t *testing.T, typ byte, counter uint64, msg string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte{byte(counter >> 40), byte(counter >> 32), byte(counter >> 24), byte(counter >> 16), byte(counter >> 8), byte(counter), 0x80}
+	frame := []byte{0x1a, typ}
+	for _, b := range append(body, data...) {
+		frame = append(frame, b)
+		if b == 0x1a {
+			frame = append(frame, b)
+		}
+	}
+	return frame
+}
+
+func TestReplaySBS1(t *testing.T) {
+	content := []byte("MSG,3,1,1,4CA2D6,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.250,,37000,,,53.1,-6.2,,,0,0,0,0\r\n" +
+		"\n" +
+		"MSG,4,1,1,4CA2D6,1,2024/01/01,12:00:01.500,,,,,450,270,,,0,,,,,0\n" +
+		"MSG,8,1,1,4CA2D6,1,,,,,,,,,,,,,,,,0\n")
+	want := []Line{
+		{"MSG,3,1,1,4CA2D6,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.250,,37000,,,53.1,-6.2,,,0,0,0,0", time.Date(2024, 1, 1, 11, 0, 0, 250e6, time.UTC)},
+		{"MSG,4,1,1,4CA2D6,1,2024/01/01,12:00:01.500,,,,,450,270,,,0,,,,,0", time.Date(2024, 1, 1, 11, 0, 1, 500e6, time.UTC)},
+		{"MSG,8,1,1,4CA2D6,1,,,,,,,,,,,,,,,,0", time.Time{}},
+	}
+	loc := time.FixedZone("UTC+1", 3600)
+	for _, compress := range []bool{false, true} {
+		format, lines := readAll(t, writeCapture(t, content, compress), loc)
+		if format != FormatSBS1 {
+			t.Errorf("compressed %t: format %s, want %s", compress, format, FormatSBS1)
+		}
+		if len(lines) != len(want) {
+			t.Fatalf("compressed %t: read %d lines, want %d", compress, len(lines), len(want))
+		}
+		for i, line := range lines {
+			if line.Text != want[i].Text || !line.Time.Equal(want[i].Time) {
+				t.Errorf("compressed %t: line %d = %q at %s, want %q at %s", compress, i, line.Text, line.Time, want[i].Text, want[i].Time)
+			}
+		}
+	}
+}
+
+func TestReplayBeast(t *testing.T) {
+	var content []byte
+	for _, frame := range [][]byte{
+		beastFrame(t, beast.FrameModeAC, 1_000_000, "2000"),
+		beastFrame(t, beast.FrameModeSLong, 0x1a1a1a1a, "8D4840D6202CC371C32CE0576098"),                   // KLM1023
+		beastFrame(t, beast.FrameModeSShort, 0x1a1a1a1a+beast.TimestampHz/2, "28000AAA02E41F"),            // squawk 7700
+		beastFrame(t, beast.FrameModeSLong, 0x1a1a1a1a+beast.TimestampHz, "8D4840D6202CC371C32CE0576099"), // corrupted
+		beastFrame(t, beast.FrameModeSShort, 5, "5D4840D6F8741A"),                                         // the receiver restarted
+	} {
+		content = append(content, frame...)
+	}
+	for _, compress := range []bool{false, true} {
+		opened := time.Now()
+		format, lines := readAll(t, writeCapture(t, content, compress), time.UTC)
+		if format != FormatBeast {
+			t.Errorf("compressed %t: format %s, want %s", compress, format, FormatBeast)
+		}
+		// The address/parity reply is from an address heard before, so it decodes.
+		wantTypes := []string{"MSG,1,", "MSG,6,", "MSG,8,"}
+		// After the restart, the timeline continues from the last frame, the corrupted one.
+		wantOffsets := []time.Duration{0, 500 * time.Millisecond, time.Second}
+		if len(lines) != len(wantTypes) {
+			t.Fatalf("compressed %t: read %d lines, want %d: %v", compress, len(lines), len(wantTypes), lines)
+		}
+		base := lines[0].Time
+		if base.Before(opened) || time.Since(base) > time.Minute {
+			t.Errorf("compressed %t: first line at %s, want the time the file was opened", compress, base)
+		}
+		for i, line := range lines {
+			if line.Text[:6] != wantTypes[i] || line.Time.Sub(base) != wantOffsets[i] {
+				t.Errorf("compressed %t: line %d = %q at +%s, want %s... at +%s", compress, i, line.Text, line.Time.Sub(base), wantTypes[i], wantOffsets[i])
+			}
+		}
+	}
+}
+
+func TestReplayTruncatedBeast(t *testing.T) {
+	frame := beastFrame(t, beast.FrameModeSLong, 1, "8D4840D6202CC371C32CE0576098")
+	content := append(frame[:10:10], beastFrame(t, beast.FrameModeSLong, 2, "8D4840D6202CC371C32CE0576098")...)
+	content = append(content, frame[:10]...)
+	f, err := Open(writeCapture(t, content, false), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if line, err := f.Next(); err != nil || line.Text[:6] != "MSG,1," {
+		t.Fatalf("Next = %q, %v; want the complete frame", line.Text, err)
+	}
+	if _, err := f.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Next at the truncated end = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestPacer(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	never := make(chan struct{})
+
+	p := NewPacer(0)
+	began := time.Now()
+	for i := range 3 {
+		if !p.Wait(start.Add(time.Duration(i)*time.Hour), never) {
+			t.Fatal("Wait returned false without done closed")
+		}
+	}
+	if elapsed := time.Since(began); elapsed > 100*time.Millisecond {
+		t.Errorf("unpaced replay waited %s", elapsed)
+	}
+
+	p = NewPacer(10)
+	began = time.Now()
+	p.Wait(start, never)
+	p.Wait(time.Time{}, never) // no timestamp, due at once
+	p.Wait(start.Add(500*time.Millisecond), never)
+	if elapsed := time.Since(began); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("replay at 10x waited %s for 500ms, want 50ms", elapsed)
+	}
+
+	done := make(chan struct{})
+	close(done)
+	if p.Wait(start.Add(time.Hour), done) {
+		t.Error("Wait returned true with done closed")
+	}
+}
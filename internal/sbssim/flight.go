@@ -0,0 +1,176 @@
+package sbssim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	earthRadiusKm = 6371.0
+	kmPerNm       = 1.852
+
+	turnRate      = 3.0  // degrees per second, a standard rate turn
+	waypointReach = 10.0 // km from a waypoint at which the next one is chosen
+	alertDuration = 10 * time.Second
+	identDuration = 18 * time.Second // how long SPI stays set after the pilot presses IDENT
+
+	// Per-second probabilities of the random events of a flight.
+	altitudeChangeChance = 1.0 / 300
+	squawkChangeChance   = 1.0 / 900
+	emergencyChance      = 1.0 / 3600
+	identChance          = 1.0 / 600
+)
+
+var airlines = []string{"BAW", "AFR", "DLH", "KLM", "RYR", "EZY", "UAE", "SAS", "IBE", "AAL"}
+
+// flight is one synthetic aircraft flying from waypoint to waypoint along
+// great circles, with climbs, descents, squawk changes and the odd emergency.
+type flight struct {
+	hex      string
+	callsign string
+	squawk   string
+
+	sessionID, aircraftID, flightID int
+
+	lat, lon  float64 // degrees
+	alt       float64 // feet
+	targetAlt float64
+	climbRate float64 // feet per minute while climbing or descending
+	vr        float64 // current vertical rate, feet per minute
+	speed     float64 // knots
+	track     float64 // degrees true
+
+	destLat, destLon float64
+
+	expires        time.Time
+	alertUntil     time.Time // set after a squawk change
+	identUntil     time.Time
+	emergencyUntil time.Time
+	normalSquawk   string // restored when an emergency ends
+}
+
+// newFlight creates an aircraft at a random position in the area.
+func newFlight(rng *rand.Rand, opts *Options, id int, now time.Time) *flight {
+	f := &flight{
+		hex:        fmt.Sprintf("%06X", 0x400000+rng.Intn(0x3FFFFF)),
+		callsign:   fmt.Sprintf("%s%d", airlines[rng.Intn(len(airlines))], 10+rng.Intn(9000)),
+		squawk:     randomSquawk(rng),
+		sessionID:  1,
+		aircraftID: id,
+		flightID:   id,
+		alt:        float64(1000 * (5 + rng.Intn(36))),
+		speed:      250 + rng.Float64()*230,
+		track:      rng.Float64() * 360,
+		expires:    now.Add(opts.Lifetime/2 + time.Duration(rng.Int63n(int64(opts.Lifetime)))),
+	}
+	f.lat, f.lon = randomPoint(rng, opts.CenterLat, opts.CenterLon, opts.RadiusKm)
+	f.targetAlt = f.alt
+	f.destLat, f.destLon = randomPoint(rng, opts.CenterLat, opts.CenterLon, opts.RadiusKm)
+	return f
+}
+
+// step advances the flight by dt, with random events drawn from rng.
+func (f *flight) step(rng *rand.Rand, opts *Options, dt float64, now time.Time) {
+	if dt <= 0 {
+		return
+	}
+
+	// Turn towards the next waypoint at a standard rate, then fly straight on.
+	if distanceKm(f.lat, f.lon, f.destLat, f.destLon) < waypointReach {
+		f.destLat, f.destLon = randomPoint(rng, opts.CenterLat, opts.CenterLon, opts.RadiusKm)
+	}
+	turn := math.Remainder(bearing(f.lat, f.lon, f.destLat, f.destLon)-f.track, 360)
+	maxTurn := turnRate * dt
+	f.track = math.Mod(f.track+math.Max(-maxTurn, math.Min(maxTurn, turn))+360, 360)
+	f.lat, f.lon = destination(f.lat, f.lon, f.track, f.speed*kmPerNm*dt/3600)
+
+	if chance(rng, altitudeChangeChance, dt) {
+		f.targetAlt = float64(1000 * (5 + rng.Intn(36)))
+		f.climbRate = 1000 + rng.Float64()*2000
+	}
+	switch diff := f.targetAlt - f.alt; {
+	case math.Abs(diff) < 50:
+		f.alt, f.vr = f.targetAlt, 0
+	case diff > 0:
+		f.vr = f.climbRate
+	default:
+		f.vr = -f.climbRate
+	}
+	f.alt += f.vr * dt / 60
+
+	if !f.emergencyUntil.IsZero() && now.After(f.emergencyUntil) {
+		f.emergencyUntil = time.Time{}
+		f.setSquawk(f.normalSquawk, now)
+	}
+	if f.emergencyUntil.IsZero() {
+		switch {
+		case chance(rng, emergencyChance, dt):
+			f.normalSquawk = f.squawk
+			f.emergencyUntil = now.Add(2*time.Minute + time.Duration(rng.Int63n(int64(3*time.Minute))))
+			f.setSquawk([]string{"7500", "7600", "7700"}[rng.Intn(3)], now)
+		case chance(rng, squawkChangeChance, dt):
+			f.setSquawk(randomSquawk(rng), now)
+		}
+	}
+	if chance(rng, identChance, dt) {
+		f.identUntil = now.Add(identDuration)
+	}
+}
+
+func (f *flight) setSquawk(squawk string, now time.Time) {
+	f.squawk = squawk
+	f.alertUntil = now.Add(alertDuration)
+}
+
+func (f *flight) emergency() bool {
+	return !f.emergencyUntil.IsZero()
+}
+
+// chance reports whether an event with the given per-second probability happens within dt seconds.
+func chance(rng *rand.Rand, perSecond, dt float64) bool {
+	return rng.Float64() < 1-math.Pow(1-perSecond, dt)
+}
+
+func randomSquawk(rng *rand.Rand) string {
+	for {
+		sq := fmt.Sprintf("%o", 0o1000+rng.Intn(0o7000))
+		if sq != "7500" && sq != "7600" && sq != "7700" {
+			return sq
+		}
+	}
+}
+
+// randomPoint returns a point uniformly distributed within radiusKm of the centre.
+func randomPoint(rng *rand.Rand, lat, lon, radiusKm float64) (float64, float64) {
+	return destination(lat, lon, rng.Float64()*360, radiusKm*math.Sqrt(rng.Float64()))
+}
+
+// destination returns the point reached by flying distKm from lat/lon on the initial bearing.
+func destination(lat, lon, bearingDeg, distKm float64) (float64, float64) {
+	phi1, lambda1, theta := radians(lat), radians(lon), radians(bearingDeg)
+	delta := distKm / earthRadiusKm
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1), math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+	return degrees(phi2), math.Remainder(degrees(lambda2), 360)
+}
+
+// bearing returns the initial great circle bearing from the first point to the second.
+func bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2, dLambda := radians(lat1), radians(lat2), radians(lon2-lon1)
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	return math.Mod(degrees(math.Atan2(y, x))+360, 360)
+}
+
+// distanceKm returns the great circle distance between two points.
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := radians(lat1), radians(lat2)
+	dPhi, dLambda := phi2-phi1, radians(lon2-lon1)
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
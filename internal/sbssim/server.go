@@ -0,0 +1,233 @@
+package sbssim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	tickInterval       = 10 * time.Millisecond
+	clientWriteTimeout = time.Second
+)
+
+// Server serves a simulated SBS-1 feed on a TCP port, like dump1090 on port
+// 30003: every connected client receives the same lines, and lines generated
+// while nobody is connected are lost.
+type Server struct {
+	addr string
+	opts Options
+
+	listener net.Listener
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	clients map[net.Conn]struct{}
+
+	sent        atomic.Int64
+	disconnects atomic.Int64
+	stalls      atomic.Int64
+}
+
+// NewServer creates a server that will listen on addr ("127.0.0.1:0" picks a free port).
+func NewServer(addr string, opts Options) *Server {
+	opts.setDefaults()
+	return &Server{
+		addr:    addr,
+		opts:    opts,
+		done:    make(chan struct{}),
+		clients: make(map[net.Conn]struct{}),
+	}
+}
+
+// Start binds the listener and starts generating traffic in the background.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	s.listener = ln
+	log.Printf("SBS-1 simulator listening on %s (%d aircraft, %.0f lines/s).", ln.Addr(), s.opts.Aircraft, s.opts.Rate)
+
+	s.wg.Add(2)
+	go s.acceptLoop()
+	go s.generate()
+	return nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Sent returns the number of lines delivered to at least one client.
+func (s *Server) Sent() int64 {
+	return s.sent.Load()
+}
+
+// Disconnects returns the number of times the clients were dropped on purpose.
+func (s *Server) Disconnects() int64 {
+	return s.disconnects.Load()
+}
+
+// Stalls returns the number of stalls injected so far.
+func (s *Server) Stalls() int64 {
+	return s.stalls.Load()
+}
+
+// Clients returns the number of connected clients.
+func (s *Server) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("ERROR: SBS-1 simulator accept failed: %v", err)
+			}
+			return
+		}
+		s.mu.Lock()
+		select {
+		case <-s.done:
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		default:
+		}
+		s.clients[conn] = struct{}{}
+		s.mu.Unlock()
+		log.Printf("SBS-1 simulator client connected: %s", conn.RemoteAddr())
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			_, _ = io.Copy(io.Discard, conn)
+			s.drop(conn)
+		}()
+	}
+}
+
+// generate produces lines at the configured rate and injects the faults.
+func (s *Server) generate() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	now := time.Now()
+	sim := NewSimulator(s.opts, now)
+	last := now
+	due := 0.0
+	faults := s.opts.Faults
+	nextDisconnect := next(now, faults.DisconnectEvery)
+	nextStall := next(now, faults.StallEvery)
+	var stallUntil time.Time
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now = <-ticker.C:
+		}
+
+		if !nextDisconnect.IsZero() && now.After(nextDisconnect) {
+			nextDisconnect = next(now, faults.DisconnectEvery)
+			s.disconnects.Add(1)
+			log.Println("SBS-1 simulator: injecting disconnect.")
+			s.dropAll()
+		}
+		if !nextStall.IsZero() && now.After(nextStall) {
+			nextStall = next(now, faults.StallEvery)
+			stallUntil = now.Add(faults.StallFor)
+			s.stalls.Add(1)
+			log.Printf("SBS-1 simulator: injecting a %s stall.", faults.StallFor)
+		}
+
+		due += now.Sub(last).Seconds() * s.opts.Rate
+		last = now
+		var buf []byte
+		lines := 0
+		for ; due >= 1; due-- {
+			line := sim.Next(now)
+			if now.Before(stallUntil) {
+				continue // the aircraft keep flying, but nothing is sent
+			}
+			buf = append(buf, line...)
+			buf = append(buf, '\r', '\n')
+			lines++
+		}
+		if lines > 0 {
+			s.broadcast(buf, lines)
+		}
+	}
+}
+
+// broadcast writes a block of lines to every client, dropping those that cannot keep up.
+func (s *Server) broadcast(buf []byte, lines int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delivered := false
+	for conn := range s.clients {
+		_ = conn.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+		if _, err := conn.Write(buf); err != nil {
+			delete(s.clients, conn)
+			_ = conn.Close()
+			continue
+		}
+		delivered = true
+	}
+	if delivered {
+		s.sent.Add(int64(lines))
+	}
+}
+
+func (s *Server) drop(conn net.Conn) {
+	s.mu.Lock()
+	_, present := s.clients[conn]
+	delete(s.clients, conn)
+	s.mu.Unlock()
+	_ = conn.Close()
+	if present {
+		log.Printf("SBS-1 simulator client disconnected: %s", conn.RemoteAddr())
+	}
+}
+
+func (s *Server) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.clients {
+		delete(s.clients, conn)
+		_ = conn.Close()
+	}
+}
+
+// Close stops the feed and disconnects everyone.
+func (s *Server) Close() error {
+	close(s.done)
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.dropAll()
+	s.wg.Wait()
+	return err
+}
+
+// next returns the time of the next periodic fault, or the zero time if it is disabled.
+func next(now time.Time, every time.Duration) time.Time {
+	if every <= 0 {
+		return time.Time{}
+	}
+	return now.Add(every)
+}
@@ -0,0 +1,215 @@
+// Package sbssim simulates a dump1090 SBS-1 feed: synthetic aircraft flying
+// great circle paths between random waypoints, with climbs, turns, squawk
+// changes and emergencies, plus injectable faults. It backs cmd/sbs-sim and
+// can be used directly to exercise the collector end to end:
+//
+//	srv := sbssim.NewServer("127.0.0.1:0", sbssim.Options{Aircraft: 20, Rate: 200})
+//	if err := srv.Start(); err != nil { ... }
+//	defer srv.Close()
+//	// point DUMP1090_HOST/DUMP1090_PORT at srv.Addr()
+package sbssim
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options configures the simulated traffic.
+type Options struct {
+	Aircraft  int     // aircraft in the air at any time; default 10
+	Rate      float64 // SBS-1 lines per second across all aircraft; default 100
+	CenterLat float64 // centre of the simulated area; default Heathrow
+	CenterLon float64
+	RadiusKm  float64       // radius of the simulated area; default 200 km
+	Lifetime  time.Duration // average time an aircraft stays before it leaves and is replaced; default 20 minutes
+	Seed      int64         // seed of the random generator; 0 picks one from the clock
+	Location  *time.Location
+	Faults    Faults
+}
+
+// Faults are the failures a Server injects into its feed.
+type Faults struct {
+	Malformed       float64       // fraction of lines that are corrupted
+	DisconnectEvery time.Duration // drop every client this often; 0 disables
+	StallEvery      time.Duration // stop sending this often, keeping clients connected; 0 disables
+	StallFor        time.Duration // how long a stall lasts
+}
+
+func (o *Options) setDefaults() {
+	if o.Aircraft <= 0 {
+		o.Aircraft = 10
+	}
+	if o.Rate <= 0 {
+		o.Rate = 100
+	}
+	if o.CenterLat == 0 && o.CenterLon == 0 {
+		o.CenterLat, o.CenterLon = 51.4700, -0.4543
+	}
+	if o.RadiusKm <= 0 {
+		o.RadiusKm = 200
+	}
+	if o.Lifetime <= 0 {
+		o.Lifetime = 20 * time.Minute
+	}
+	if o.Seed == 0 {
+		o.Seed = time.Now().UnixNano()
+	}
+	if o.Location == nil {
+		o.Location = time.UTC
+	}
+}
+
+// Simulator generates the lines of the feed. It is not safe for concurrent use.
+type Simulator struct {
+	opts    Options
+	rng     *rand.Rand
+	flights []*flight
+	nextID  int
+	last    time.Time
+	pending []string // lines queued ahead of the regular messages, such as AIR/ID for a new aircraft
+}
+
+// messageWeights is the relative frequency of each MSG transmission type,
+// roughly as seen from dump1090 with mostly ADS-B equipped traffic.
+var messageWeights = []struct {
+	transmission int
+	weight       int
+}{
+	{1, 4}, {3, 35}, {4, 25}, {5, 15}, {6, 6}, {7, 5}, {8, 10},
+}
+
+// NewSimulator creates the initial aircraft. now is the time of the first line.
+func NewSimulator(opts Options, now time.Time) *Simulator {
+	opts.setDefaults()
+	s := &Simulator{opts: opts, rng: rand.New(rand.NewSource(opts.Seed)), last: now}
+	for range opts.Aircraft {
+		s.flights = append(s.flights, s.spawnFlight(now))
+	}
+	return s
+}
+
+// Next advances every aircraft to now and returns the next line of the feed.
+func (s *Simulator) Next(now time.Time) string {
+	if dt := now.Sub(s.last).Seconds(); dt > 0 {
+		for i, f := range s.flights {
+			f.step(s.rng, &s.opts, dt, now)
+			if now.After(f.expires) {
+				s.pending = append(s.pending, s.line(f, now, "STA", "", "RM"))
+				s.flights[i] = s.spawnFlight(now)
+			}
+		}
+		s.last = now
+	}
+
+	var line string
+	if len(s.pending) > 0 {
+		line, s.pending = s.pending[0], s.pending[1:]
+	} else {
+		line = s.message(s.flights[s.rng.Intn(len(s.flights))], now)
+	}
+	if s.opts.Faults.Malformed > 0 && s.rng.Float64() < s.opts.Faults.Malformed {
+		line = s.corrupt(line)
+	}
+	return line
+}
+
+// spawnFlight creates a new aircraft and queues the AIR and ID messages
+// BaseStation sends when it first hears one.
+func (s *Simulator) spawnFlight(now time.Time) *flight {
+	s.nextID++
+	f := newFlight(s.rng, &s.opts, s.nextID, now)
+	s.pending = append(s.pending, s.line(f, now, "AIR", "", ""), s.line(f, now, "ID", "", f.callsign))
+	return f
+}
+
+// message returns a random MSG transmission for an aircraft.
+func (s *Simulator) message(f *flight, now time.Time) string {
+	total := 0
+	for _, m := range messageWeights {
+		total += m.weight
+	}
+	pick := s.rng.Intn(total)
+	transmission := 0
+	for _, m := range messageWeights {
+		if pick < m.weight {
+			transmission = m.transmission
+			break
+		}
+		pick -= m.weight
+	}
+
+	var fields [12]string // fields 10 to 21 of the MSG line
+	alt := strconv.Itoa(int(f.alt/25) * 25)
+	alert := flag(now.Before(f.alertUntil))
+	emergency := flag(f.emergency())
+	spi := flag(now.Before(f.identUntil))
+	switch transmission {
+	case 1:
+		fields[0] = f.callsign
+	case 3:
+		fields[1] = alt
+		fields[4] = strconv.FormatFloat(f.lat, 'f', 5, 64)
+		fields[5] = strconv.FormatFloat(f.lon, 'f', 5, 64)
+		fields[8], fields[9], fields[10], fields[11] = alert, emergency, spi, "0"
+	case 4:
+		fields[2] = strconv.FormatFloat(f.speed, 'f', 1, 64)
+		fields[3] = strconv.FormatFloat(f.track, 'f', 1, 64)
+		fields[6] = strconv.Itoa(int(f.vr/64) * 64)
+	case 5:
+		fields[1] = alt
+		fields[8], fields[10], fields[11] = alert, spi, "0"
+	case 6:
+		fields[7] = f.squawk
+		fields[8], fields[9], fields[10], fields[11] = alert, emergency, spi, "0"
+	case 7:
+		fields[1] = alt
+		fields[11] = "0"
+	case 8:
+		fields[11] = "0"
+	}
+	return s.line(f, now, "MSG", strconv.Itoa(transmission), strings.Join(fields[:], ","))
+}
+
+// line formats a BaseStation message for an aircraft; rest holds the fields from the 11th on.
+func (s *Simulator) line(f *flight, now time.Time, msgType, transmission, rest string) string {
+	local := now.In(s.opts.Location)
+	date, clock := local.Format("2006/01/02"), local.Format("15:04:05.000")
+	return msgType + "," + transmission + "," + strconv.Itoa(f.sessionID) + "," + strconv.Itoa(f.aircraftID) + "," + f.hex + "," +
+		strconv.Itoa(f.flightID) + "," + date + "," + clock + "," + date + "," + clock + "," + rest
+}
+
+// corrupt damages a line the way a flaky feeder or network might.
+func (s *Simulator) corrupt(line string) string {
+	fields := strings.Split(line, ",")
+	switch s.rng.Intn(4) {
+	case 0: // truncated
+		return line[:s.rng.Intn(len(line))]
+	case 1: // a letter in a number
+		if len(fields) > 11 && fields[11] != "" {
+			fields[11] = strings.Replace(fields[11], "0", "O", 1) + "O"
+			return strings.Join(fields, ",")
+		}
+		return line + ",O"
+	case 2: // an invalid flag
+		if len(fields) == 22 {
+			fields[21] = "2"
+			return strings.Join(fields, ",")
+		}
+		return strings.Replace(line, ",", ",,", 1)
+	default: // line noise
+		noise := []byte(line)
+		for i := 0; i < 3; i++ {
+			noise[s.rng.Intn(len(noise))] = byte(0x21 + s.rng.Intn(0x5E))
+		}
+		return string(noise)
+	}
+}
+
+func flag(set bool) string {
+	if set {
+		return "-1"
+	}
+	return "0"
+}
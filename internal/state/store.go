@@ -0,0 +1,201 @@
+package state
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// TrackPoint is a single position report in an aircraft's recent history.
+type TrackPoint struct {
+	Time         time.Time
+	Latitude     float64
+	Longitude    float64
+	Altitude     *int
+	GroundSpeed  *float64
+	Track        *float64
+	VerticalRate *int
+}
+
+// Aircraft is a snapshot of everything the collector currently knows about one aircraft.
+type Aircraft struct {
+	State     models.AircraftData // merged latest value of every field
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Messages  int
+	Track     []TrackPoint // oldest first
+}
+
+// Store keeps an in-memory view of recently seen aircraft, built by merging
+// every parsed message. Aircraft not heard from for longer than ttl are pruned.
+type Store struct {
+	mu             sync.RWMutex
+	aircraft       map[string]*Aircraft
+	ttl            time.Duration
+	maxTrackPoints int
+}
+
+// NewStore creates an empty Store.
+func NewStore(ttl time.Duration, maxTrackPoints int) *Store {
+	return &Store{
+		aircraft:       make(map[string]*Aircraft),
+		ttl:            ttl,
+		maxTrackPoints: maxTrackPoints,
+	}
+}
+
+// Update merges a parsed message into the aircraft's state and returns a copy of the merged state.
+// Event messages only refresh the callsign, status and liveness of the aircraft;
+// a STA RM/AD event removes it from the store.
+func (s *Store) Update(data *models.AircraftData) models.AircraftData {
+	hex := normalizeHex(data.HexIdent)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ac, ok := s.aircraft[hex]
+	if data.IsGone() {
+		if !ok {
+			return *data
+		}
+		delete(s.aircraft, hex)
+		ac.State.Status = data.Status
+		return ac.State
+	}
+	if !ok {
+		ac = &Aircraft{FirstSeen: now}
+		ac.State.HexIdent = hex
+		s.aircraft[hex] = ac
+	}
+	ac.LastSeen = now
+	ac.Messages++
+
+	if data.IsEvent() {
+		if data.Callsign != "" {
+			ac.State.Callsign = data.Callsign
+		}
+		if data.Status != "" {
+			ac.State.Status = data.Status
+		}
+		return ac.State
+	}
+
+	ac.State.Merge(data)
+	ac.State.HexIdent = hex
+
+	if data.Latitude != nil && data.Longitude != nil {
+		ac.Track = append(ac.Track, TrackPoint{
+			Time:         data.Timestamp,
+			Latitude:     *data.Latitude,
+			Longitude:    *data.Longitude,
+			Altitude:     ac.State.Altitude,
+			GroundSpeed:  ac.State.GroundSpeed,
+			Track:        ac.State.Track,
+			VerticalRate: ac.State.VerticalRate,
+		})
+		if s.maxTrackPoints > 0 && len(ac.Track) > s.maxTrackPoints {
+			// Drop the oldest points, reusing the backing array.
+			excess := len(ac.Track) - s.maxTrackPoints
+			ac.Track = append(ac.Track[:0], ac.Track[excess:]...)
+		}
+	}
+
+	return ac.State
+}
+
+// Get returns a snapshot of a single aircraft.
+func (s *Store) Get(hex string) (Aircraft, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ac, ok := s.aircraft[normalizeHex(hex)]
+	if !ok {
+		return Aircraft{}, false
+	}
+	return ac.snapshot(time.Time{}), true
+}
+
+// Track returns the aircraft's track points generated at or after since.
+func (s *Store) Track(hex string, since time.Time) ([]TrackPoint, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ac, ok := s.aircraft[normalizeHex(hex)]
+	if !ok {
+		return nil, false
+	}
+	return ac.snapshot(since).Track, true
+}
+
+// List returns snapshots of all known aircraft, sorted by hex ident. Tracks are omitted.
+func (s *Store) List() []Aircraft {
+	s.mu.RLock()
+	list := make([]Aircraft, 0, len(s.aircraft))
+	for _, ac := range s.aircraft {
+		snap := *ac
+		snap.Track = nil
+		list = append(list, snap)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].State.HexIdent < list[j].State.HexIdent })
+	return list
+}
+
+// Len returns the number of aircraft currently tracked.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.aircraft)
+}
+
+// Prune removes aircraft that have not been seen for longer than the store's TTL
+// and returns how many were removed.
+func (s *Store) Prune(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for hex, ac := range s.aircraft {
+		if now.Sub(ac.LastSeen) > s.ttl {
+			delete(s.aircraft, hex)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Run prunes expired aircraft periodically until done is closed.
+func (s *Store) Run(done <-chan struct{}) {
+	interval := s.ttl / 2
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			s.Prune(now)
+		}
+	}
+}
+
+// snapshot copies the aircraft, keeping only track points at or after since.
+func (ac *Aircraft) snapshot(since time.Time) Aircraft {
+	snap := *ac
+	snap.Track = make([]TrackPoint, 0, len(ac.Track))
+	for _, p := range ac.Track {
+		if !p.Time.Before(since) {
+			snap.Track = append(snap.Track, p)
+		}
+	}
+	return snap
+}
+
+func normalizeHex(hex string) string {
+	return strings.ToUpper(strings.TrimSpace(hex))
+}
@@ -0,0 +1,134 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// position is an airborne position message of 4CA2D6 generated at start+at.
+func position(at time.Duration, lat, lon float64) *models.AircraftData {
+	altitude := 37000
+	return &models.AircraftData{
+		MessageType:      models.MessageTypeTransmission,
+		TransmissionType: "3",
+		HexIdent:         "4ca2d6",
+		Timestamp:        start.Add(at),
+		Latitude:         &lat,
+		Longitude:        &lon,
+		Altitude:         &altitude,
+	}
+}
+
+func TestStoreTrimsTrack(t *testing.T) {
+	tests := []struct {
+		name      string
+		max       int
+		positions int
+		want      []time.Duration // times of the kept points
+	}{
+		{"under the limit", 3, 2, []time.Duration{0, time.Second}},
+		{"at the limit", 3, 3, []time.Duration{0, time.Second, 2 * time.Second}},
+		{"over the limit", 3, 5, []time.Duration{2 * time.Second, 3 * time.Second, 4 * time.Second}},
+		{"no limit", 0, 5, []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore(time.Hour, tt.max)
+			for i := range tt.positions {
+				s.Update(position(time.Duration(i)*time.Second, 53+float64(i)/100, -6))
+			}
+			ac, ok := s.Get("4CA2D6")
+			if !ok {
+				t.Fatal("aircraft not stored")
+			}
+			if len(ac.Track) != len(tt.want) {
+				t.Fatalf("track has %d points, want %d", len(ac.Track), len(tt.want))
+			}
+			for i, p := range ac.Track {
+				if !p.Time.Equal(start.Add(tt.want[i])) {
+					t.Errorf("point %d at %s, want %s", i, p.Time, start.Add(tt.want[i]))
+				}
+			}
+		})
+	}
+}
+
+func TestStoreEvents(t *testing.T) {
+	lat, lon := 53.5, -6.5
+	tests := []struct {
+		name     string
+		event    models.AircraftData
+		callsign string
+		status   string
+		gone     bool
+	}{
+		{"new aircraft", models.AircraftData{MessageType: models.MessageTypeNewAircraft}, "", "", false},
+		{"new callsign", models.AircraftData{MessageType: models.MessageTypeNewID, Callsign: "EIN123"}, "EIN123", "", false},
+		{"position lost", models.AircraftData{MessageType: models.MessageTypeStatus, Status: models.StatusPositionLost}, "", models.StatusPositionLost, false},
+		{"zone entered", models.AircraftData{MessageType: models.MessageTypeZoneEnter, Latitude: &lat, Longitude: &lon}, "", "", false},
+		{"removed", models.AircraftData{MessageType: models.MessageTypeStatus, Status: models.StatusRemoved}, "", models.StatusRemoved, true},
+		{"deleted", models.AircraftData{MessageType: models.MessageTypeStatus, Status: models.StatusDeleted}, "", models.StatusDeleted, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore(time.Hour, 10)
+			s.Update(position(0, 53, -6))
+			event := tt.event
+			event.HexIdent = "4CA2D6"
+			event.Timestamp = start.Add(time.Second)
+			merged := s.Update(&event)
+			if merged.Callsign != tt.callsign || merged.Status != tt.status {
+				t.Errorf("merged callsign %q status %q, want %q and %q", merged.Callsign, merged.Status, tt.callsign, tt.status)
+			}
+			if merged.Latitude == nil || *merged.Latitude != 53 {
+				t.Errorf("merged latitude %v, want the position message's 53", merged.Latitude)
+			}
+			ac, ok := s.Get("4CA2D6")
+			if ok == tt.gone {
+				t.Fatalf("aircraft stored = %t, want %t", ok, !tt.gone)
+			}
+			if ok && (len(ac.Track) != 1 || ac.Messages != 2) {
+				t.Errorf("aircraft has %d track points from %d messages, want 1 from 2", len(ac.Track), ac.Messages)
+			}
+		})
+	}
+}
+
+func TestStoreTrackSince(t *testing.T) {
+	s := NewStore(time.Hour, 10)
+	for i := range 4 {
+		s.Update(position(time.Duration(i)*time.Minute, 53, -6))
+	}
+	if _, ok := s.Track("400000", time.Time{}); ok {
+		t.Error("found the track of an unknown aircraft")
+	}
+	for _, tt := range []struct {
+		since time.Time
+		want  int
+	}{
+		{time.Time{}, 4},
+		{start.Add(2 * time.Minute), 2},
+		{start.Add(2*time.Minute + time.Second), 1},
+		{start.Add(time.Hour), 0},
+	} {
+		track, ok := s.Track(" 4ca2d6", tt.since)
+		if !ok || len(track) != tt.want {
+			t.Errorf("Track since %s = %d points, %t; want %d", tt.since, len(track), ok, tt.want)
+		}
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	s := NewStore(time.Minute, 10)
+	s.Update(position(0, 53, -6))
+	if removed := s.Prune(time.Now()); removed != 0 || s.Len() != 1 {
+		t.Errorf("Prune removed %d of a fresh aircraft, want 0", removed)
+	}
+	if removed := s.Prune(time.Now().Add(2 * time.Minute)); removed != 1 || s.Len() != 0 {
+		t.Errorf("Prune removed %d of an expired aircraft, want 1", removed)
+	}
+}
@@ -0,0 +1,106 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/telemetry"
+)
+
+// Server is the collector's embedded HTTP server. It serves the health,
+// readiness and metrics endpoints, and other packages may mount additional
+// handlers on it with Handle.
+type Server struct {
+	addr        string
+	telemetry   *telemetry.Telemetry
+	maxWriteAge time.Duration
+	mux         *http.ServeMux
+	httpServer  *http.Server
+}
+
+// NewServer creates a status server listening on addr.
+// maxWriteAge is how long batch writes may keep failing before /readyz reports not ready.
+func NewServer(addr string, tel *telemetry.Telemetry, maxWriteAge time.Duration) *Server {
+	s := &Server{
+		addr:        addr,
+		telemetry:   tel,
+		maxWriteAge: maxWriteAge,
+		mux:         http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /healthz", s.handleHealthz)
+	s.mux.HandleFunc("GET /readyz", s.handleReadyz)
+	s.mux.HandleFunc("GET /metrics", s.handleMetrics)
+	s.httpServer = &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Handle registers an additional handler for the given pattern.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start binds the listener and serves requests in the background.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	log.Printf("HTTP status server listening on %s.", ln.Addr())
+	go func() {
+		if err := s.httpServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("ERROR: HTTP status server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown gracefully stops the server.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+// handleHealthz reports that the process is alive.
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = fmt.Fprintln(w, "ok")
+}
+
+// handleReadyz reports whether the collector is connected to dump1090, or is
+// replaying a capture, and its writes are succeeding. A quiet sky with no
+// writes at all is still ready; the collector becomes unready once a batch has
+// been waiting for longer than maxWriteAge without a successful write, whether
+// the writes fail or hang.
+func (s *Server) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if !s.telemetry.Connected() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprintln(w, "not ready: not connected to any dump1090 source")
+		return
+	}
+
+	due := s.telemetry.WriteDue()
+	if !due.IsZero() && time.Since(due) > s.maxWriteAge {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprintf(w, "not ready: no successful write since %s\n", s.telemetry.LastWriteSuccess().UTC().Format(time.RFC3339))
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, "ready")
+}
+
+// handleMetrics serves the collector counters in Prometheus text format.
+func (s *Server) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := s.telemetry.WritePrometheus(w); err != nil {
+		log.Printf("Error writing metrics response: %v", err)
+	}
+}
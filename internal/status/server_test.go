@@ -0,0 +1,63 @@
+package status
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/telemetry"
+)
+
+func TestReadyz(t *testing.T) {
+	const maxWriteAge = 20 * time.Millisecond
+	tests := []struct {
+		name  string
+		setup func(tel *telemetry.Telemetry)
+		want  int
+	}{
+		{"not connected", func(*telemetry.Telemetry) {}, http.StatusServiceUnavailable},
+		{"quiet sky", func(tel *telemetry.Telemetry) {
+			tel.SetConnected("rx", true)
+			time.Sleep(2 * maxWriteAge)
+		}, http.StatusOK},
+		{"write just due", func(tel *telemetry.Telemetry) {
+			tel.SetConnected("rx", true)
+			time.Sleep(2 * maxWriteAge)
+			tel.BatchQueued()
+		}, http.StatusOK},
+		{"write hanging", func(tel *telemetry.Telemetry) {
+			tel.SetConnected("rx", true)
+			tel.BatchQueued()
+			time.Sleep(2 * maxWriteAge)
+		}, http.StatusServiceUnavailable},
+		{"writes failing", func(tel *telemetry.Telemetry) {
+			tel.SetConnected("rx", true)
+			tel.BatchQueued()
+			time.Sleep(2 * maxWriteAge)
+			tel.WriteFailed(context.Background(), time.Millisecond)
+		}, http.StatusServiceUnavailable},
+		{"written", func(tel *telemetry.Telemetry) {
+			tel.SetConnected("rx", true)
+			tel.BatchQueued()
+			time.Sleep(2 * maxWriteAge)
+			tel.BatchWritten(context.Background(), 1, time.Millisecond)
+		}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel, err := telemetry.New(context.Background(), telemetry.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.setup(tel)
+			s := NewServer("127.0.0.1:0", tel, maxWriteAge)
+			rec := httptest.NewRecorder()
+			s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d (%q), want %d", rec.Code, rec.Body.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package stream
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// Mode selects what a subscriber receives for each message.
+const (
+	ModeRecord = "record" // the message as parsed
+	ModeState  = "state"  // the aircraft's merged state after the message
+)
+
+// BoundingBox is a latitude/longitude rectangle.
+type BoundingBox struct {
+	MinLat, MinLon, MaxLat, MaxLon float64
+}
+
+// Filter restricts which events a subscriber receives. Zero values match everything.
+// Position and altitude criteria exclude events that do not carry the value.
+type Filter struct {
+	Mode           string
+	Hex            map[string]struct{}
+	CallsignPrefix string
+	BBox           *BoundingBox
+	MinAltitude    *int
+	MaxAltitude    *int
+}
+
+// Subscription is the JSON/query form of a Filter, as supplied by clients.
+type Subscription struct {
+	Mode        string    `json:"mode,omitempty"`
+	Hex         []string  `json:"hex,omitempty"`
+	Callsign    string    `json:"callsign,omitempty"`
+	BBox        []float64 `json:"bbox,omitempty"` // min_lat, min_lon, max_lat, max_lon
+	MinAltitude *int      `json:"min_alt,omitempty"`
+	MaxAltitude *int      `json:"max_alt,omitempty"`
+}
+
+// SubscriptionFromQuery reads a subscription from URL query parameters:
+// mode, hex (comma separated), callsign, bbox (min_lat,min_lon,max_lat,max_lon), min_alt and max_alt.
+func SubscriptionFromQuery(q url.Values) (Subscription, error) {
+	sub := Subscription{
+		Mode:     q.Get("mode"),
+		Callsign: q.Get("callsign"),
+	}
+	if hex := q.Get("hex"); hex != "" {
+		sub.Hex = strings.Split(hex, ",")
+	}
+	if bbox := q.Get("bbox"); bbox != "" {
+		for _, part := range strings.Split(bbox, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return Subscription{}, fmt.Errorf("invalid bbox %q: %w", bbox, err)
+			}
+			sub.BBox = append(sub.BBox, v)
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  **int
+	}{{"min_alt", &sub.MinAltitude}, {"max_alt", &sub.MaxAltitude}} {
+		if v := q.Get(p.name); v != "" {
+			alt, err := strconv.Atoi(v)
+			if err != nil {
+				return Subscription{}, fmt.Errorf("invalid %s %q: %w", p.name, v, err)
+			}
+			*p.dst = &alt
+		}
+	}
+	return sub, nil
+}
+
+// Filter validates the subscription and compiles it into a Filter.
+func (sub Subscription) Filter() (Filter, error) {
+	f := Filter{
+		Mode:           sub.Mode,
+		CallsignPrefix: strings.ToUpper(strings.TrimSpace(sub.Callsign)),
+		MinAltitude:    sub.MinAltitude,
+		MaxAltitude:    sub.MaxAltitude,
+	}
+	switch f.Mode {
+	case "":
+		f.Mode = ModeRecord
+	case ModeRecord, ModeState:
+	default:
+		return Filter{}, fmt.Errorf("invalid mode %q: expected %s or %s", sub.Mode, ModeRecord, ModeState)
+	}
+	if len(sub.Hex) > 0 {
+		f.Hex = make(map[string]struct{}, len(sub.Hex))
+		for _, h := range sub.Hex {
+			if h = strings.ToUpper(strings.TrimSpace(h)); h != "" {
+				f.Hex[h] = struct{}{}
+			}
+		}
+	}
+	if len(sub.BBox) > 0 {
+		if len(sub.BBox) != 4 {
+			return Filter{}, fmt.Errorf("bbox needs 4 values (min_lat,min_lon,max_lat,max_lon), got %d", len(sub.BBox))
+		}
+		f.BBox = &BoundingBox{MinLat: sub.BBox[0], MinLon: sub.BBox[1], MaxLat: sub.BBox[2], MaxLon: sub.BBox[3]}
+		if f.BBox.MinLat > f.BBox.MaxLat || f.BBox.MinLon > f.BBox.MaxLon {
+			return Filter{}, fmt.Errorf("bbox minimums must not exceed maximums")
+		}
+	}
+	if f.MinAltitude != nil && f.MaxAltitude != nil && *f.MinAltitude > *f.MaxAltitude {
+		return Filter{}, fmt.Errorf("min_alt must not exceed max_alt")
+	}
+	return f, nil
+}
+
+// target returns the part of the event the filter applies to.
+func (f *Filter) target(ev *Event) *models.AircraftData {
+	if f.Mode == ModeState {
+		return &ev.State
+	}
+	return &ev.Record
+}
+
+// Match reports whether data passes the filter.
+func (f *Filter) Match(data *models.AircraftData) bool {
+	if f.Hex != nil {
+		if _, ok := f.Hex[strings.ToUpper(data.HexIdent)]; !ok {
+			return false
+		}
+	}
+	if f.CallsignPrefix != "" && !strings.HasPrefix(strings.ToUpper(data.Callsign), f.CallsignPrefix) {
+		return false
+	}
+	if f.BBox != nil {
+		if data.Latitude == nil || data.Longitude == nil {
+			return false
+		}
+		lat, lon := *data.Latitude, *data.Longitude
+		if lat < f.BBox.MinLat || lat > f.BBox.MaxLat || lon < f.BBox.MinLon || lon > f.BBox.MaxLon {
+			return false
+		}
+	}
+	if f.MinAltitude != nil || f.MaxAltitude != nil {
+		if data.Altitude == nil {
+			return false
+		}
+		if f.MinAltitude != nil && *data.Altitude < *f.MinAltitude {
+			return false
+		}
+		if f.MaxAltitude != nil && *data.Altitude > *f.MaxAltitude {
+			return false
+		}
+	}
+	return true
+}
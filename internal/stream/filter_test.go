@@ -0,0 +1,105 @@
+package stream
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestSubscriptionFromQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    Subscription
+		wantErr bool
+	}{
+		{"", Subscription{}, false},
+		{"mode=state&hex=4ca2d6,3C6586&callsign=ein", Subscription{Mode: "state", Hex: []string{"4ca2d6", "3C6586"}, Callsign: "ein"}, false},
+		{"bbox=53,-7,54.5,-6", Subscription{BBox: []float64{53, -7, 54.5, -6}}, false},
+		{"bbox=53,%20-7", Subscription{BBox: []float64{53, -7}}, false},
+		{"min_alt=1000&max_alt=40000", Subscription{MinAltitude: intPtr(1000), MaxAltitude: intPtr(40000)}, false},
+		{"bbox=53,west", Subscription{}, true},
+		{"min_alt=low", Subscription{}, true},
+		{"max_alt=1e4", Subscription{}, true},
+	}
+	for _, tt := range tests {
+		q, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := SubscriptionFromQuery(q)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SubscriptionFromQuery(%q) = %+v, %v; want %+v, error %t", tt.query, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSubscriptionFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		sub     Subscription
+		want    Filter
+		wantErr bool
+	}{
+		{"empty", Subscription{}, Filter{Mode: ModeRecord}, false},
+		{"state", Subscription{Mode: ModeState}, Filter{Mode: ModeState}, false},
+		{"normalized", Subscription{Hex: []string{" 4ca2d6", "", "3C6586"}, Callsign: " ein "},
+			Filter{Mode: ModeRecord, Hex: map[string]struct{}{"4CA2D6": {}, "3C6586": {}}, CallsignPrefix: "EIN"}, false},
+		{"bbox", Subscription{BBox: []float64{53, -7, 54, -6}}, Filter{Mode: ModeRecord, BBox: &BoundingBox{53, -7, 54, -6}}, false},
+		{"point bbox", Subscription{BBox: []float64{53, -7, 53, -7}}, Filter{Mode: ModeRecord, BBox: &BoundingBox{53, -7, 53, -7}}, false},
+		{"invalid mode", Subscription{Mode: "raw"}, Filter{}, true},
+		{"short bbox", Subscription{BBox: []float64{53, -7, 54}}, Filter{}, true},
+		{"inverted latitudes", Subscription{BBox: []float64{54, -7, 53, -6}}, Filter{}, true},
+		{"inverted longitudes", Subscription{BBox: []float64{53, -6, 54, -7}}, Filter{}, true},
+		{"inverted altitudes", Subscription{MinAltitude: intPtr(2000), MaxAltitude: intPtr(1000)}, Filter{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.sub.Filter()
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter() = %+v, %v; want %+v, error %t", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	lat, lon, alt := 53.4, -6.3, 35000
+	positioned := models.AircraftData{HexIdent: "4ca2d6", Callsign: "EIN123", Latitude: &lat, Longitude: &lon, Altitude: &alt}
+	bare := models.AircraftData{HexIdent: "4CA2D6"}
+	tests := []struct {
+		name string
+		sub  Subscription
+		data models.AircraftData
+		want bool
+	}{
+		{"everything", Subscription{}, bare, true},
+		{"hex", Subscription{Hex: []string{"4CA2D6"}}, positioned, true},
+		{"other hex", Subscription{Hex: []string{"3C6586"}}, positioned, false},
+		{"callsign prefix", Subscription{Callsign: "ein"}, positioned, true},
+		{"other callsign", Subscription{Callsign: "RYR"}, positioned, false},
+		{"no callsign", Subscription{Callsign: "EIN"}, bare, false},
+		{"inside bbox", Subscription{BBox: []float64{53, -7, 54, -6}}, positioned, true},
+		{"on the bbox edge", Subscription{BBox: []float64{53.4, -6.3, 54, -6}}, positioned, true},
+		{"outside bbox", Subscription{BBox: []float64{52, -7, 53, -6}}, positioned, false},
+		{"bbox without position", Subscription{BBox: []float64{53, -7, 54, -6}}, bare, false},
+		{"within altitudes", Subscription{MinAltitude: intPtr(30000), MaxAltitude: intPtr(35000)}, positioned, true},
+		{"below min_alt", Subscription{MinAltitude: intPtr(36000)}, positioned, false},
+		{"above max_alt", Subscription{MaxAltitude: intPtr(34999)}, positioned, false},
+		{"altitude without altitude", Subscription{MaxAltitude: intPtr(40000)}, bare, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := tt.sub.Filter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Match(&tt.data); got != tt.want {
+				t.Errorf("Match = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// Event is published for every parsed message: the record as received, and
+// the merged state of the aircraft after applying it.
+type Event struct {
+	Record models.AircraftData
+	State  models.AircraftData
+}
+
+// Hub fans parsed messages out to streaming clients. Publish never blocks:
+// each subscriber has a bounded buffer, events are dropped for subscribers
+// whose buffer is full, and a subscriber that keeps falling behind is disconnected.
+type Hub struct {
+	mu         sync.RWMutex
+	subs       map[*Subscriber]struct{}
+	bufferSize int
+	maxDropped int
+}
+
+// NewHub creates a Hub. bufferSize is the per-client queue length and
+// maxDropped the number of consecutive dropped events after which a client is disconnected.
+func NewHub(bufferSize, maxDropped int) *Hub {
+	return &Hub{
+		subs:       make(map[*Subscriber]struct{}),
+		bufferSize: bufferSize,
+		maxDropped: maxDropped,
+	}
+}
+
+// Subscriber is a single streaming client.
+type Subscriber struct {
+	events  chan Event
+	filter  atomic.Pointer[Filter]
+	dropped atomic.Int64 // consecutive events dropped because the queue was full
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Events returns the channel the client should drain.
+func (s *Subscriber) Events() <-chan Event {
+	return s.events
+}
+
+// Done is closed when the hub disconnects the subscriber for being too slow.
+func (s *Subscriber) Done() <-chan struct{} {
+	return s.done
+}
+
+// SetFilter replaces the subscriber's filter.
+func (s *Subscriber) SetFilter(f Filter) {
+	s.filter.Store(&f)
+}
+
+// Filter returns the subscriber's current filter.
+func (s *Subscriber) Filter() Filter {
+	return *s.filter.Load()
+}
+
+func (s *Subscriber) close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// Subscribe registers a new client with the given filter.
+func (h *Hub) Subscribe(f Filter) *Subscriber {
+	s := &Subscriber{
+		events: make(chan Event, h.bufferSize),
+		done:   make(chan struct{}),
+	}
+	s.SetFilter(f)
+
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Unsubscribe removes a client. It is safe to call more than once.
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	delete(h.subs, s)
+	h.mu.Unlock()
+	s.close()
+}
+
+// Len returns the number of connected subscribers.
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+// Publish offers an event to every subscriber whose filter matches. It never blocks.
+func (h *Hub) Publish(record, state *models.AircraftData) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.subs) == 0 {
+		return
+	}
+
+	ev := Event{Record: *record, State: *state}
+	for s := range h.subs {
+		f := s.filter.Load()
+		if !f.Match(f.target(&ev)) {
+			continue
+		}
+		select {
+		case s.events <- ev:
+			s.dropped.Store(0)
+		default:
+			if dropped := s.dropped.Add(1); h.maxDropped > 0 && dropped >= int64(h.maxDropped) {
+				// The handler notices Done and calls Unsubscribe.
+				s.close()
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package stream
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// publish publishes a record of hex with the given callsign in the merged state.
+func publish(h *Hub, hex, callsign string) {
+	h.Publish(&models.AircraftData{HexIdent: hex}, &models.AircraftData{HexIdent: hex, Callsign: callsign})
+}
+
+func isDone(s *Subscriber) bool {
+	select {
+	case <-s.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHubFiltersPerSubscriber(t *testing.T) {
+	h := NewHub(10, 0)
+	all := h.Subscribe(Filter{Mode: ModeRecord})
+	one := h.Subscribe(Filter{Mode: ModeRecord, Hex: map[string]struct{}{"3C6586": {}}})
+	// The record carries no callsign, so only the state matches.
+	byState := h.Subscribe(Filter{Mode: ModeState, CallsignPrefix: "DLH"})
+	byRecord := h.Subscribe(Filter{Mode: ModeRecord, CallsignPrefix: "DLH"})
+
+	publish(h, "4CA2D6", "EIN123")
+	publish(h, "3C6586", "DLH4AB")
+
+	for _, tt := range []struct {
+		name string
+		sub  *Subscriber
+		want []string
+	}{
+		{"all", all, []string{"4CA2D6", "3C6586"}},
+		{"one hex", one, []string{"3C6586"}},
+		{"state callsign", byState, []string{"3C6586"}},
+		{"record callsign", byRecord, nil},
+	} {
+		var got []string
+		for len(tt.sub.Events()) > 0 {
+			ev := <-tt.sub.Events()
+			got = append(got, ev.Record.HexIdent)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: received %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHubDisconnectsSlowSubscriber(t *testing.T) {
+	h := NewHub(2, 3)
+	slow := h.Subscribe(Filter{Mode: ModeRecord})
+	fast := h.Subscribe(Filter{Mode: ModeRecord})
+	drain := func() {
+		for len(fast.Events()) > 0 {
+			<-fast.Events()
+		}
+	}
+
+	// Two events fill the queue, then two are dropped.
+	for range 4 {
+		publish(h, "4CA2D6", "")
+		drain()
+	}
+	if isDone(slow) {
+		t.Fatal("disconnected after 2 dropped events, want 3")
+	}
+	// A delivered event resets the count of consecutive drops.
+	<-slow.Events()
+	publish(h, "4CA2D6", "")
+	publish(h, "4CA2D6", "")
+	publish(h, "4CA2D6", "")
+	drain()
+	if isDone(slow) {
+		t.Fatal("disconnected after 2 consecutive dropped events, want 3")
+	}
+	publish(h, "4CA2D6", "")
+	drain()
+	if !isDone(slow) {
+		t.Fatal("not disconnected after 3 consecutive dropped events")
+	}
+	if isDone(fast) {
+		t.Error("disconnected a subscriber that keeps up")
+	}
+
+	h.Unsubscribe(slow)
+	h.Unsubscribe(slow)
+	if h.Len() != 1 {
+		t.Errorf("Len = %d after unsubscribing the slow subscriber, want 1", h.Len())
+	}
+}
+
+func TestHubKeepsSubscribersWithoutLimit(t *testing.T) {
+	h := NewHub(1, 0)
+	s := h.Subscribe(Filter{Mode: ModeRecord})
+	for range 100 {
+		publish(h, "4CA2D6", "")
+	}
+	if isDone(s) || len(s.Events()) != 1 {
+		t.Errorf("done %t with %d queued events, want connected with 1", isDone(s), len(s.Events()))
+	}
+}
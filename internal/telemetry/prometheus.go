@@ -0,0 +1,189 @@
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const prometheusNamespace = "dump1090_collector"
+
+// WritePrometheus renders the process-local counters in the Prometheus text
+// exposition format (version 0.0.4).
+func (t *Telemetry) WritePrometheus(w io.Writer) error {
+	pw := &promWriter{w: w}
+
+	pw.counter("lines_received_total", "Raw SBS-1 lines read from dump1090.", t.stats.linesRead.Load())
+	pw.counter("lines_dropped_total", "Raw lines dropped because the raw data channel was full.", t.stats.linesDropped.Load())
+	pw.counter("parse_errors_total", "Lines that could not be parsed.", t.stats.parseErrors.Load())
+
+	t.fieldErrorsMu.Lock()
+	fieldErrors := make(map[fieldKey]int64, len(t.fieldErrorCounts))
+	for k, v := range t.fieldErrorCounts {
+		fieldErrors[k] = v
+	}
+	t.fieldErrorsMu.Unlock()
+	if len(fieldErrors) > 0 {
+		keys := make([]fieldKey, 0, len(fieldErrors))
+		for k := range fieldErrors {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].source != keys[j].source {
+				return keys[i].source < keys[j].source
+			}
+			return keys[i].field < keys[j].field
+		})
+		pw.header("field_errors_total", "SBS-1 fields that could not be decoded, by source and field.", "counter")
+		for _, k := range keys {
+			pw.sample("field_errors_total", promLabels("source", k.source, "field", k.field), strconv.FormatInt(fieldErrors[k], 10))
+		}
+	}
+
+	if stages := t.Stages(); len(stages) > 0 {
+		names := make([]string, 0, len(stages))
+		for name := range stages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		pw.header("pipeline_dropped_total", "Records dropped by a pipeline stage, by stage.", "counter")
+		for _, name := range names {
+			pw.sample("pipeline_dropped_total", promLabels("stage", name), strconv.FormatInt(stages[name].Dropped, 10))
+		}
+		pw.header("pipeline_errors_total", "Records a pipeline stage failed to process, by stage.", "counter")
+		for _, name := range names {
+			pw.sample("pipeline_errors_total", promLabels("stage", name), strconv.FormatInt(stages[name].Errors, 10))
+		}
+		pw.header("pipeline_counts_total", "Counters of the pipeline stages, by stage and counter.", "counter")
+		for _, name := range names {
+			counters := stages[name].Counters
+			keys := make([]string, 0, len(counters))
+			for counter := range counters {
+				keys = append(keys, counter)
+			}
+			sort.Strings(keys)
+			for _, counter := range keys {
+				pw.sample("pipeline_counts_total", promLabels("stage", name, "counter", counter), strconv.FormatInt(counters[counter], 10))
+			}
+		}
+	}
+
+	pw.counter("batches_written_total", "Batches successfully written to the time-series database.", t.stats.batchesWritten.Load())
+	pw.counter("records_written_total", "Records successfully written to the time-series database.", t.stats.recordsWritten.Load())
+	pw.counter("write_errors_total", "Failed batch writes.", t.stats.writeErrors.Load())
+	pw.counter("reconnects_total", "Times the dump1090 connection was lost and re-established.", t.stats.reconnects.Load())
+
+	pw.counter("config_reloads_total", "Configuration reloads attempted.", t.stats.reloads.Load())
+	pw.counter("config_reload_failures_total", "Configuration reloads rejected because the new configuration was invalid or could not be applied.", t.stats.reloadFailures.Load())
+	if last := t.lastReload.Load(); last != 0 {
+		success := "1"
+		if t.lastReloadFailed.Load() {
+			success = "0"
+		}
+		pw.header("config_last_reload_successful", "Whether the last configuration reload was applied (1) or rejected (0).", "gauge")
+		pw.sample("config_last_reload_successful", "", success)
+		pw.header("config_last_reload_timestamp_seconds", "Unix time of the last configuration reload.", "gauge")
+		pw.sample("config_last_reload_timestamp_seconds", "", strconv.FormatFloat(float64(last)/1e9, 'f', 3, 64))
+	}
+
+	t.stats.writeDurationMu.Lock()
+	sum, count := t.stats.writeDurationSum, t.stats.writeDurationCount
+	t.stats.writeDurationMu.Unlock()
+	pw.header("write_duration_seconds", "Time taken to write a batch.", "summary")
+	pw.sample("write_duration_seconds_sum", "", strconv.FormatFloat(sum, 'g', -1, 64))
+	pw.sample("write_duration_seconds_count", "", strconv.FormatInt(count, 10))
+
+	pw.header("connected", "Whether the collector is connected to a dump1090 source (1) or not (0).", "gauge")
+	sources := t.ConnectedSources()
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		connected := "0"
+		if sources[name] {
+			connected = "1"
+		}
+		pw.sample("connected", promLabels("source", name), connected)
+	}
+
+	pw.header("last_successful_write_timestamp_seconds", "Unix time of the last successful batch write.", "gauge")
+	pw.sample("last_successful_write_timestamp_seconds", "", strconv.FormatFloat(float64(t.lastWriteSuccess.Load())/1e9, 'f', 3, 64))
+
+	if skews := t.ClockSkews(); len(skews) > 0 {
+		pw.header("receiver_clock_skew_seconds", "Average difference between the collector's clock and a receiver's message timestamps; positive when the receiver is behind.", "gauge")
+		sources := make([]string, 0, len(skews))
+		for source := range skews {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		for _, source := range sources {
+			pw.sample("receiver_clock_skew_seconds", promLabels("source", source), strconv.FormatFloat(skews[source].Seconds(), 'f', 3, 64))
+		}
+	}
+
+	t.queuesMu.Lock()
+	queues := append([]queueGauge(nil), t.queues...)
+	t.queuesMu.Unlock()
+	if len(queues) > 0 {
+		pw.header("queue_length", "Number of items currently buffered in an internal channel.", "gauge")
+		for _, q := range queues {
+			pw.sample("queue_length", promLabels("queue", q.name), strconv.Itoa(q.length()))
+		}
+		pw.header("queue_capacity", "Capacity of an internal channel.", "gauge")
+		for _, q := range queues {
+			pw.sample("queue_capacity", promLabels("queue", q.name), strconv.Itoa(q.capacity))
+		}
+	}
+
+	return pw.err
+}
+
+// promWriter writes exposition lines and remembers the first write error.
+type promWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (pw *promWriter) header(name, help, kind string) {
+	if pw.err != nil {
+		return
+	}
+	_, pw.err = fmt.Fprintf(pw.w, "# HELP %s_%s %s\n# TYPE %s_%s %s\n", prometheusNamespace, name, help, prometheusNamespace, name, kind)
+}
+
+func (pw *promWriter) sample(name, labels, value string) {
+	if pw.err != nil {
+		return
+	}
+	_, pw.err = fmt.Fprintf(pw.w, "%s_%s%s %s\n", prometheusNamespace, name, labels, value)
+}
+
+// labelEscaper escapes a label value. The exposition format only knows the
+// \\, \" and \n escapes, so other characters are written as they are.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promLabels formats the given name and value pairs as a label set, e.g. {source="rx"}.
+func promLabels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (pw *promWriter) counter(name, help string, value int64) {
+	pw.header(name, help, "counter")
+	pw.sample(name, "", strconv.FormatInt(value, 10))
+}
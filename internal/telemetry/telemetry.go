@@ -0,0 +1,515 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+const instrumentationName = "github.com/m03315/go-dump1090-timeseries-collector"
+
+// Options configures the OTLP/HTTP export of collector telemetry.
+type Options struct {
+	// Endpoint is the base URL of the OTLP/HTTP receiver, e.g. "http://localhost:4318".
+	// The signal paths (/v1/metrics, /v1/traces) are appended to it.
+	// An empty Endpoint disables export; the Telemetry methods are then no-ops.
+	Endpoint       string
+	ExportInterval time.Duration
+	TracesEnabled  bool
+	ServiceName    string
+	// TracerProvider, if set, receives the batch traces instead of the OTLP
+	// exporter, e.g. that of a program embedding the collector.
+	TracerProvider trace.TracerProvider
+}
+
+// queueGauge describes a channel whose occupancy is reported on every collection.
+type queueGauge struct {
+	name     string
+	length   func() int
+	capacity int
+}
+
+// Telemetry records collector health metrics and per-batch traces.
+// The zero-cost no-op providers are used when OTLP export is disabled.
+type Telemetry struct {
+	meterProvider  *sdkmetric.MeterProvider
+	tracerProvider *sdktrace.TracerProvider
+	tracer         trace.Tracer
+
+	linesRead      metric.Int64Counter
+	parseErrors    metric.Int64Counter
+	fieldErrors    metric.Int64Counter
+	batchesWritten metric.Int64Counter
+	recordsWritten metric.Int64Counter
+	writeErrors    metric.Int64Counter
+	writeLatency   metric.Float64Histogram
+	linesDropped   metric.Int64Counter
+	reconnects     metric.Int64Counter
+	configReloads  metric.Int64Counter
+	stageDropped   metric.Int64Counter
+	stageErrors    metric.Int64Counter
+	stageCounters  metric.Int64Counter
+
+	queuesMu sync.Mutex
+	queues   []queueGauge
+
+	clockSkewMu sync.Mutex
+	clockSkew   func() map[string]time.Duration
+
+	// Process-local state, served in Prometheus format and used for readiness.
+	stats            stats
+	connectedMu      sync.Mutex
+	connected        map[string]bool // per dump1090 source
+	fieldErrorsMu    sync.Mutex
+	fieldErrorCounts map[fieldKey]int64
+	stagesMu         sync.Mutex
+	stageCounts      map[string]StageStats // per pipeline stage
+	lastWriteSuccess atomic.Int64          // unix nanoseconds
+	lastWriteFailure atomic.Int64          // unix nanoseconds
+	writeDue         atomic.Int64          // unix nanoseconds the first batch queued since the last successful write was queued, 0 if none
+	lastReload       atomic.Int64          // unix nanoseconds, 0 before the first reload
+	lastReloadFailed atomic.Bool
+}
+
+// fieldKey identifies a field error counter.
+type fieldKey struct {
+	source string
+	field  string
+}
+
+// StageStats counts what a pipeline stage did to the records.
+type StageStats struct {
+	Dropped  int64
+	Errors   int64
+	Counters map[string]int64 // counted by the stage itself, e.g. lookup misses
+}
+
+// stats holds the in-process counters mirrored from the OTel instruments.
+type stats struct {
+	linesRead      atomic.Int64
+	linesDropped   atomic.Int64
+	parseErrors    atomic.Int64
+	batchesWritten atomic.Int64
+	recordsWritten atomic.Int64
+	writeErrors    atomic.Int64
+	reconnects     atomic.Int64
+	reloads        atomic.Int64
+	reloadFailures atomic.Int64
+
+	writeDurationMu    sync.Mutex
+	writeDurationSum   float64
+	writeDurationCount int64
+}
+
+// New creates the metric instruments and, if an endpoint is configured,
+// the OTLP/HTTP metric and trace exporters.
+func New(ctx context.Context, opts Options) (*Telemetry, error) {
+	t := &Telemetry{
+		connected:        make(map[string]bool),
+		fieldErrorCounts: make(map[fieldKey]int64),
+		stageCounts:      make(map[string]StageStats),
+	}
+	// Treat startup as the last successful write so readiness has a grace period.
+	t.lastWriteSuccess.Store(time.Now().UnixNano())
+
+	var meter metric.Meter
+	if opts.Endpoint == "" {
+		meter = metricnoop.NewMeterProvider().Meter(instrumentationName)
+		t.tracer = tracenoop.NewTracerProvider().Tracer(instrumentationName)
+	} else {
+		endpoint, err := url.Parse(opts.Endpoint)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid OTLP endpoint %q", opts.Endpoint)
+		}
+		basePath := strings.TrimSuffix(endpoint.Path, "/")
+		insecure := endpoint.Scheme != "https"
+
+		res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(opts.ServiceName),
+		))
+		if err != nil {
+			return nil, fmt.Errorf("failed to build OTel resource: %w", err)
+		}
+
+		metricOpts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(endpoint.Host),
+			otlpmetrichttp.WithURLPath(basePath + "/v1/metrics"),
+		}
+		if insecure {
+			metricOpts = append(metricOpts, otlpmetrichttp.WithInsecure())
+		}
+		metricExporter, err := otlpmetrichttp.New(ctx, metricOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+		}
+		t.meterProvider = sdkmetric.NewMeterProvider(
+			sdkmetric.WithResource(res),
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(opts.ExportInterval))),
+		)
+		meter = t.meterProvider.Meter(instrumentationName)
+
+		if opts.TracesEnabled {
+			traceOpts := []otlptracehttp.Option{
+				otlptracehttp.WithEndpoint(endpoint.Host),
+				otlptracehttp.WithURLPath(basePath + "/v1/traces"),
+			}
+			if insecure {
+				traceOpts = append(traceOpts, otlptracehttp.WithInsecure())
+			}
+			traceExporter, err := otlptracehttp.New(ctx, traceOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+			}
+			t.tracerProvider = sdktrace.NewTracerProvider(
+				sdktrace.WithResource(res),
+				sdktrace.WithBatcher(traceExporter),
+			)
+			t.tracer = t.tracerProvider.Tracer(instrumentationName)
+		} else {
+			t.tracer = tracenoop.NewTracerProvider().Tracer(instrumentationName)
+		}
+	}
+
+	if opts.TracerProvider != nil {
+		t.tracer = opts.TracerProvider.Tracer(instrumentationName)
+	}
+
+	var err error
+	if t.linesRead, err = meter.Int64Counter("collector.lines.read",
+		metric.WithDescription("Raw SBS-1 lines read from dump1090")); err != nil {
+		return nil, err
+	}
+	if t.parseErrors, err = meter.Int64Counter("collector.parse.errors",
+		metric.WithDescription("Lines that could not be parsed")); err != nil {
+		return nil, err
+	}
+	if t.fieldErrors, err = meter.Int64Counter("collector.parse.field_errors",
+		metric.WithDescription("SBS-1 fields that could not be decoded, by source and field")); err != nil {
+		return nil, err
+	}
+	if t.batchesWritten, err = meter.Int64Counter("collector.batches.written",
+		metric.WithDescription("Batches successfully written to the time-series database")); err != nil {
+		return nil, err
+	}
+	if t.recordsWritten, err = meter.Int64Counter("collector.records.written",
+		metric.WithDescription("Records successfully written to the time-series database")); err != nil {
+		return nil, err
+	}
+	if t.writeErrors, err = meter.Int64Counter("collector.write.errors",
+		metric.WithDescription("Failed batch writes")); err != nil {
+		return nil, err
+	}
+	if t.writeLatency, err = meter.Float64Histogram("collector.write.duration",
+		metric.WithDescription("Time taken to write a batch"), metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+
+	if t.linesDropped, err = meter.Int64Counter("collector.lines.dropped",
+		metric.WithDescription("Raw lines dropped because the raw data channel was full")); err != nil {
+		return nil, err
+	}
+	if t.reconnects, err = meter.Int64Counter("collector.reconnects",
+		metric.WithDescription("Times the dump1090 connection was lost and re-established")); err != nil {
+		return nil, err
+	}
+	if t.configReloads, err = meter.Int64Counter("collector.config.reloads",
+		metric.WithDescription("Configuration reloads, by result")); err != nil {
+		return nil, err
+	}
+
+	if t.stageDropped, err = meter.Int64Counter("collector.pipeline.dropped",
+		metric.WithDescription("Records dropped by a pipeline stage, by stage")); err != nil {
+		return nil, err
+	}
+	if t.stageErrors, err = meter.Int64Counter("collector.pipeline.errors",
+		metric.WithDescription("Records a pipeline stage failed to process, by stage")); err != nil {
+		return nil, err
+	}
+	if t.stageCounters, err = meter.Int64Counter("collector.pipeline.counts",
+		metric.WithDescription("Counters of the pipeline stages, by stage and counter")); err != nil {
+		return nil, err
+	}
+
+	queueLength, err := meter.Int64ObservableGauge("collector.queue.length",
+		metric.WithDescription("Number of items currently buffered in an internal channel"))
+	if err != nil {
+		return nil, err
+	}
+	queueCapacity, err := meter.Int64ObservableGauge("collector.queue.capacity",
+		metric.WithDescription("Capacity of an internal channel"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		t.queuesMu.Lock()
+		defer t.queuesMu.Unlock()
+		for _, q := range t.queues {
+			attrs := metric.WithAttributes(attribute.String("queue", q.name))
+			o.ObserveInt64(queueLength, int64(q.length()), attrs)
+			o.ObserveInt64(queueCapacity, int64(q.capacity), attrs)
+		}
+		return nil
+	}, queueLength, queueCapacity); err != nil {
+		return nil, err
+	}
+
+	receiverSkew, err := meter.Float64ObservableGauge("collector.receiver.clock_skew",
+		metric.WithDescription("Average difference between the collector's clock and a receiver's message timestamps"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for source, skew := range t.ClockSkews() {
+			o.ObserveFloat64(receiverSkew, skew.Seconds(), metric.WithAttributes(attribute.String("source", source)))
+		}
+		return nil
+	}, receiverSkew); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ObserveClockSkew registers the function that reports each receiver's clock skew
+// as collector.receiver.clock_skew.
+func (t *Telemetry) ObserveClockSkew(skews func() map[string]time.Duration) {
+	t.clockSkewMu.Lock()
+	defer t.clockSkewMu.Unlock()
+	t.clockSkew = skews
+}
+
+// ClockSkews returns the clock skew of every receiver, or nil if none is observed.
+func (t *Telemetry) ClockSkews() map[string]time.Duration {
+	t.clockSkewMu.Lock()
+	skews := t.clockSkew
+	t.clockSkewMu.Unlock()
+	if skews == nil {
+		return nil
+	}
+	return skews()
+}
+
+// ObserveQueue registers a channel whose current length is reported as collector.queue.length.
+func (t *Telemetry) ObserveQueue(name string, length func() int, capacity int) {
+	t.queuesMu.Lock()
+	defer t.queuesMu.Unlock()
+	t.queues = append(t.queues, queueGauge{name: name, length: length, capacity: capacity})
+}
+
+// LineRead counts a raw line received from dump1090.
+func (t *Telemetry) LineRead(ctx context.Context) {
+	t.linesRead.Add(ctx, 1)
+	t.stats.linesRead.Add(1)
+}
+
+// LineDropped counts a raw line dropped because the pipeline could not keep up.
+func (t *Telemetry) LineDropped(ctx context.Context) {
+	t.linesDropped.Add(ctx, 1)
+	t.stats.linesDropped.Add(1)
+}
+
+// ParseError counts a line that failed to parse.
+func (t *Telemetry) ParseError(ctx context.Context) {
+	t.parseErrors.Add(ctx, 1)
+	t.stats.parseErrors.Add(1)
+}
+
+// FieldErrors counts the fields of a message from the named source that could not be decoded.
+func (t *Telemetry) FieldErrors(ctx context.Context, source string, fields []string) {
+	t.fieldErrorsMu.Lock()
+	defer t.fieldErrorsMu.Unlock()
+	for _, field := range fields {
+		t.fieldErrors.Add(ctx, 1, metric.WithAttributes(attribute.String("source", source), attribute.String("field", field)))
+		t.fieldErrorCounts[fieldKey{source: source, field: field}]++
+	}
+}
+
+// StageDropped counts a record dropped by the named pipeline stage.
+func (t *Telemetry) StageDropped(ctx context.Context, stage string) {
+	t.stageDropped.Add(ctx, 1, metric.WithAttributes(attribute.String("stage", stage)))
+	t.stagesMu.Lock()
+	defer t.stagesMu.Unlock()
+	counts := t.stageCounts[stage]
+	counts.Dropped++
+	t.stageCounts[stage] = counts
+}
+
+// StageError counts a record the named pipeline stage failed to process.
+func (t *Telemetry) StageError(ctx context.Context, stage string) {
+	t.stageErrors.Add(ctx, 1, metric.WithAttributes(attribute.String("stage", stage)))
+	t.stagesMu.Lock()
+	defer t.stagesMu.Unlock()
+	counts := t.stageCounts[stage]
+	counts.Errors++
+	t.stageCounts[stage] = counts
+}
+
+// StageCount adds n to a counter of the named pipeline stage.
+func (t *Telemetry) StageCount(ctx context.Context, stage, counter string, n int64) {
+	t.stageCounters.Add(ctx, n, metric.WithAttributes(attribute.String("stage", stage), attribute.String("counter", counter)))
+	t.stagesMu.Lock()
+	defer t.stagesMu.Unlock()
+	counts := t.stageCounts[stage]
+	if counts.Counters == nil {
+		counts.Counters = make(map[string]int64)
+	}
+	counts.Counters[counter] += n
+	t.stageCounts[stage] = counts
+}
+
+// Stages returns the counts of every pipeline stage that dropped or failed a
+// record, or counted something.
+func (t *Telemetry) Stages() map[string]StageStats {
+	t.stagesMu.Lock()
+	defer t.stagesMu.Unlock()
+	stages := make(map[string]StageStats, len(t.stageCounts))
+	for name, counts := range t.stageCounts {
+		counts.Counters = maps.Clone(counts.Counters)
+		stages[name] = counts
+	}
+	return stages
+}
+
+// Reconnect counts a lost dump1090 connection that is being re-established.
+func (t *Telemetry) Reconnect(ctx context.Context) {
+	t.reconnects.Add(ctx, 1)
+	t.stats.reconnects.Add(1)
+}
+
+// ConfigReloaded records the outcome of a configuration reload; err is nil when it was applied.
+func (t *Telemetry) ConfigReloaded(ctx context.Context, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+		t.stats.reloadFailures.Add(1)
+	}
+	t.configReloads.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+	t.stats.reloads.Add(1)
+	t.lastReloadFailed.Store(err != nil)
+	t.lastReload.Store(time.Now().UnixNano())
+}
+
+// SetConnected records whether the collector currently has a live connection to the named dump1090 source.
+// A source replaying capture files is connected while the replay runs.
+func (t *Telemetry) SetConnected(source string, connected bool) {
+	t.connectedMu.Lock()
+	t.connected[source] = connected
+	t.connectedMu.Unlock()
+}
+
+// Connected reports whether the collector currently has a live connection to at least one dump1090 source.
+func (t *Telemetry) Connected() bool {
+	t.connectedMu.Lock()
+	defer t.connectedMu.Unlock()
+	for _, connected := range t.connected {
+		if connected {
+			return true
+		}
+	}
+	return false
+}
+
+// ConnectedSources returns the connection state of every dump1090 source seen so far.
+func (t *Telemetry) ConnectedSources() map[string]bool {
+	t.connectedMu.Lock()
+	defer t.connectedMu.Unlock()
+	sources := make(map[string]bool, len(t.connected))
+	for name, connected := range t.connected {
+		sources[name] = connected
+	}
+	return sources
+}
+
+// BatchWritten records a successful batch write and its latency.
+func (t *Telemetry) BatchWritten(ctx context.Context, records int, latency time.Duration) {
+	t.batchesWritten.Add(ctx, 1)
+	t.recordsWritten.Add(ctx, int64(records))
+	t.writeLatency.Record(ctx, latency.Seconds(), metric.WithAttributes(attribute.Bool("success", true)))
+
+	t.stats.batchesWritten.Add(1)
+	t.stats.recordsWritten.Add(int64(records))
+	t.observeWriteDuration(latency)
+	t.lastWriteSuccess.Store(time.Now().UnixNano())
+	t.writeDue.Store(0)
+}
+
+// WriteFailed records a failed batch write and its latency.
+func (t *Telemetry) WriteFailed(ctx context.Context, latency time.Duration) {
+	t.writeErrors.Add(ctx, 1)
+	t.writeLatency.Record(ctx, latency.Seconds(), metric.WithAttributes(attribute.Bool("success", false)))
+
+	t.stats.writeErrors.Add(1)
+	t.observeWriteDuration(latency)
+	t.lastWriteFailure.Store(time.Now().UnixNano())
+}
+
+// BatchQueued records that a batch is waiting to be written.
+func (t *Telemetry) BatchQueued() {
+	t.writeDue.CompareAndSwap(0, time.Now().UnixNano())
+}
+
+// WriteDue returns when the first batch queued since the last successful
+// write was queued, or the zero time if none has been.
+func (t *Telemetry) WriteDue() time.Time {
+	if ns := t.writeDue.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+// LastWriteSuccess returns the time of the last successful batch write
+// (or the process start time if nothing has been written yet).
+func (t *Telemetry) LastWriteSuccess() time.Time {
+	return time.Unix(0, t.lastWriteSuccess.Load())
+}
+
+// LastWriteFailure returns the time of the last failed batch write, or the zero time.
+func (t *Telemetry) LastWriteFailure() time.Time {
+	if ns := t.lastWriteFailure.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+func (t *Telemetry) observeWriteDuration(latency time.Duration) {
+	t.stats.writeDurationMu.Lock()
+	t.stats.writeDurationSum += latency.Seconds()
+	t.stats.writeDurationCount++
+	t.stats.writeDurationMu.Unlock()
+}
+
+// Tracer returns the tracer used for batch spans (a no-op tracer when tracing is disabled).
+func (t *Telemetry) Tracer() trace.Tracer {
+	return t.tracer
+}
+
+// Shutdown flushes any pending telemetry and stops the exporters.
+func (t *Telemetry) Shutdown(ctx context.Context) error {
+	var errs []error
+	if t.meterProvider != nil {
+		errs = append(errs, t.meterProvider.Shutdown(ctx))
+	}
+	if t.tracerProvider != nil {
+		errs = append(errs, t.tracerProvider.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,140 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	metricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// receiver is an OTLP/HTTP endpoint recording the exported metric names and spans.
+type receiver struct {
+	mu      sync.Mutex
+	paths   map[string]int
+	metrics map[string]bool
+	spans   map[string]map[string]int64 // span name to its integer attributes
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil || req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/x-protobuf" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths[req.URL.Path]++
+	switch req.URL.Path {
+	case "/otlp/v1/metrics":
+		var export metricpb.ExportMetricsServiceRequest
+		if err := proto.Unmarshal(body, &export); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, rm := range export.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					r.metrics[m.Name] = true
+				}
+			}
+		}
+	case "/otlp/v1/traces":
+		var export tracepb.ExportTraceServiceRequest
+		if err := proto.Unmarshal(body, &export); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, rs := range export.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					attrs := make(map[string]int64)
+					for _, kv := range s.Attributes {
+						attrs[kv.Key] = kv.Value.GetIntValue()
+					}
+					r.spans[s.Name] = attrs
+				}
+			}
+		}
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func TestExportOTLP(t *testing.T) {
+	r := &receiver{paths: make(map[string]int), metrics: make(map[string]bool), spans: make(map[string]map[string]int64)}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx := context.Background()
+	tel, err := New(ctx, Options{
+		Endpoint:       srv.URL + "/otlp/",
+		ExportInterval: time.Hour, // exported by Shutdown
+		TracesEnabled:  true,
+		ServiceName:    "collector-test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tel.LineRead(ctx)
+	tel.ParseError(ctx)
+	tel.BatchWritten(ctx, 3, 20*time.Millisecond)
+	tel.WriteFailed(ctx, time.Second)
+	_, span := tel.Tracer().Start(ctx, "collector.batch")
+	span.SetAttributes(attribute.Int("lines", 4), attribute.Int("parse_errors", 1), attribute.Int("records", 3))
+	span.End()
+	if err := tel.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.paths["/otlp/v1/metrics"] == 0 || r.paths["/otlp/v1/traces"] == 0 {
+		t.Fatalf("received %v, want POSTs to /otlp/v1/metrics and /otlp/v1/traces", r.paths)
+	}
+	for _, name := range []string{
+		"collector.lines.read",
+		"collector.parse.errors",
+		"collector.batches.written",
+		"collector.records.written",
+		"collector.write.errors",
+		"collector.write.duration",
+	} {
+		if !r.metrics[name] {
+			t.Errorf("metric %s not exported, got %v", name, r.metrics)
+		}
+	}
+	attrs, ok := r.spans["collector.batch"]
+	if !ok {
+		t.Fatalf("span collector.batch not exported, got %v", r.spans)
+	}
+	for key, want := range map[string]int64{"lines": 4, "parse_errors": 1, "records": 3} {
+		if attrs[key] != want {
+			t.Errorf("span attribute %s = %d, want %d", key, attrs[key], want)
+		}
+	}
+}
+
+func TestPromLabels(t *testing.T) {
+	tests := []struct {
+		pairs []string
+		want  string
+	}{
+		{[]string{"source", "rx"}, `{source="rx"}`},
+		{[]string{"stage", "filter", "counter", "misses"}, `{stage="filter",counter="misses"}`},
+		{[]string{"source", `C:\capture "a"` + "\n"}, `{source="C:\\capture \"a\"\n"}`},
+		{[]string{"source", "Côte\t\x01"}, "{source=\"Côte\t\x01\"}"}, // no \t, \u or \x escapes
+	}
+	for _, tt := range tests {
+		if got := promLabels(tt.pairs...); got != tt.want {
+			t.Errorf("promLabels(%q) = %s, want %s", tt.pairs, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package timeseries
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/bst"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// bstStateTTL is how long an aircraft's last known state is kept for filling
+// in later snapshots after its last message.
+const bstStateTTL = 10 * time.Minute
+
+// BSTWriter implements TimeSeriesWriter by appending BaseStation BST lines to a
+// file, so that tooling from the BaseStation era can consume current data.
+//
+// Like BaseStation, every line is a snapshot of the aircraft's last known
+// state, so the writer merges incoming messages per aircraft and writes one
+// line per transmission. Events are not written, but RM/AD forget the aircraft.
+type BSTWriter struct {
+	path string
+
+	mu       sync.Mutex
+	file     *os.File
+	writer   *bst.Writer
+	aircraft map[string]*bstAircraft
+}
+
+type bstAircraft struct {
+	state    models.AircraftData
+	lastSeen time.Time
+}
+
+// NewBSTWriter opens (or creates) the BST file at path for appending.
+// Timestamps are written in loc, the time zone BaseStation tooling expects.
+func NewBSTWriter(path string, loc *time.Location) (*BSTWriter, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open BST file: %w", err)
+	}
+	return &BSTWriter{
+		path:     path,
+		file:     file,
+		writer:   bst.NewWriter(file, loc),
+		aircraft: make(map[string]*bstAircraft),
+	}, nil
+}
+
+// WriteBatch implements the TimeSeriesWriter interface for BST files.
+func (bw *BSTWriter) WriteBatch(ctx context.Context, batch []models.AircraftData) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	now := time.Now()
+	lines := 0
+	for _, data := range batch {
+		if data.HexIdent == "" {
+			continue
+		}
+		if data.IsGone() {
+			delete(bw.aircraft, data.HexIdent)
+			continue
+		}
+		if data.IsEvent() {
+			continue
+		}
+
+		ac := bw.aircraft[data.HexIdent]
+		if ac == nil {
+			ac = &bstAircraft{}
+			bw.aircraft[data.HexIdent] = ac
+		}
+		ac.state.Merge(&data)
+		ac.lastSeen = now
+
+		if err := bw.writer.Write(&ac.state); err != nil {
+			return fmt.Errorf("bst write error: %w", err)
+		}
+		lines++
+	}
+
+	for hex, ac := range bw.aircraft {
+		if now.Sub(ac.lastSeen) > bstStateTTL {
+			delete(bw.aircraft, hex)
+		}
+	}
+
+	log.Printf("Writing batch of %d lines to BST file %s...", lines, bw.path)
+	if err := bw.writer.Flush(); err != nil {
+		return fmt.Errorf("bst write error: %w", err)
+	}
+	return nil
+}
+
+// Close implements the TimeSeriesWriter interface.
+func (bw *BSTWriter) Close() error {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if bw.file == nil {
+		return nil
+	}
+	flushErr := bw.writer.Flush()
+	closeErr := bw.file.Close()
+	bw.file = nil
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
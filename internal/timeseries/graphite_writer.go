@@ -0,0 +1,372 @@
+package timeseries
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+const (
+	// graphiteAircraftWindow is how long an aircraft counts towards the
+	// receiver's aircraft_count metric after its last message.
+	graphiteAircraftWindow = 60 * time.Second
+	// graphitePickleChunk caps the number of metrics per pickle frame so a
+	// single large batch does not exceed Carbon's maximum message size.
+	graphitePickleChunk = 500
+	graphiteDialTimeout = 10 * time.Second
+)
+
+// graphiteMetric is a single datapoint in Carbon terms.
+type graphiteMetric struct {
+	path      string
+	value     float64
+	timestamp int64
+}
+
+// GraphiteWriter implements TimeSeriesWriter for Graphite / Carbon using either
+// the plaintext (port 2003) or pickle (port 2004) protocol over TCP.
+type GraphiteWriter struct {
+	address         string
+	protocol        string
+	prefixTemplate  string
+	defaultReceiver string
+
+	mu        sync.Mutex
+	conn      net.Conn
+	lastBatch time.Time
+	lastSeen  map[string]map[string]*graphiteAircraft // receiver -> hex
+}
+
+// graphiteAircraft is what the writer remembers about an aircraft for the
+// receiver-level aggregates.
+type graphiteAircraft struct {
+	lastSeen time.Time // collector time of its last message
+	country  string    // models.TagCountry, set by the icao pipeline stage
+	military string    // models.TagMilitary
+}
+
+// NewGraphiteWriter creates and returns a new GraphiteWriter.
+// The prefix is a dot-separated metric path in which "{receiver}" is replaced by
+// the sanitized name of the receiver that heard the message, e.g. "adsb.{receiver}".
+// defaultReceiver is used for records that carry no receiver name.
+// The connection is established lazily and re-established after write failures.
+func NewGraphiteWriter(address, protocol, prefix, defaultReceiver string) (*GraphiteWriter, error) {
+	if protocol != "plaintext" && protocol != "pickle" {
+		return nil, fmt.Errorf("unsupported graphite protocol: %s", protocol)
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid graphite address %q: %w", address, err)
+	}
+
+	return &GraphiteWriter{
+		address:         address,
+		protocol:        protocol,
+		prefixTemplate:  prefix,
+		defaultReceiver: defaultReceiver,
+		lastSeen:        make(map[string]map[string]*graphiteAircraft),
+	}, nil
+}
+
+// WriteBatch implements the TimeSeriesWriter interface for Graphite.
+func (gw *GraphiteWriter) WriteBatch(ctx context.Context, batch []models.AircraftData) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	now := time.Now()
+	metrics := make([]graphiteMetric, 0, len(batch)*4+3)
+	messages := make(map[string]int)          // per receiver
+	events := make(map[string]map[string]int) // per receiver, per event type
+
+	for _, data := range batch {
+		receiver := data.Receiver
+		if receiver == "" {
+			receiver = gw.defaultReceiver
+		}
+		if data.IsCoverage() {
+			// e.g. <prefix>.coverage.10000-20000.45.max_distance_km
+			base := gw.path(receiver, "coverage."+sanitizeGraphiteKey(data.Tags[models.TagAltitudeBand])+"."+sanitizeGraphiteKey(data.Tags[models.TagBearing]))
+			for key, value := range data.Fields {
+				metrics = append(metrics, graphiteMetric{path: base + "." + sanitizeGraphiteKey(key), value: value, timestamp: data.Timestamp.Unix()})
+			}
+			continue
+		}
+		messages[receiver]++
+		if data.HexIdent == "" {
+			continue
+		}
+		if gw.lastSeen[receiver] == nil {
+			gw.lastSeen[receiver] = make(map[string]*graphiteAircraft)
+		}
+		ac := gw.lastSeen[receiver][data.HexIdent]
+		if ac == nil {
+			ac = &graphiteAircraft{}
+			gw.lastSeen[receiver][data.HexIdent] = ac
+		}
+		ac.lastSeen = now
+		if country, ok := data.Tags[models.TagCountry]; ok {
+			ac.country = country
+		}
+		if military, ok := data.Tags[models.TagMilitary]; ok {
+			ac.military = military
+		}
+
+		if data.IsEvent() {
+			if events[receiver] == nil {
+				events[receiver] = make(map[string]int)
+			}
+			eventType := data.MessageType
+			if data.Status != "" {
+				eventType += "_" + data.Status
+			}
+			events[receiver][strings.ToLower(eventType)]++
+			if data.IsGone() {
+				delete(gw.lastSeen[receiver], data.HexIdent)
+			}
+			continue
+		}
+
+		ts := data.Timestamp.Unix()
+		base := gw.path(receiver, sanitizeGraphiteKey(data.HexIdent))
+		add := func(name string, value float64) {
+			metrics = append(metrics, graphiteMetric{path: base + "." + name, value: value, timestamp: ts})
+		}
+
+		if data.Altitude != nil {
+			add("altitude_ft", float64(*data.Altitude))
+		}
+		if data.GroundSpeed != nil {
+			add("ground_speed_kts", *data.GroundSpeed)
+		}
+		if data.Track != nil {
+			add("track_deg", *data.Track)
+		}
+		if data.Latitude != nil {
+			add("latitude", *data.Latitude)
+		}
+		if data.Longitude != nil {
+			add("longitude", *data.Longitude)
+		}
+		if data.VerticalRate != nil {
+			add("vertical_rate_fpm", float64(*data.VerticalRate))
+		}
+		if data.Alert != nil {
+			add("alert", boolToFloat(*data.Alert))
+		}
+		if data.Emergency != nil {
+			add("emergency", boolToFloat(*data.Emergency))
+		}
+		if data.SPI != nil {
+			add("spi", boolToFloat(*data.SPI))
+		}
+		if data.IsOnGround != nil {
+			add("is_on_ground", boolToFloat(*data.IsOnGround))
+		}
+		for key, value := range data.Fields {
+			add(sanitizeGraphiteKey(key), value)
+		}
+	}
+
+	// Receiver-level aggregates, timestamped with the collector's clock.
+	for receiver, seen := range gw.lastSeen {
+		countries := make(map[string]int)
+		military, enriched := 0, false
+		for hex, ac := range seen {
+			if now.Sub(ac.lastSeen) > graphiteAircraftWindow {
+				delete(seen, hex)
+				continue
+			}
+			if ac.country != "" {
+				countries[ac.country]++
+			}
+			if ac.military != "" {
+				enriched = true
+				if ac.military == "true" {
+					military++
+				}
+			}
+		}
+		statsBase := gw.path(receiver, "stats")
+		metrics = append(metrics,
+			graphiteMetric{path: statsBase + ".messages", value: float64(messages[receiver]), timestamp: now.Unix()},
+			graphiteMetric{path: statsBase + ".aircraft_count", value: float64(len(seen)), timestamp: now.Unix()},
+		)
+		if !gw.lastBatch.IsZero() {
+			if elapsed := now.Sub(gw.lastBatch).Seconds(); elapsed > 0 {
+				metrics = append(metrics, graphiteMetric{path: statsBase + ".message_rate", value: float64(messages[receiver]) / elapsed, timestamp: now.Unix()})
+			}
+		}
+		for eventType, count := range events[receiver] {
+			metrics = append(metrics, graphiteMetric{path: statsBase + ".events." + sanitizeGraphiteKey(eventType), value: float64(count), timestamp: now.Unix()})
+		}
+		for country, count := range countries {
+			metrics = append(metrics, graphiteMetric{path: statsBase + ".countries." + sanitizeGraphiteKey(country) + ".aircraft_count", value: float64(count), timestamp: now.Unix()})
+		}
+		if enriched {
+			metrics = append(metrics, graphiteMetric{path: statsBase + ".military_aircraft_count", value: float64(military), timestamp: now.Unix()})
+		}
+		if len(seen) == 0 {
+			delete(gw.lastSeen, receiver)
+		}
+	}
+	gw.lastBatch = now
+
+	var payloads [][]byte
+	if gw.protocol == "pickle" {
+		for start := 0; start < len(metrics); start += graphitePickleChunk {
+			end := min(start+graphitePickleChunk, len(metrics))
+			payloads = append(payloads, encodePickleFrame(metrics[start:end]))
+		}
+	} else {
+		payloads = append(payloads, encodePlaintext(metrics))
+	}
+
+	log.Printf("Writing batch of %d metrics to Graphite (%s, %s)...", len(metrics), gw.address, gw.protocol)
+	for _, payload := range payloads {
+		if err := gw.send(ctx, payload); err != nil {
+			return fmt.Errorf("graphite write error: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close implements the TimeSeriesWriter interface.
+func (gw *GraphiteWriter) Close() error {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	if gw.conn != nil {
+		err := gw.conn.Close()
+		gw.conn = nil
+		return err
+	}
+	return nil
+}
+
+// path renders the prefix for the given receiver and joins a metric name onto it.
+func (gw *GraphiteWriter) path(receiver, name string) string {
+	prefix := strings.ReplaceAll(gw.prefixTemplate, "{receiver}", sanitizeGraphiteKey(receiver))
+	prefix = strings.Trim(prefix, ".")
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// send writes the payload, reconnecting once if the existing connection has
+// gone away. After a partial write, only the metrics not yet written in full
+// are sent again: the remaining lines in plaintext, the whole frame in pickle,
+// as Carbon discards a truncated frame. Metrics written before the connection
+// failed may still have been lost with it.
+func (gw *GraphiteWriter) send(ctx context.Context, payload []byte) error {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		if gw.conn == nil {
+			dialer := net.Dialer{Timeout: graphiteDialTimeout}
+			conn, err := dialer.DialContext(ctx, "tcp", gw.address)
+			if err != nil {
+				return fmt.Errorf("failed to connect to carbon at %s: %w", gw.address, err)
+			}
+			gw.conn = conn
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = gw.conn.SetWriteDeadline(deadline)
+		} else {
+			_ = gw.conn.SetWriteDeadline(time.Time{})
+		}
+
+		n, err := gw.conn.Write(payload)
+		if err == nil {
+			return nil
+		}
+		if gw.protocol == "plaintext" {
+			// Resume at the start of the first line not written in full.
+			payload = payload[bytes.LastIndexByte(payload[:n], '\n')+1:]
+		}
+		lastErr = err
+		log.Printf("Warning: Graphite connection to %s failed (%v), reconnecting...", gw.address, err)
+		_ = gw.conn.Close()
+		gw.conn = nil
+	}
+	return lastErr
+}
+
+// sanitizeGraphiteKey makes a string safe to use as a single Graphite path
+// component: dots, whitespace and other special characters become underscores.
+func sanitizeGraphiteKey(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "unknown"
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// encodePlaintext renders metrics in Carbon's line protocol: "<path> <value> <timestamp>\n".
+func encodePlaintext(metrics []graphiteMetric) []byte {
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		buf.WriteString(m.path)
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(m.value, 'f', -1, 64))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(m.timestamp, 10))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// encodePickleFrame renders metrics as a length-prefixed pickle (protocol 2) of
+// [(path, (timestamp, value)), ...], which is what Carbon's pickle receiver expects.
+func encodePickleFrame(metrics []graphiteMetric) []byte {
+	var body bytes.Buffer
+	body.Write([]byte{0x80, 0x02}) // PROTO 2
+	body.WriteByte(']')            // EMPTY_LIST
+	body.WriteByte('(')            // MARK
+	for _, m := range metrics {
+		body.WriteByte('X') // BINUNICODE
+		_ = binary.Write(&body, binary.LittleEndian, uint32(len(m.path)))
+		body.WriteString(m.path)
+		body.WriteByte('G') // BINFLOAT
+		_ = binary.Write(&body, binary.BigEndian, math.Float64bits(float64(m.timestamp)))
+		body.WriteByte('G') // BINFLOAT
+		_ = binary.Write(&body, binary.BigEndian, math.Float64bits(m.value))
+		body.WriteByte(0x86) // TUPLE2 (timestamp, value)
+		body.WriteByte(0x86) // TUPLE2 (path, (timestamp, value))
+	}
+	body.WriteByte('e') // APPENDS
+	body.WriteByte('.') // STOP
+
+	frame := make([]byte, 4, 4+body.Len())
+	binary.BigEndian.PutUint32(frame, uint32(body.Len()))
+	return append(frame, body.Bytes()...)
+}
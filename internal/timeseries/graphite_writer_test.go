@@ -0,0 +1,143 @@
+package timeseries
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSanitizeGraphiteKey(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"home", "home"},
+		{"roof-2_a", "roof-2_a"},
+		{"192.168.1.10", "192_168_1_10"},
+		{" attic ", "attic"},
+		{"my receiver", "my_receiver"},
+		{"a/b*c", "a_b_c"},
+		{"Côte", "C_te"},
+		{"", "unknown"},
+		{"   ", "unknown"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeGraphiteKey(tt.in); got != tt.want {
+			t.Errorf("sanitizeGraphiteKey(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEncodePlaintext(t *testing.T) {
+	got := encodePlaintext([]graphiteMetric{
+		{path: "adsb.home.4CA2D6.altitude", value: 37000, timestamp: 1700000000},
+		{path: "a.b", value: -1.5, timestamp: 1},
+	})
+	want := "adsb.home.4CA2D6.altitude 37000 1700000000\na.b -1.5 1\n"
+	if string(got) != want {
+		t.Errorf("encodePlaintext = %q, want %q", got, want)
+	}
+}
+
+func TestEncodePickleFrame(t *testing.T) {
+	// The frames were checked with Python's pickle.loads, which reads them as
+	// [] and [('adsb.home.4CA2D6.altitude', (1700000000.0, 37000.0)), ('a.b', (1.0, -1.5))].
+	tests := []struct {
+		name    string
+		metrics []graphiteMetric
+		want    string
+	}{
+		{"empty", nil, "00000006" + "80025d28652e"},
+		{"two metrics", []graphiteMetric{
+			{path: "adsb.home.4CA2D6.altitude", value: 37000, timestamp: 1700000000},
+			{path: "a.b", value: -1.5, timestamp: 1},
+		}, "00000054" + "80025d28" +
+			"5819000000616473622e686f6d652e3443413244362e616c746974756465" + "4741d954fc40000000" + "4740e2110000000000" + "8686" +
+			"5803000000612e62" + "473ff0000000000000" + "47bff8000000000000" + "8686" +
+			"652e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(encodePickleFrame(tt.metrics)); got != tt.want {
+				t.Errorf("encodePickleFrame =\n  %s\nwant\n  %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// brokenConn accepts limit bytes, then fails every write.
+type brokenConn struct {
+	net.Conn
+	limit   int
+	written bytes.Buffer
+}
+
+func (c *brokenConn) Write(p []byte) (int, error) {
+	n := min(len(p), c.limit-c.written.Len())
+	c.written.Write(p[:n])
+	if n < len(p) {
+		return n, errors.New("connection reset by peer")
+	}
+	return n, nil
+}
+
+func (c *brokenConn) SetWriteDeadline(time.Time) error { return nil }
+func (c *brokenConn) Close() error                     { return nil }
+
+func TestSendResumesAfterPartialWrite(t *testing.T) {
+	plaintext := encodePlaintext([]graphiteMetric{
+		{path: "a.first", value: 1, timestamp: 1700000000},
+		{path: "a.second", value: 2, timestamp: 1700000000},
+		{path: "a.third", value: 3, timestamp: 1700000000},
+	})
+	pickle := encodePickleFrame([]graphiteMetric{{path: "a.first", value: 1, timestamp: 1700000000}})
+	tests := []struct {
+		name     string
+		protocol string
+		payload  []byte
+		limit    int
+		resent   []byte
+	}{
+		{"plaintext, nothing written", "plaintext", plaintext, 0, plaintext},
+		{"plaintext, within the second line", "plaintext", plaintext, len("a.first 1 1700000000\na.sec"), []byte("a.second 2 1700000000\na.third 3 1700000000\n")},
+		{"plaintext, at a line boundary", "plaintext", plaintext, len("a.first 1 1700000000\n"), []byte("a.second 2 1700000000\na.third 3 1700000000\n")},
+		{"pickle, within the frame", "pickle", pickle, 10, pickle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			received := make(chan []byte, 1)
+			go func() {
+				conn, err := ln.Accept()
+				if err != nil {
+					received <- nil
+					return
+				}
+				defer conn.Close()
+				data, _ := io.ReadAll(conn)
+				received <- data
+			}()
+
+			gw, err := NewGraphiteWriter(ln.Addr().String(), tt.protocol, "adsb", "home")
+			if err != nil {
+				t.Fatal(err)
+			}
+			gw.conn = &brokenConn{limit: tt.limit}
+			if err := gw.send(context.Background(), tt.payload); err != nil {
+				t.Fatal(err)
+			}
+			gw.Close()
+			if got := <-received; !bytes.Equal(got, tt.resent) {
+				t.Errorf("sent again %q, want %q", got, tt.resent)
+			}
+		})
+	}
+}
@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"log"
 	"net/http" // Ensure this is imported for http.Client
-	"time"
 
 	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"github.com/influxdata/line-protocol/v2/lineprotocol"
 	"github.com/m03315/go-dump1090-timeseries-collector/models"
 )
 
@@ -44,10 +44,22 @@ func (iw *InfluxDBWriter) WriteBatch(ctx context.Context, batch []models.Aircraf
 	pointsToWrite := make([]*influxdb3.Point, 0, len(batch))
 
 	for _, data := range batch {
+		if data.IsCoverage() {
+			pointsToWrite = append(pointsToWrite, newCoveragePoint(&data))
+			continue
+		}
+		if data.IsEvent() {
+			pointsToWrite = append(pointsToWrite, newEventPoint(&data))
+			continue
+		}
+
 		point := influxdb3.NewPointWithMeasurement("aircraft_sbs1").
-			SetTimestamp(data.GeneratedTimestamp)
+			SetTimestamp(data.Timestamp)
 
 		// Set Tags
+		if data.Receiver != "" {
+			point.SetTag("receiver", data.Receiver)
+		}
 		point.SetTag("message_type", data.MessageType)
 		if data.TransmissionType != "" {
 			point.SetTag("transmission_type", data.TransmissionType)
@@ -59,6 +71,9 @@ func (iw *InfluxDBWriter) WriteBatch(ctx context.Context, batch []models.Aircraf
 		if data.Squawk != "" {
 			point.SetTag("squawk", data.Squawk)
 		}
+		for key, value := range data.Tags {
+			point.SetTag(key, value)
+		}
 
 		// Set Fields
 		if data.SessionID != nil {
@@ -70,7 +85,7 @@ func (iw *InfluxDBWriter) WriteBatch(ctx context.Context, batch []models.Aircraf
 		if data.FlightID != nil {
 			point.SetField("flight_id", *data.FlightID)
 		}
-		point.SetField("logged_timestamp_unix_ms", data.LoggedTimestamp.UnixNano()/int64(time.Millisecond))
+		setTimestampFields(point, &data)
 		if data.Altitude != nil {
 			point.SetField("altitude_ft", *data.Altitude)
 		}
@@ -101,6 +116,9 @@ func (iw *InfluxDBWriter) WriteBatch(ctx context.Context, batch []models.Aircraf
 		if data.IsOnGround != nil {
 			point.SetField("is_on_ground", *data.IsOnGround)
 		}
+		for key, value := range data.Fields {
+			point.SetField(key, value)
+		}
 
 		if point.HasFields() {
 			pointsToWrite = append(pointsToWrite, point)
@@ -110,13 +128,92 @@ func (iw *InfluxDBWriter) WriteBatch(ctx context.Context, batch []models.Aircraf
 	}
 
 	log.Printf("Writing batch of %d points to InfluxDB 3.x (database: %s)...", len(pointsToWrite), iw.database)
-	err := iw.client.WritePoints(ctx, pointsToWrite)
+	// SBS-1 timestamps have millisecond resolution; write them as such rather than padding to nanoseconds.
+	err := iw.client.WritePoints(ctx, pointsToWrite, influxdb3.WithPrecision(lineprotocol.Millisecond))
 	if err != nil {
 		return fmt.Errorf("influxdb write error: %w", err)
 	}
 	return nil
 }
 
+// newEventPoint builds an "aircraft_events" point for an AIR, ID, STA, SEL or CLK message
+// or a zone_enter / zone_exit event, so that appearances, disappearances and status transitions can be queried separately from transmissions.
+func newEventPoint(data *models.AircraftData) *influxdb3.Point {
+	point := influxdb3.NewPointWithMeasurement("aircraft_events").
+		SetTimestamp(data.Timestamp)
+
+	if data.Receiver != "" {
+		point.SetTag("receiver", data.Receiver)
+	}
+	point.SetTag("event_type", data.MessageType)
+	point.SetTag("hex_ident", data.HexIdent)
+	if data.Callsign != "" {
+		point.SetTag("callsign", data.Callsign)
+	}
+	if data.Status != "" {
+		point.SetTag("status", data.Status)
+	}
+	for key, value := range data.Tags {
+		point.SetTag(key, value)
+	}
+
+	point.SetField("count", 1)
+	if data.SessionID != nil {
+		point.SetField("session_id", *data.SessionID)
+	}
+	if data.AircraftID != nil {
+		point.SetField("aircraft_id", *data.AircraftID)
+	}
+	if data.FlightID != nil {
+		point.SetField("flight_id", *data.FlightID)
+	}
+	// Zone events record where the aircraft crossed the boundary.
+	if data.Altitude != nil {
+		point.SetField("altitude_ft", *data.Altitude)
+	}
+	if data.Latitude != nil {
+		point.SetField("latitude", *data.Latitude)
+	}
+	if data.Longitude != nil {
+		point.SetField("longitude", *data.Longitude)
+	}
+	for key, value := range data.Fields {
+		point.SetField(key, value)
+	}
+	setTimestampFields(point, data)
+	return point
+}
+
+// newCoveragePoint builds a "receiver_coverage" point for a coverage summary:
+// the farthest distance at which a receiver saw aircraft in a bearing sector
+// and altitude band.
+func newCoveragePoint(data *models.AircraftData) *influxdb3.Point {
+	point := influxdb3.NewPointWithMeasurement("receiver_coverage").
+		SetTimestamp(data.Timestamp)
+
+	if data.Receiver != "" {
+		point.SetTag("receiver", data.Receiver)
+	}
+	for key, value := range data.Tags {
+		point.SetTag(key, value)
+	}
+	for key, value := range data.Fields {
+		point.SetField(key, value)
+	}
+	return point
+}
+
+// setTimestampFields records the receiver's timestamps that were not chosen as
+// the point time, so that none of them are lost.
+func setTimestampFields(point *influxdb3.Point, data *models.AircraftData) {
+	if !data.LoggedTimestamp.IsZero() {
+		point.SetField("logged_timestamp_unix_ms", data.LoggedTimestamp.UnixMilli())
+	}
+	if !data.GeneratedTimestamp.IsZero() && !data.GeneratedTimestamp.Equal(data.Timestamp) {
+		point.SetField("generated_timestamp_unix_ms", data.GeneratedTimestamp.UnixMilli())
+	}
+}
+
 // Close implements the TimeSeriesWriter interface.
 func (iw *InfluxDBWriter) Close() error {
 	if iw.client != nil {
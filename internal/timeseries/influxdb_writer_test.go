@@ -0,0 +1,42 @@
+package timeseries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/line-protocol/v2/lineprotocol"
+	"github.com/m03315/go-dump1090-timeseries-collector/internal/parser"
+)
+
+func TestNewEventPoint(t *testing.T) {
+	const header = ",,333,5,4CA2D6,1458,2008/11/28,14:53:49.986,2008/11/28,14:53:50.010"
+	const fields = "aircraft_id=5i,count=1i,flight_id=1458i,logged_timestamp_unix_ms=1227884030010i,session_id=333i 1227884029986\n"
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"AIR", "AIR" + header, "aircraft_events,event_type=AIR,hex_ident=4CA2D6,receiver=rx " + fields},
+		{"ID", "ID" + header + ",RYR1427", "aircraft_events,callsign=RYR1427,event_type=ID,hex_ident=4CA2D6,receiver=rx " + fields},
+		{"STA", "STA" + header + ",RM", "aircraft_events,event_type=STA,hex_ident=4CA2D6,receiver=rx,status=RM " + fields},
+		{"SEL", "SEL" + header + ",RYR1427", "aircraft_events,callsign=RYR1427,event_type=SEL,hex_ident=4CA2D6,receiver=rx " + fields},
+		{"CLK", "CLK" + header, "aircraft_events,event_type=CLK,hex_ident=4CA2D6,receiver=rx " + fields},
+	}
+	p := parser.NewParser(parser.Options{Strict: true})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := p.Parse(tt.line, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			data.Receiver = "rx"
+			got, err := newEventPoint(data).MarshalBinary(lineprotocol.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("point =\n  %s\nwant\n  %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package timeseries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// MultiWriter implements TimeSeriesWriter by writing every batch to several
+// sinks concurrently. A batch fails if any sink fails; the error names the
+// sinks that did. Batches are not retried, so the sinks that succeeded keep
+// the batch and those that failed lose it.
+type MultiWriter struct {
+	names   []string
+	writers []TimeSeriesWriter
+}
+
+// NewMultiWriter combines writers, labelled by names in errors.
+func NewMultiWriter(names []string, writers []TimeSeriesWriter) *MultiWriter {
+	return &MultiWriter{names: names, writers: writers}
+}
+
+// WriteBatch implements the TimeSeriesWriter interface.
+func (mw *MultiWriter) WriteBatch(ctx context.Context, batch []models.AircraftData) error {
+	errs := make([]error, len(mw.writers))
+	var wg sync.WaitGroup
+	for i, writer := range mw.writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := writer.WriteBatch(ctx, batch); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", mw.names[i], err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Close implements the TimeSeriesWriter interface, closing every sink.
+func (mw *MultiWriter) Close() error {
+	var errs []error
+	for i, writer := range mw.writers {
+		if err := writer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", mw.names[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
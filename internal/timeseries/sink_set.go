@@ -0,0 +1,124 @@
+package timeseries
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// SinkSet implements TimeSeriesWriter by writing to every sink of the
+// configuration. Its sinks can be replaced while it is in use: Reload only
+// opens the sinks that are new or changed and closes those that are gone.
+type SinkSet struct {
+	mu     sync.RWMutex // held for reading during writes, so that a sink is never closed mid-write
+	sinks  []openSink
+	writer TimeSeriesWriter // all sinks combined
+}
+
+// openSink is a sink writer together with the settings it was opened with.
+type openSink struct {
+	key    sinkKey
+	writer TimeSeriesWriter
+}
+
+// sinkKey holds everything a sink writer depends on: a sink is reopened when it changes.
+type sinkKey struct {
+	sink     config.SinkConfig
+	receiver string // Graphite paths
+	timezone string // BST timestamps
+}
+
+func newSinkKey(cfg *config.Config, sink config.SinkConfig) sinkKey {
+	return sinkKey{sink: sink, receiver: cfg.Receiver.Name, timezone: cfg.Receiver.Timezone}
+}
+
+// NewSinkSet opens a writer for every sink in cfg.Sinks.
+func NewSinkSet(cfg *config.Config) (*SinkSet, error) {
+	s := &SinkSet{}
+	if _, _, err := s.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload switches to the sinks in cfg.Sinks, reusing the writers of unchanged
+// sinks. It returns the types of the sinks it opened and closed. If a sink
+// fails to open, the current sinks are kept.
+func (s *SinkSet) Reload(cfg *config.Config) (opened, closed []string, err error) {
+	s.mu.RLock()
+	unused := append([]openSink(nil), s.sinks...)
+	s.mu.RUnlock()
+
+	sinks := make([]openSink, 0, len(cfg.Sinks))
+	var added []openSink
+	for i, sink := range cfg.Sinks {
+		key := newSinkKey(cfg, sink)
+		reused := false
+		for j, old := range unused {
+			if old.key == key {
+				sinks = append(sinks, old)
+				unused = append(unused[:j], unused[j+1:]...)
+				reused = true
+				break
+			}
+		}
+		if reused {
+			continue
+		}
+		writer, err := newSinkWriter(cfg, sink)
+		if err != nil {
+			for _, sink := range added {
+				sink.writer.Close()
+			}
+			return nil, nil, fmt.Errorf("sinks[%d]: %w", i, err)
+		}
+		sinks = append(sinks, openSink{key: key, writer: writer})
+		added = append(added, openSink{key: key, writer: writer})
+		opened = append(opened, sink.Type)
+	}
+
+	s.mu.Lock()
+	s.sinks = sinks
+	s.writer = combine(sinks)
+	s.mu.Unlock()
+
+	for _, old := range unused {
+		if err := old.writer.Close(); err != nil {
+			log.Printf("Error closing %s writer: %v", old.key.sink.Type, err)
+		}
+		closed = append(closed, old.key.sink.Type)
+	}
+	return opened, closed, nil
+}
+
+// combine returns the writer for a list of sinks: the sink itself when there
+// is only one, a MultiWriter otherwise.
+func combine(sinks []openSink) TimeSeriesWriter {
+	if len(sinks) == 1 {
+		return sinks[0].writer
+	}
+	names := make([]string, len(sinks))
+	writers := make([]TimeSeriesWriter, len(sinks))
+	for i, sink := range sinks {
+		names[i], writers[i] = sink.key.sink.Type, sink.writer
+	}
+	return NewMultiWriter(names, writers)
+}
+
+// WriteBatch implements the TimeSeriesWriter interface.
+func (s *SinkSet) WriteBatch(ctx context.Context, batch []models.AircraftData) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.writer.WriteBatch(ctx, batch)
+}
+
+// Close implements the TimeSeriesWriter interface, closing every sink.
+func (s *SinkSet) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writer.Close()
+}
@@ -2,7 +2,13 @@ package timeseries
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
 	"github.com/m03315/go-dump1090-timeseries-collector/models"
+	"log"
+	"net/http"
+	"time"
 )
 
 // TimeSeriesWriter defines the interface for writing batches of aircraft data
@@ -15,3 +21,62 @@ type TimeSeriesWriter interface {
 	// Close cleans up resources (e.g., closes database connections).
 	Close() error
 }
+
+// NewWriterFromConfig creates a writer for every sink in cfg.Sinks. With more
+// than one sink, batches are written to all of them.
+func NewWriterFromConfig(cfg *config.Config) (TimeSeriesWriter, error) {
+	if len(cfg.Sinks) == 0 {
+		return nil, errors.New("no sinks configured")
+	}
+	return NewSinkSet(cfg)
+}
+
+// newSinkWriter creates the writer for a single sink.
+func newSinkWriter(cfg *config.Config, sink config.SinkConfig) (TimeSeriesWriter, error) {
+	switch sink.Type {
+	case "influxdb":
+		httpClient := &http.Client{
+			Timeout: 30 * time.Second,
+		}
+		writer, err := NewInfluxDBWriter(
+			sink.URL,
+			sink.Token,
+			sink.Database,
+			httpClient,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize InfluxDB writer: %w", err)
+		}
+		log.Println("Initialized InfluxDB writer.")
+		return writer, nil
+	case "graphite":
+		writer, err := NewGraphiteWriter(
+			sink.Address,
+			sink.Protocol,
+			sink.Prefix,
+			cfg.Receiver.Name,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Graphite writer: %w", err)
+		}
+		log.Println("Initialized Graphite writer.")
+		return writer, nil
+	case "bst":
+		loc, err := time.LoadLocation(cfg.Receiver.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid receiver time zone %q: %w", cfg.Receiver.Timezone, err)
+		}
+		writer, err := NewBSTWriter(sink.Path, loc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize BST writer: %w", err)
+		}
+		log.Printf("Initialized BST writer (%s).", sink.Path)
+		return writer, nil
+	case "prometheus":
+		// Example placeholder for Prometheus
+		// return NewPrometheusWriter(cfg.PrometheusPushGatewayURL)
+		return nil, fmt.Errorf("prometheus output type not yet implemented")
+	default:
+		return nil, fmt.Errorf("unsupported output type: %s", sink.Type)
+	}
+}
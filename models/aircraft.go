@@ -4,6 +4,7 @@ import "time"
 
 // AircraftData represents the parsed information from an SBS-1 message.
 type AircraftData struct {
+	Receiver           string // name of the dump1090 source that received the message
 	MessageType        string
 	TransmissionType   string
 	SessionID          *int
@@ -12,6 +13,8 @@ type AircraftData struct {
 	FlightID           *int
 	GeneratedTimestamp time.Time
 	LoggedTimestamp    time.Time
+	ReceivedTimestamp  time.Time // when the collector read the line
+	Timestamp          time.Time // point time: one of the above, chosen by the parser's timestamp source
 
 	Callsign     string
 	Altitude     *int
@@ -25,4 +28,105 @@ type AircraftData struct {
 	Emergency    *bool
 	SPI          *bool
 	IsOnGround   *bool
+
+	Status string // STA messages only: OK, PL, SL, RM or AD
+
+	Tags   map[string]string  // extra labels such as the country of registration, written as tags where the sink supports them
+	Fields map[string]float64 // extra values such as the distance from the receiver, written as fields where the sink supports them
+}
+
+// SetTag sets a tag, creating the map of tags if needed.
+func (a *AircraftData) SetTag(key, value string) {
+	if a.Tags == nil {
+		a.Tags = make(map[string]string)
+	}
+	a.Tags[key] = value
+}
+
+// SetField sets a field, creating the map of fields if needed.
+func (a *AircraftData) SetField(key string, value float64) {
+	if a.Fields == nil {
+		a.Fields = make(map[string]float64)
+	}
+	a.Fields[key] = value
+}
+
+// Merge overlays the fields present in update onto a, so that a holds the
+// latest known value of every field. Identity and timestamps are always taken
+// from update; optional fields are only replaced when update carries them.
+func (a *AircraftData) Merge(update *AircraftData) {
+	a.Receiver = update.Receiver
+	a.MessageType = update.MessageType
+	a.TransmissionType = update.TransmissionType
+	a.HexIdent = update.HexIdent
+	a.GeneratedTimestamp = update.GeneratedTimestamp
+	a.LoggedTimestamp = update.LoggedTimestamp
+	a.ReceivedTimestamp = update.ReceivedTimestamp
+	a.Timestamp = update.Timestamp
+
+	if update.SessionID != nil {
+		a.SessionID = update.SessionID
+	}
+	if update.AircraftID != nil {
+		a.AircraftID = update.AircraftID
+	}
+	if update.FlightID != nil {
+		a.FlightID = update.FlightID
+	}
+	if update.Callsign != "" {
+		a.Callsign = update.Callsign
+	}
+	if update.Altitude != nil {
+		a.Altitude = update.Altitude
+	}
+	if update.GroundSpeed != nil {
+		a.GroundSpeed = update.GroundSpeed
+	}
+	if update.Track != nil {
+		a.Track = update.Track
+	}
+	if update.Latitude != nil {
+		a.Latitude = update.Latitude
+	}
+	if update.Longitude != nil {
+		a.Longitude = update.Longitude
+	}
+	if update.VerticalRate != nil {
+		a.VerticalRate = update.VerticalRate
+	}
+	if update.Squawk != "" {
+		a.Squawk = update.Squawk
+	}
+	if update.Alert != nil {
+		a.Alert = update.Alert
+	}
+	if update.Emergency != nil {
+		a.Emergency = update.Emergency
+	}
+	if update.SPI != nil {
+		a.SPI = update.SPI
+	}
+	if update.IsOnGround != nil {
+		a.IsOnGround = update.IsOnGround
+	}
+	if len(update.Tags) > 0 {
+		tags := make(map[string]string, len(a.Tags)+len(update.Tags))
+		for k, v := range a.Tags {
+			tags[k] = v
+		}
+		for k, v := range update.Tags {
+			tags[k] = v
+		}
+		a.Tags = tags
+	}
+	if len(update.Fields) > 0 {
+		fields := make(map[string]float64, len(a.Fields)+len(update.Fields))
+		for k, v := range a.Fields {
+			fields[k] = v
+		}
+		for k, v := range update.Fields {
+			fields[k] = v
+		}
+		a.Fields = fields
+	}
 }
@@ -0,0 +1,59 @@
+package models
+
+// SBS-1 message types (field 1). MSG lines carry transmissions from the
+// aircraft itself; the others are events generated by the receiver software.
+const (
+	MessageTypeTransmission = "MSG" // transmission message, eight subtypes
+	MessageTypeNewAircraft  = "AIR" // a new aircraft signal was picked up
+	MessageTypeNewID        = "ID"  // an aircraft set or changed its callsign
+	MessageTypeStatus       = "STA" // an aircraft's status changed based on time-outs
+	MessageTypeSelection    = "SEL" // the user changed the selected aircraft
+	MessageTypeClick        = "CLK" // the user double-clicked an aircraft
+
+	// MessageTypeSnapshot marks a record read from a BaseStation BST log,
+	// which holds an aircraft's complete last known state rather than one message.
+	MessageTypeSnapshot = "BST"
+
+	// Events generated by the collector's geofence stage when an aircraft
+	// enters or leaves a zone, named by the "zone" tag.
+	MessageTypeZoneEnter = "zone_enter"
+	MessageTypeZoneExit  = "zone_exit"
+
+	// MessageTypeCoverage marks a summary generated by the collector's coverage
+	// stage, the farthest distance at which the receiver saw aircraft in a
+	// bearing sector and altitude band, rather than a record of an aircraft.
+	MessageTypeCoverage = "coverage"
+)
+
+// Status values carried by STA messages.
+const (
+	StatusOK           = "OK" // aircraft is being received normally
+	StatusPositionLost = "PL" // no position update within the time-out
+	StatusSignalLost   = "SL" // no message at all within the time-out
+	StatusRemoved      = "RM" // aircraft removed from the display
+	StatusDeleted      = "AD" // aircraft deleted from the session
+)
+
+// IsEvent reports whether the record is an event generated by the receiver
+// (AIR, ID, STA, SEL or CLK) or the collector (zone_enter, zone_exit) rather
+// than a transmission from the aircraft.
+func (a *AircraftData) IsEvent() bool {
+	switch a.MessageType {
+	case MessageTypeNewAircraft, MessageTypeNewID, MessageTypeStatus, MessageTypeSelection, MessageTypeClick,
+		MessageTypeZoneEnter, MessageTypeZoneExit:
+		return true
+	}
+	return false
+}
+
+// IsCoverage reports whether the record is a coverage summary rather than a
+// record of an aircraft.
+func (a *AircraftData) IsCoverage() bool {
+	return a.MessageType == MessageTypeCoverage
+}
+
+// IsGone reports whether the record is a STA event telling that the aircraft
+// has been removed or deleted by the receiver.
+func (a *AircraftData) IsGone() bool {
+	return a.MessageType == MessageTypeStatus && (a.Status == StatusRemoved || a.Status == StatusDeleted)
+}
@@ -0,0 +1,18 @@
+package models
+
+// Keys of AircraftData.Tags that the pipeline stages set and the sinks read.
+const (
+	// TagCountry holds the country that allocated the aircraft's address, i.e.
+	// its country of registration: the Country column of BST files.
+	TagCountry = "country"
+	// TagMilitary is "true" for addresses in a known military range and
+	// "false" otherwise.
+	TagMilitary = "military"
+
+	// TagBearing holds the start of the bearing sector of a coverage record,
+	// in degrees clockwise from true north.
+	TagBearing = "bearing"
+	// TagAltitudeBand names the altitude band of a coverage record, e.g.
+	// "10000-20000" or "30000+", in feet.
+	TagAltitudeBand = "altitude_band"
+)
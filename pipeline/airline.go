@@ -0,0 +1,221 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+const (
+	// TagAirline is the tag holding the name of the airline flying a callsign.
+	TagAirline = "airline"
+	// TagAirlineCountry is the tag holding the airline's country.
+	TagAirlineCountry = "airline_country"
+	// TagOrigin is the tag holding the airport a callsign's route departs from.
+	TagOrigin = "origin"
+	// TagDestination is the tag holding the airport a callsign's route arrives at.
+	TagDestination = "destination"
+)
+
+func init() {
+	Register("airline", func(_ *config.Config, stage config.StageConfig) (Processor, error) {
+		return NewAirline(stage.Airlines, stage.Routes, stage.WatchInterval)
+	})
+}
+
+// airlineColumns maps the normalized CSV header names of an airlines file to
+// the fields of an airline.
+var airlineColumns = map[string]string{
+	"icao":        "designator",
+	"designator":  "designator",
+	"icaocode":    "designator",
+	"airlineicao": "designator",
+	"name":        TagAirline,
+	"airline":     TagAirline,
+	"airlinename": TagAirline,
+	"country":     TagAirlineCountry,
+}
+
+// routeColumns maps the normalized CSV header names of a routes file to the
+// fields of a route. "airports" is a list of airports separated by dashes,
+// e.g. "EGLL-KJFK", as in Virtual Radar Server's standing data.
+var routeColumns = map[string]string{
+	"callsign":        "callsign",
+	"origin":          TagOrigin,
+	"originicao":      TagOrigin,
+	"from":            TagOrigin,
+	"departure":       TagOrigin,
+	"destination":     TagDestination,
+	"destinationicao": TagDestination,
+	"to":              TagDestination,
+	"arrival":         TagDestination,
+	"airports":        "airports",
+	"airportcodes":    "airports",
+	"route":           "airports",
+}
+
+// airline is an entry of the airlines file.
+type airline struct {
+	name    string
+	country string
+}
+
+// route is an entry of the routes file.
+type route struct {
+	origin      string
+	destination string
+}
+
+// Airline tags the records carrying a callsign with the airline whose ICAO
+// designator starts the callsign, e.g. "AFR" for AFR1234, and, if a routes
+// file is given, with the origin and destination of the callsign's route.
+// The files are loaded into memory and loaded again when they change.
+// Lookups are counted as the "airline_hits", "airline_misses", "route_hits"
+// and "route_misses" counters of the stage.
+type Airline struct {
+	airlines atomic.Pointer[map[string]airline]
+	routes   atomic.Pointer[map[string]route] // nil without a routes file
+	watchers []*fileWatcher
+}
+
+// NewAirline loads the airlines CSV file at airlinesPath and the optional
+// routes CSV file at routesPath, and checks them for changes every
+// watchInterval. Both files have a header row naming their columns. The
+// airlines file has a designator (or icao) column and name and country
+// columns. The routes file has a callsign column and either origin and
+// destination columns or an airports column such as "EGLL-KJFK".
+func NewAirline(airlinesPath, routesPath string, watchInterval time.Duration) (*Airline, error) {
+	a := &Airline{}
+	watcher, err := watchFile(airlinesPath, watchInterval, func(path string) error {
+		airlines, err := loadAirlines(path)
+		if err != nil {
+			return err
+		}
+		a.airlines.Store(&airlines)
+		log.Printf("Loaded %d airlines from %s.", len(airlines), path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load airlines: %w", err)
+	}
+	a.watchers = append(a.watchers, watcher)
+	if routesPath != "" {
+		watcher, err := watchFile(routesPath, watchInterval, func(path string) error {
+			routes, err := loadRoutes(path)
+			if err != nil {
+				return err
+			}
+			a.routes.Store(&routes)
+			log.Printf("Loaded %d routes from %s.", len(routes), path)
+			return nil
+		})
+		if err != nil {
+			a.Close()
+			return nil, fmt.Errorf("failed to load routes: %w", err)
+		}
+		a.watchers = append(a.watchers, watcher)
+	}
+	return a, nil
+}
+
+// Process implements the Processor interface.
+func (a *Airline) Process(ctx context.Context, data *models.AircraftData) (bool, error) {
+	callsign := strings.ToUpper(strings.TrimSpace(data.Callsign))
+	if callsign == "" {
+		return true, nil
+	}
+	if designator, ok := airlineDesignator(callsign); ok {
+		if al, ok := (*a.airlines.Load())[designator]; ok {
+			Count(ctx, "airline_hits", 1)
+			data.SetTag(TagAirline, al.name)
+			if al.country != "" {
+				data.SetTag(TagAirlineCountry, al.country)
+			}
+		} else {
+			Count(ctx, "airline_misses", 1)
+		}
+	}
+	if routes := a.routes.Load(); routes != nil {
+		if rt, ok := (*routes)[callsign]; ok {
+			Count(ctx, "route_hits", 1)
+			if rt.origin != "" {
+				data.SetTag(TagOrigin, rt.origin)
+			}
+			if rt.destination != "" {
+				data.SetTag(TagDestination, rt.destination)
+			}
+		} else {
+			Count(ctx, "route_misses", 1)
+		}
+	}
+	return true, nil
+}
+
+// Close stops watching the files.
+func (a *Airline) Close() error {
+	for _, w := range a.watchers {
+		w.Close()
+	}
+	return nil
+}
+
+// airlineDesignator returns the ICAO airline designator of an airline
+// callsign: three letters followed by the flight number. Callsigns that are
+// registrations, such as GABCD or N123AB, have none.
+func airlineDesignator(callsign string) (string, bool) {
+	if len(callsign) < 4 || callsign[3] < '0' || callsign[3] > '9' {
+		return "", false
+	}
+	for i := range 3 {
+		if callsign[i] < 'A' || callsign[i] > 'Z' {
+			return "", false
+		}
+	}
+	return callsign[:3], true
+}
+
+// loadAirlines reads an airlines CSV file. Rows without a designator or a
+// name are skipped.
+func loadAirlines(path string) (map[string]airline, error) {
+	airlines := make(map[string]airline)
+	shared := make(interner)
+	err := readCSV(path, airlineColumns, "designator", func(value func(string) string) {
+		designator, name := strings.ToUpper(value("designator")), value(TagAirline)
+		if len(designator) != 3 || name == "" {
+			return
+		}
+		airlines[strings.Clone(designator)] = airline{name: strings.Clone(name), country: shared.intern(value(TagAirlineCountry))}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return airlines, nil
+}
+
+// loadRoutes reads a routes CSV file. Rows without a callsign or airports are
+// skipped.
+func loadRoutes(path string) (map[string]route, error) {
+	routes := make(map[string]route)
+	shared := make(interner)
+	err := readCSV(path, routeColumns, "callsign", func(value func(string) string) {
+		callsign := strings.ToUpper(value("callsign"))
+		rt := route{origin: value(TagOrigin), destination: value(TagDestination)}
+		if airports := strings.Split(value("airports"), "-"); len(airports) >= 2 {
+			rt.origin, rt.destination = airports[0], airports[len(airports)-1]
+		}
+		if callsign == "" || (rt.origin == "" && rt.destination == "") {
+			return
+		}
+		routes[strings.Clone(callsign)] = route{origin: shared.intern(rt.origin), destination: shared.intern(rt.destination)}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+func TestAirlineDesignator(t *testing.T) {
+	tests := []struct {
+		callsign   string
+		designator string
+	}{
+		{"AFR1234", "AFR"},
+		{"BAW9", "BAW"},
+		{"EZY12AB", "EZY"},
+		{"GABCD", ""},  // UK registration
+		{"N123AB", ""}, // US registration
+		{"DAIBL", ""},
+		{"F-GKXA", ""},
+		{"AF1234", ""},
+		{"AFR", ""},
+		{"afr1234", ""}, // Process upper-cases callsigns first
+		{"", ""},
+	}
+	for _, tt := range tests {
+		designator, ok := airlineDesignator(tt.callsign)
+		if designator != tt.designator || ok != (tt.designator != "") {
+			t.Errorf("airlineDesignator(%q) = %q, %t; want %q", tt.callsign, designator, ok, tt.designator)
+		}
+	}
+}
+
+func TestAirline(t *testing.T) {
+	airlines := writeFile(t, "airlines.csv", "ICAO,Name,Country\nAFR,Air France,France\nbaw,British Airways,\nXX,Short,\nEZY,,\n")
+	routes := writeFile(t, "routes.csv", "Callsign,AirportCodes\nAFR1234,LFPG-EGLL\nBAW1,EGLL-OMDB-WSSS\nEZY1,\n")
+	a, err := NewAirline(airlines, routes, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	tests := []struct {
+		callsign string
+		tags     map[string]string
+		counts   map[string]int64
+	}{
+		{"AFR1234 ", map[string]string{TagAirline: "Air France", TagAirlineCountry: "France", TagOrigin: "LFPG", TagDestination: "EGLL"},
+			map[string]int64{"airline_hits": 1, "route_hits": 1}},
+		{"baw1", map[string]string{TagAirline: "British Airways", TagOrigin: "EGLL", TagDestination: "WSSS"},
+			map[string]int64{"airline_hits": 1, "route_hits": 1}},
+		{"EZY1", nil, map[string]int64{"airline_misses": 1, "route_misses": 1}},
+		{"GABCD", nil, map[string]int64{"route_misses": 1}},
+		{"", nil, map[string]int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.callsign, func(t *testing.T) {
+			counts := make(map[string]int64)
+			data := &models.AircraftData{Callsign: tt.callsign}
+			if keep, err := a.Process(counting(counts), data); !keep || err != nil {
+				t.Fatalf("Process = %t, %v; want true, nil", keep, err)
+			}
+			if !maps.Equal(data.Tags, tt.tags) {
+				t.Errorf("tags = %v, want %v", data.Tags, tt.tags)
+			}
+			if !maps.Equal(counts, tt.counts) {
+				t.Errorf("counts = %v, want %v", counts, tt.counts)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+const (
+	// TagBearing is the tag of a coverage record holding the start of its
+	// bearing sector, in degrees clockwise from true north.
+	TagBearing = models.TagBearing
+	// TagAltitudeBand is the tag of a coverage record naming its altitude
+	// band, e.g. "10000-20000" or "30000+", in feet.
+	TagAltitudeBand = models.TagAltitudeBand
+	// FieldMaxDistance is the field of a coverage record holding the farthest
+	// distance at which an aircraft was seen in the window, in kilometres.
+	FieldMaxDistance = "max_distance_km"
+	// FieldPositions is the field of a coverage record holding the number of
+	// positions seen in the window.
+	FieldPositions = "positions"
+
+	// coverageSlots is the number of slots the window is divided into: the
+	// window rolls forward one slot at a time.
+	coverageSlots = 12
+)
+
+func init() {
+	Register("coverage", func(cfg *config.Config, stage config.StageConfig) (Processor, error) {
+		return NewCoverage(sourcePositions(cfg), CoverageOptions{
+			SectorDeg:     stage.SectorDeg,
+			AltitudeBands: stage.AltitudeBands,
+			Window:        stage.Window,
+			Interval:      stage.Interval,
+			Output:        stage.Output,
+		})
+	})
+}
+
+// CoverageOptions configures a Coverage stage.
+type CoverageOptions struct {
+	SectorDeg     int           // width of the bearing sectors, a divisor of 360
+	AltitudeBands []int         // boundaries between the altitude bands in feet, ascending
+	Window        time.Duration // the maximum distances are those of the last Window
+	Interval      time.Duration // how often coverage records are emitted and Output written
+	Output        string        // GeoJSON file the coverage polygons are written to; "" for none
+}
+
+// Coverage measures the range of the receivers: it tracks the farthest
+// distance at which each receiver saw an aircraft in every bearing sector and
+// altitude band over a rolling window. Every interval, it emits a coverage
+// record for every sector and band in which aircraft were seen, and writes
+// the coverage of every receiver and band as a GeoJSON polygon. Positions
+// without an altitude, such as surface positions, and records of receivers
+// whose position is unknown are not counted.
+//
+// Time is that of the records, so that replays are aggregated as they were
+// received. The file is written in the background, so that a slow disk does
+// not hold up the pipeline; Close writes the last coverage.
+type Coverage struct {
+	positions map[string]Position
+	opts      CoverageOptions
+	sectors   int
+	receivers map[string]*receiverCoverage
+
+	writes chan []coverageFeature // the latest coverage not yet written; nil without Output
+	done   chan struct{}
+}
+
+// receiverCoverage is the coverage of one receiver.
+type receiverCoverage struct {
+	slots   [coverageSlots]coverageSlot
+	emitted time.Time // record time of the last coverage records
+}
+
+// coverageSlot holds the maxima of one part of the window, for every sector
+// of every band.
+type coverageSlot struct {
+	number    int64 // time / slot duration; the slot is reused for number + coverageSlots
+	distances []float64
+	positions []int64
+}
+
+// coverageFeature is a GeoJSON feature of the output file.
+type coverageFeature struct {
+	Type       string         `json:"type"`
+	Properties map[string]any `json:"properties"`
+	Geometry   map[string]any `json:"geometry"`
+}
+
+// NewCoverage creates a Coverage stage for receivers at the given positions,
+// keyed by receiver name.
+func NewCoverage(positions map[string]Position, opts CoverageOptions) (*Coverage, error) {
+	if opts.SectorDeg <= 0 || 360%opts.SectorDeg != 0 {
+		return nil, fmt.Errorf("invalid sector width %d: must be a divisor of 360", opts.SectorDeg)
+	}
+	if !sort.IntsAreSorted(opts.AltitudeBands) {
+		return nil, fmt.Errorf("altitude bands must be ascending")
+	}
+	if opts.Window <= 0 || opts.Interval <= 0 {
+		return nil, fmt.Errorf("window and interval must be positive")
+	}
+	c := &Coverage{
+		positions: positions,
+		opts:      opts,
+		sectors:   360 / opts.SectorDeg,
+		receivers: make(map[string]*receiverCoverage),
+	}
+	if opts.Output != "" {
+		c.writes, c.done = make(chan []coverageFeature, 1), make(chan struct{})
+		go c.writeLoop()
+	}
+	return c, nil
+}
+
+// Process implements the Processor interface.
+func (c *Coverage) Process(ctx context.Context, data *models.AircraftData) (bool, error) {
+	pos, ok := c.positions[data.Receiver]
+	if !ok {
+		return true, nil
+	}
+	rc := c.receivers[data.Receiver]
+	if rc == nil {
+		rc = &receiverCoverage{emitted: data.Timestamp}
+		c.receivers[data.Receiver] = rc
+	}
+
+	if data.Latitude != nil && data.Longitude != nil && data.Altitude != nil {
+		distance := distanceKm(pos.Latitude, pos.Longitude, *data.Latitude, *data.Longitude)
+		sector := int(bearingDeg(pos.Latitude, pos.Longitude, *data.Latitude, *data.Longitude)) / c.opts.SectorDeg
+		bin := c.band(*data.Altitude)*c.sectors + min(sector, c.sectors-1)
+		if slot := c.slot(rc, data.Timestamp); slot != nil {
+			slot.distances[bin] = max(slot.distances[bin], distance)
+			slot.positions[bin]++
+		}
+	}
+
+	if data.Timestamp.Sub(rc.emitted) < c.opts.Interval {
+		return true, nil
+	}
+	rc.emitted = data.Timestamp
+	c.emit(ctx, data.Receiver, rc, data.Timestamp)
+	if c.writes != nil {
+		c.queue(c.features(data.Timestamp))
+	}
+	return true, nil
+}
+
+// Close writes the last coverage queued and stops the writer.
+func (c *Coverage) Close() error {
+	if c.writes != nil {
+		close(c.writes)
+		<-c.done
+	}
+	return nil
+}
+
+// band returns the index of the altitude band of altitude.
+func (c *Coverage) band(altitude int) int {
+	return sort.Search(len(c.opts.AltitudeBands), func(i int) bool { return c.opts.AltitudeBands[i] > altitude })
+}
+
+// bandName names a band by its bounds in feet.
+func (c *Coverage) bandName(band int) string {
+	bands := c.opts.AltitudeBands
+	switch {
+	case len(bands) == 0:
+		return "all"
+	case band == len(bands):
+		return strconv.Itoa(bands[band-1]) + "+"
+	case band == 0:
+		return "0-" + strconv.Itoa(bands[0])
+	default:
+		return strconv.Itoa(bands[band-1]) + "-" + strconv.Itoa(bands[band])
+	}
+}
+
+// slot returns the slot of the window holding time t, clearing it if it last
+// held an older part of the window, or nil if t has already left the window.
+func (c *Coverage) slot(rc *receiverCoverage, t time.Time) *coverageSlot {
+	number := t.UnixNano() / int64(c.slotDuration())
+	slot := &rc.slots[number%coverageSlots]
+	switch {
+	case slot.distances == nil:
+		bins := (len(c.opts.AltitudeBands) + 1) * c.sectors
+		slot.distances, slot.positions = make([]float64, bins), make([]int64, bins)
+	case number < slot.number:
+		return nil
+	case number > slot.number:
+		clear(slot.distances)
+		clear(slot.positions)
+	}
+	slot.number = number
+	return slot
+}
+
+// slotDuration returns the duration of a slot of the window.
+func (c *Coverage) slotDuration() time.Duration {
+	return max(c.opts.Window/coverageSlots, time.Nanosecond)
+}
+
+// window returns, for every bin, the farthest distance and the number of
+// positions in the window ending at t.
+func (c *Coverage) window(rc *receiverCoverage, t time.Time) ([]float64, []int64) {
+	bins := (len(c.opts.AltitudeBands) + 1) * c.sectors
+	distances, positions := make([]float64, bins), make([]int64, bins)
+	current := t.UnixNano() / int64(c.slotDuration())
+	for i := range rc.slots {
+		slot := &rc.slots[i]
+		if slot.distances == nil || slot.number <= current-coverageSlots || slot.number > current {
+			continue
+		}
+		for bin := range distances {
+			distances[bin] = max(distances[bin], slot.distances[bin])
+			positions[bin] += slot.positions[bin]
+		}
+	}
+	return distances, positions
+}
+
+// emit emits a coverage record for every bin of a receiver in which aircraft
+// were seen.
+func (c *Coverage) emit(ctx context.Context, receiver string, rc *receiverCoverage, t time.Time) {
+	distances, positions := c.window(rc, t)
+	for bin, distance := range distances {
+		if positions[bin] == 0 {
+			continue
+		}
+		band, sector := bin/c.sectors, bin%c.sectors
+		Emit(ctx, models.AircraftData{
+			Receiver:          receiver,
+			MessageType:       models.MessageTypeCoverage,
+			Timestamp:         t,
+			ReceivedTimestamp: t,
+			Tags: map[string]string{
+				TagBearing:      strconv.Itoa(sector * c.opts.SectorDeg),
+				TagAltitudeBand: c.bandName(band),
+			},
+			Fields: map[string]float64{
+				FieldMaxDistance: distance,
+				FieldPositions:   float64(positions[bin]),
+			},
+		})
+	}
+}
+
+// features returns the coverage of every receiver and band in the window
+// ending at t, as polygons through the farthest point of every sector.
+func (c *Coverage) features(t time.Time) []coverageFeature {
+	receivers := make([]string, 0, len(c.receivers))
+	for receiver := range c.receivers {
+		receivers = append(receivers, receiver)
+	}
+	sort.Strings(receivers)
+
+	features := []coverageFeature{}
+	for _, receiver := range receivers {
+		pos := c.positions[receiver]
+		distances, positions := c.window(c.receivers[receiver], t)
+		for band := 0; band <= len(c.opts.AltitudeBands); band++ {
+			var total int64
+			ring := make([][2]float64, 0, c.sectors+1)
+			for sector := range c.sectors {
+				bin := band*c.sectors + sector
+				total += positions[bin]
+				// The middle of the sector, at the farthest distance seen in it.
+				lat, lon := destination(pos.Latitude, pos.Longitude, (float64(sector)+0.5)*float64(c.opts.SectorDeg), distances[bin])
+				ring = append(ring, [2]float64{round6(lon), round6(lat)})
+			}
+			if total == 0 {
+				continue
+			}
+			ring = append(ring, ring[0])
+			features = append(features, coverageFeature{
+				Type: "Feature",
+				Properties: map[string]any{
+					"receiver":      receiver,
+					TagAltitudeBand: c.bandName(band),
+					FieldPositions:  total,
+					"window":        c.opts.Window.String(),
+					"updated":       t.UTC().Format(time.RFC3339),
+				},
+				Geometry: map[string]any{"type": "Polygon", "coordinates": [][][2]float64{ring}},
+			})
+		}
+	}
+	return features
+}
+
+// queue hands features to the writer without waiting, replacing the coverage
+// it has not started writing yet. Process is its only caller, so the second
+// send cannot block.
+func (c *Coverage) queue(features []coverageFeature) {
+	select {
+	case c.writes <- features:
+		return
+	default:
+	}
+	select {
+	case <-c.writes:
+	default:
+	}
+	c.writes <- features
+}
+
+// writeLoop writes every coverage queued until Close.
+func (c *Coverage) writeLoop() {
+	defer close(c.done)
+	for features := range c.writes {
+		if err := writeCoverage(c.opts.Output, features); err != nil {
+			log.Printf("ERROR: failed to write coverage to %s: %v", c.opts.Output, err)
+		}
+	}
+}
+
+// writeCoverage writes features to path as a FeatureCollection, replacing the
+// file atomically.
+func writeCoverage(path string, features []coverageFeature) error {
+	content, err := json.Marshal(map[string]any{"type": "FeatureCollection", "features": features})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// round6 rounds a coordinate to 6 decimal places, about 10 cm.
+func round6(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
@@ -0,0 +1,172 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// coverageStart is the start of a slot for windows of whole minutes.
+var coverageStart = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// sighting is a position record of receiver rx at time t.
+func sighting(t time.Time, lat, lon float64, altitude int) *models.AircraftData {
+	return &models.AircraftData{Receiver: "rx", Timestamp: t, Latitude: &lat, Longitude: &lon, Altitude: &altitude}
+}
+
+func newTestCoverage(t *testing.T, opts CoverageOptions) *Coverage {
+	t.Helper()
+	c, err := NewCoverage(map[string]Position{"rx": {0, 0, 0}}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCoverageBands(t *testing.T) {
+	tests := []struct {
+		bands    []int
+		altitude int
+		name     string
+	}{
+		{[]int{10000, 20000, 30000}, 0, "0-10000"},
+		{[]int{10000, 20000, 30000}, 9999, "0-10000"},
+		{[]int{10000, 20000, 30000}, 10000, "10000-20000"},
+		{[]int{10000, 20000, 30000}, 25000, "20000-30000"},
+		{[]int{10000, 20000, 30000}, 30000, "30000+"},
+		{[]int{10000, 20000, 30000}, 45000, "30000+"},
+		{[]int{10000, 20000, 30000}, -500, "0-10000"},
+		{[]int{18000}, 17999, "0-18000"},
+		{[]int{18000}, 18000, "18000+"},
+		{nil, 35000, "all"},
+	}
+	for _, tt := range tests {
+		c := newTestCoverage(t, CoverageOptions{SectorDeg: 1, AltitudeBands: tt.bands, Window: time.Hour, Interval: time.Minute})
+		if got := c.bandName(c.band(tt.altitude)); got != tt.name {
+			t.Errorf("bands %v: altitude %d in band %q, want %q", tt.bands, tt.altitude, got, tt.name)
+		}
+	}
+}
+
+func TestCoverageWindow(t *testing.T) {
+	// Positions 1° and 0.5° north of the receiver, in the order they are processed.
+	type position struct {
+		at  time.Duration
+		lat float64
+	}
+	tests := []struct {
+		name      string
+		positions []position
+		at        time.Duration // end of the window
+		distance  float64
+		count     int64
+	}{
+		{"just seen", []position{{0, 1}}, 0, 111.195, 1},
+		{"end of the slot", []position{{59 * time.Second, 1}}, time.Minute - time.Nanosecond, 111.195, 1},
+		{"last slot of the window", []position{{0, 1}}, 11 * time.Minute, 111.195, 1},
+		{"left the window", []position{{0, 1}}, 12 * time.Minute, 0, 0},
+		{"long gone", []position{{0, 1}}, 5 * time.Hour, 0, 0},
+		{"maximum of the window", []position{{0, 1}, {6 * time.Minute, 0.5}}, 11 * time.Minute, 111.195, 2},
+		{"slot reused", []position{{0, 1}, {12 * time.Minute, 0.5}}, 12 * time.Minute, 55.597, 1},
+		{"too old for a reused slot", []position{{12 * time.Minute, 0.5}, {0, 1}}, 12 * time.Minute, 55.597, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCoverage(t, CoverageOptions{SectorDeg: 90, Window: 12 * time.Minute, Interval: time.Hour})
+			for _, p := range tt.positions {
+				c.Process(context.Background(), sighting(coverageStart.Add(p.at), p.lat, 0, 35000))
+			}
+			distances, positions := c.window(c.receivers["rx"], coverageStart.Add(tt.at))
+			if !near(distances[0], tt.distance, 0.001) || positions[0] != tt.count {
+				t.Errorf("window has %.3f km from %d positions, want %.3f km from %d", distances[0], positions[0], tt.distance, tt.count)
+			}
+		})
+	}
+}
+
+func TestCoverageOutput(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "coverage.geojson")
+	c, err := NewCoverage(map[string]Position{"rx": {0, 0, 0}}, CoverageOptions{
+		SectorDeg:     10,
+		AltitudeBands: []int{10000, 20000, 30000},
+		Window:        time.Hour,
+		Interval:      time.Minute,
+		Output:        output,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var emitted []models.AircraftData
+	ctx := WithEmitter(context.Background(), func(data models.AircraftData) { emitted = append(emitted, data) })
+	for _, data := range []*models.AircraftData{
+		sighting(coverageStart, 1, 0, 35000),                     // north, 111 km
+		sighting(coverageStart.Add(10*time.Second), 0, 1, 15000), // east, 111 km
+		sighting(coverageStart.Add(20*time.Second), 0, 0.5, 15000),
+		{Receiver: "rx", Timestamp: coverageStart.Add(30 * time.Second)},        // no position
+		{Receiver: "elsewhere", Timestamp: coverageStart.Add(40 * time.Second)}, // unknown receiver
+		sighting(coverageStart.Add(time.Minute), 0, -1, 500),                    // west, emits
+	} {
+		if keep, err := c.Process(ctx, data); !keep || err != nil {
+			t.Fatalf("Process = %t, %v; want true, nil", keep, err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[[2]string]float64{
+		{"0", "30000+"}:       1,
+		{"90", "10000-20000"}: 2,
+		{"270", "0-10000"}:    1,
+	}
+	if len(emitted) != len(want) {
+		t.Fatalf("emitted %d records, want %d: %v", len(emitted), len(want), emitted)
+	}
+	for _, data := range emitted {
+		key := [2]string{data.Tags[TagBearing], data.Tags[TagAltitudeBand]}
+		if data.MessageType != models.MessageTypeCoverage || data.Receiver != "rx" || !data.Timestamp.Equal(coverageStart.Add(time.Minute)) {
+			t.Errorf("emitted %+v", data)
+		}
+		if data.Fields[FieldPositions] != want[key] || !near(data.Fields[FieldMaxDistance], 111.195, 0.001) {
+			t.Errorf("bearing %s band %s: %v, want %.0f positions at 111.195 km", key[0], key[1], data.Fields, want[key])
+		}
+	}
+
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		Type     string
+		Features []struct {
+			Properties map[string]any
+			Geometry   struct {
+				Type        string
+				Coordinates [][][2]float64
+			}
+		}
+	}
+	if err := json.Unmarshal(content, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Type != "FeatureCollection" || len(file.Features) != 3 {
+		t.Fatalf("output is a %s of %d features, want a FeatureCollection of 3", file.Type, len(file.Features))
+	}
+	for i, band := range []string{"0-10000", "10000-20000", "30000+"} {
+		f := file.Features[i]
+		ring := f.Geometry.Coordinates[0]
+		if f.Properties["receiver"] != "rx" || f.Properties[TagAltitudeBand] != band || f.Geometry.Type != "Polygon" {
+			t.Errorf("feature %d: %v %s, want the %s band of rx", i, f.Properties, f.Geometry.Type, band)
+		}
+		if len(ring) != 36+1 || ring[0] != ring[36] {
+			t.Errorf("feature %d: ring of %d positions, want 36 and closed", i, len(ring))
+		}
+	}
+}
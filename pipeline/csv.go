@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// readCSV reads a CSV file with a header row naming its columns. columns maps
+// normalized header names, as returned by normalizeColumn, to fields; the
+// file must have a column for the required field. row is called for every
+// data row with a function returning the trimmed value of a field, "" if it
+// has no column. The values share memory with the row: clone those kept.
+func readCSV(path string, columns map[string]string, required string, row func(value func(field string) string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%s: failed to read the header row: %w", path, err)
+	}
+	indexes := make(map[string]int)
+	for i, name := range header {
+		if field, ok := columns[normalizeColumn(name)]; ok {
+			if _, dup := indexes[field]; !dup {
+				indexes[field] = i
+			}
+		}
+	}
+	if _, ok := indexes[required]; !ok {
+		var names []string
+		for name, field := range columns {
+			if field == required {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return fmt.Errorf("%s: no %s column in the header row (one of %s)", path, required, strings.Join(names, ", "))
+	}
+
+	var record []string
+	value := func(field string) string {
+		i, ok := indexes[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	for {
+		record, err = reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		row(value)
+	}
+}
+
+// normalizeColumn lower-cases a header name and strips everything but letters
+// and digits, so that "Type Code", "type_code" and "typecode" match.
+func normalizeColumn(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// interner shares the memory of equal strings, for data files in which
+// values such as type designators repeat a lot.
+type interner map[string]string
+
+// intern returns a copy of s that does not share memory with s.
+func (in interner) intern(s string) string {
+	if v, ok := in[s]; ok {
+		return v
+	}
+	s = strings.Clone(s)
+	in[s] = s
+	return s
+}
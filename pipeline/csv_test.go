@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes content to a file in a temporary directory and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNormalizeColumn(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"typecode", "typecode"},
+		{"Type Code", "typecode"},
+		{"type_code", "typecode"},
+		{" TYPE-CODE ", "typecode"},
+		{"icao24", "icao24"},
+		{"\"ICAO 24\"", "icao24"},
+		{"Année", "année"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeColumn(tt.name); got != tt.want {
+			t.Errorf("normalizeColumn(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string // hex, registration and type of every row
+		err     string
+	}{
+		{
+			name:    "OpenSky header",
+			content: "icao24,registration,manufacturericao,typecode\n4ca2d6,EI-DEA,AIRBUS,A320\n",
+			want:    []string{"4ca2d6 EI-DEA A320"},
+		},
+		{
+			name:    "aliases in another order",
+			content: "Type Code,Reg,ICAO Hex\nB738, G-ABCD ,40621d\n",
+			want:    []string{"40621d G-ABCD B738"},
+		},
+		{
+			name:    "first of duplicate columns",
+			content: "hex,icao,reg\n4ca2d6,ffffff,EI-DEA\n",
+			want:    []string{"4ca2d6 EI-DEA "},
+		},
+		{
+			name:    "short rows",
+			content: "hex,reg,typecode\n4ca2d6\n40621d,G-ABCD\n",
+			want:    []string{"4ca2d6  ", "40621d G-ABCD "},
+		},
+		{
+			name:    "no address column",
+			content: "registration,typecode\nEI-DEA,A320\n",
+			err:     "no hex column in the header row (one of hex, icao, icao24, icaohex, modes)",
+		},
+		{
+			name:    "empty",
+			content: "",
+			err:     "failed to read the header row",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, "registry.csv", tt.content)
+			var got []string
+			err := readCSV(path, registryColumns, "hex", func(value func(string) string) {
+				got = append(got, value("hex")+" "+value(TagRegistration)+" "+value(TagTypeCode))
+			})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("rows = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
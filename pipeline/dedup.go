@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+func init() {
+	Register("dedup", func(_ *config.Config, stage config.StageConfig) (Processor, error) {
+		return NewDedup(stage.Window), nil
+	})
+}
+
+// Dedup drops records that repeat the previous record of the same aircraft and
+// message type within a window, such as the same transmission heard by
+// several receivers. Receiver, session and timestamps are not compared.
+type Dedup struct {
+	window time.Duration
+	last   map[dedupKey]dedupEntry
+	swept  time.Time
+}
+
+// dedupKey identifies the records that are compared with each other.
+type dedupKey struct {
+	hexIdent         string
+	messageType      string
+	transmissionType string
+}
+
+// dedupEntry is the last record kept for a key.
+type dedupEntry struct {
+	fingerprint string
+	at          time.Time
+}
+
+// NewDedup creates a Dedup stage with the given window.
+func NewDedup(window time.Duration) *Dedup {
+	return &Dedup{window: window, last: make(map[dedupKey]dedupEntry)}
+}
+
+// Process implements the Processor interface.
+func (d *Dedup) Process(_ context.Context, data *models.AircraftData) (bool, error) {
+	// The collector's clock, so that receivers with skewed clocks still match.
+	at := data.ReceivedTimestamp
+	if at.Sub(d.swept) >= d.window {
+		for key, entry := range d.last {
+			if at.Sub(entry.at) >= d.window {
+				delete(d.last, key)
+			}
+		}
+		d.swept = at
+	}
+
+	key := dedupKey{hexIdent: data.HexIdent, messageType: data.MessageType, transmissionType: data.TransmissionType}
+	fp := fingerprint(data)
+	if last, ok := d.last[key]; ok && last.fingerprint == fp && at.Sub(last.at) < d.window {
+		return false, nil
+	}
+	d.last[key] = dedupEntry{fingerprint: fp, at: at}
+	return true, nil
+}
+
+// fingerprint renders the content of a record, i.e. everything the aircraft transmitted.
+func fingerprint(data *models.AircraftData) string {
+	var b strings.Builder
+	b.WriteString(data.Callsign)
+	b.WriteByte('|')
+	b.WriteString(data.Squawk)
+	b.WriteByte('|')
+	b.WriteString(data.Status)
+	writeField(&b, data.Altitude)
+	writeField(&b, data.GroundSpeed)
+	writeField(&b, data.Track)
+	writeField(&b, data.Latitude)
+	writeField(&b, data.Longitude)
+	writeField(&b, data.VerticalRate)
+	writeField(&b, data.Alert)
+	writeField(&b, data.Emergency)
+	writeField(&b, data.SPI)
+	writeField(&b, data.IsOnGround)
+	return b.String()
+}
+
+func writeField[T any](b *strings.Builder, v *T) {
+	b.WriteByte('|')
+	if v != nil {
+		fmt.Fprint(b, *v)
+	}
+}
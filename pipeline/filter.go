@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+)
+
+func init() {
+	Register("filter", func(_ *config.Config, stage config.StageConfig) (Processor, error) {
+		return NewFilter(stage.Expression, stage.OnMissing == "drop")
+	})
+}
+
+// filterEnv declares the record fields an expression can use. Optional fields
+// that a record does not carry are unknown while it is evaluated.
+var filterEnv = func() *cel.Env {
+	env, err := cel.NewEnv(
+		cel.Variable("receiver", cel.StringType),
+		cel.Variable("message_type", cel.StringType),
+		cel.Variable("transmission_type", cel.StringType),
+		cel.Variable("hex_ident", cel.StringType),
+		cel.Variable("timestamp", cel.TimestampType),
+		cel.Variable("callsign", cel.StringType),
+		cel.Variable("altitude", cel.IntType),
+		cel.Variable("ground_speed", cel.DoubleType),
+		cel.Variable("track", cel.DoubleType),
+		cel.Variable("latitude", cel.DoubleType),
+		cel.Variable("longitude", cel.DoubleType),
+		cel.Variable("vertical_rate", cel.IntType),
+		cel.Variable("squawk", cel.StringType),
+		cel.Variable("alert", cel.BoolType),
+		cel.Variable("emergency", cel.BoolType),
+		cel.Variable("spi", cel.BoolType),
+		cel.Variable("is_on_ground", cel.BoolType),
+		cel.Variable("status", cel.StringType),
+		cel.Variable("tags", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("fields", cel.MapType(cel.StringType, cel.DoubleType)),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return env
+}()
+
+// Filter keeps the records for which a CEL expression is true, e.g.
+// `altitude < 10000 && !is_on_ground && callsign.startsWith("AFR")`.
+type Filter struct {
+	program     cel.Program
+	dropMissing bool // drop, rather than keep, records lacking a field the expression needs
+}
+
+// NewFilter compiles expression. Records lacking an optional field that the
+// result depends on, such as the altitude of a velocity message, are dropped
+// if dropMissing is set and kept otherwise.
+func NewFilter(expression string, dropMissing bool) (*Filter, error) {
+	ast, iss := filterEnv.Compile(expression)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("invalid filter expression:\n%w", iss.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("invalid filter expression: must evaluate to a bool, not %s", ast.OutputType())
+	}
+	program, err := filterEnv.Program(ast, cel.EvalOptions(cel.OptPartialEval))
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter expression: %w", err)
+	}
+	return &Filter{program: program, dropMissing: dropMissing}, nil
+}
+
+// Process implements the Processor interface.
+func (f *Filter) Process(_ context.Context, data *models.AircraftData) (bool, error) {
+	vars := map[string]any{
+		"receiver":          data.Receiver,
+		"message_type":      data.MessageType,
+		"transmission_type": data.TransmissionType,
+		"hex_ident":         data.HexIdent,
+		"timestamp":         data.Timestamp,
+		"callsign":          data.Callsign,
+		"squawk":            data.Squawk,
+		"status":            data.Status,
+		"tags":              data.Tags,
+		"fields":            data.Fields,
+	}
+	if data.Tags == nil {
+		vars["tags"] = map[string]string{}
+	}
+	if data.Fields == nil {
+		vars["fields"] = map[string]float64{}
+	}
+	var missing []*cel.AttributePatternType
+	bind := func(name string, present bool, value func() any) {
+		if present {
+			vars[name] = value()
+		} else {
+			missing = append(missing, cel.AttributePattern(name))
+		}
+	}
+	bind("altitude", data.Altitude != nil, func() any { return int64(*data.Altitude) })
+	bind("ground_speed", data.GroundSpeed != nil, func() any { return *data.GroundSpeed })
+	bind("track", data.Track != nil, func() any { return *data.Track })
+	bind("latitude", data.Latitude != nil, func() any { return *data.Latitude })
+	bind("longitude", data.Longitude != nil, func() any { return *data.Longitude })
+	bind("vertical_rate", data.VerticalRate != nil, func() any { return int64(*data.VerticalRate) })
+	bind("alert", data.Alert != nil, func() any { return *data.Alert })
+	bind("emergency", data.Emergency != nil, func() any { return *data.Emergency })
+	bind("spi", data.SPI != nil, func() any { return *data.SPI })
+	bind("is_on_ground", data.IsOnGround != nil, func() any { return *data.IsOnGround })
+
+	activation, err := cel.PartialVars(vars, missing...)
+	if err != nil {
+		return true, err
+	}
+	out, _, err := f.program.Eval(activation)
+	if err != nil {
+		// e.g. an overflow; the record is kept rather than silently lost.
+		return true, err
+	}
+	if types.IsUnknown(out) {
+		return !f.dropMissing, nil
+	}
+	keep, ok := out.Value().(bool)
+	if !ok {
+		return true, fmt.Errorf("filter expression returned %v instead of a bool", out.Value())
+	}
+	return keep, nil
+}
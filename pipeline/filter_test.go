@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+func TestNewFilterRejectsInvalidExpressions(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"altitude <", "invalid filter expression"},
+		{"speed > 100", "undeclared reference to 'speed'"},
+		{`callsign > 1`, "no matching overload"},
+		{"altitude + 1000", "must evaluate to a bool, not int"},
+		{`callsign`, "must evaluate to a bool, not string"},
+		{`fields["distance_km"]`, "must evaluate to a bool, not double"},
+		{"dyn(is_on_ground)", "must evaluate to a bool, not dyn"},
+	}
+	for _, tt := range tests {
+		_, err := NewFilter(tt.expression, false)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("NewFilter(%q) = %v, want an error containing %q", tt.expression, err, tt.want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	position := models.AircraftData{
+		Receiver:         "roof",
+		MessageType:      models.MessageTypeTransmission,
+		TransmissionType: "3",
+		HexIdent:         "4CA2D6",
+		Timestamp:        time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Callsign:         "EIN123",
+		Altitude:         ptr(3000),
+		Latitude:         ptr(53.4),
+		Longitude:        ptr(-6.3),
+		IsOnGround:       ptr(false),
+		Tags:             map[string]string{TagOperator: "Aer Lingus"},
+		Fields:           map[string]float64{"distance_km": 12.5},
+	}
+	velocity := models.AircraftData{ // no altitude, position or flags
+		MessageType:      models.MessageTypeTransmission,
+		TransmissionType: "4",
+		HexIdent:         "4CA2D6",
+		GroundSpeed:      ptr(140.0),
+		Track:            ptr(280.0),
+		VerticalRate:     ptr(-640),
+	}
+	tests := []struct {
+		name        string
+		expression  string
+		data        models.AircraftData
+		keep        bool // with on_missing: keep
+		dropMissing bool // with on_missing: drop
+	}{
+		{"true", "altitude < 10000 && !is_on_ground", position, true, true},
+		{"false", "altitude >= 10000", position, false, false},
+		{"strings", `callsign.startsWith("EIN") && receiver == "roof" && hex_ident == "4CA2D6"`, position, true, true},
+		{"timestamp", `timestamp < timestamp("2024-01-02T00:00:00Z")`, position, true, true},
+		{"tags", `tags["operator"] == "Aer Lingus"`, position, true, true},
+		{"missing tag", `"registration" in tags`, velocity, false, false},
+		{"fields", `fields["distance_km"] < 20.0`, position, true, true},
+		{"unset field", "altitude < 10000", velocity, true, false},
+		{"unset flag", "!is_on_ground", velocity, true, false},
+		{"unset fields of both sides", "latitude > 53.0 || longitude < -6.0", velocity, true, false},
+		{"set fields", "ground_speed > 100.0 && track < 360.0 && vertical_rate < 0", velocity, true, true},
+		{"decided without the unset field", `altitude < 10000 || ground_speed > 100.0`, velocity, true, true},
+		{"false without the unset field", `altitude < 10000 && ground_speed > 200.0`, velocity, false, false},
+		{"presence of the unset field", `transmission_type != "4" || altitude > 0`, velocity, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, dropMissing := range []bool{false, true} {
+				f, err := NewFilter(tt.expression, dropMissing)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := tt.keep
+				if dropMissing {
+					want = tt.dropMissing
+				}
+				data := tt.data
+				if keep, err := f.Process(context.Background(), &data); keep != want || err != nil {
+					t.Errorf("on_missing drop %t: Process = %t, %v; want %t, nil", dropMissing, keep, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterKeepsRecordOnError(t *testing.T) {
+	f, err := NewFilter("altitude * 9223372036854775807 > 0", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep, err := f.Process(context.Background(), &models.AircraftData{Altitude: ptr(3000)})
+	if !keep || err == nil {
+		t.Errorf("Process of an overflow = %t, %v; want true and an error", keep, err)
+	}
+}
+
+func TestBuildFilter(t *testing.T) {
+	cfg := config.Default()
+	cfg.Pipeline.Stages = []config.StageConfig{
+		{Type: "filter", Name: "keep", Expression: "altitude < 10000", OnMissing: "keep"},
+		{Type: "filter", Name: "drop", Expression: "altitude < 10000", OnMissing: "drop"},
+	}
+	stages, err := Build(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false} {
+		if keep, _ := stages[i].Processor.Process(context.Background(), &models.AircraftData{}); keep != want {
+			t.Errorf("stage %s kept a record without altitude: %t, want %t", stages[i].Name, keep, want)
+		}
+	}
+
+	cfg.Pipeline.Stages = []config.StageConfig{{Type: "filter", Expression: "altitude"}}
+	if _, err := Build(cfg); err == nil || !strings.HasPrefix(err.Error(), "pipeline.stages[0]: invalid filter expression") {
+		t.Errorf("Build = %v, want the invalid expression of pipeline.stages[0]", err)
+	}
+}
@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"math"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+)
+
+const earthRadiusKm = 6371.0
+
+// Position is the position of a receiver's antenna.
+type Position struct {
+	Latitude, Longitude float64 // decimal degrees
+	AltitudeFt          int     // above mean sea level
+}
+
+// sourcePositions returns the position of every source by name: its own, or
+// else that of the station if it is set.
+func sourcePositions(cfg *config.Config) map[string]Position {
+	positions := make(map[string]Position, len(cfg.Sources))
+	for _, src := range cfg.Sources {
+		switch {
+		case src.HasPosition():
+			positions[src.Name] = Position{src.Latitude, src.Longitude, src.Altitude}
+		case cfg.Receiver.HasPosition():
+			positions[src.Name] = Position{cfg.Receiver.Latitude, cfg.Receiver.Longitude, cfg.Receiver.Altitude}
+		}
+	}
+	return positions
+}
+
+// distanceKm returns the great circle distance between two points.
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := radians(lat1), radians(lat2)
+	dPhi, dLambda := phi2-phi1, radians(lon2-lon1)
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// bearingDeg returns the initial bearing of the great circle from the first
+// point to the second, in degrees clockwise from true north, in [0, 360).
+func bearingDeg(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := radians(lat1), radians(lat2)
+	dLambda := radians(lon2 - lon1)
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	return math.Mod(degrees(math.Atan2(y, x))+360, 360)
+}
+
+// destination returns the point at distanceKm from a point along the great
+// circle with the given initial bearing.
+func destination(lat, lon, bearing, distanceKm float64) (float64, float64) {
+	phi1, lambda1, theta := radians(lat), radians(lon), radians(bearing)
+	delta := distanceKm / earthRadiusKm
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1), math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+	return degrees(phi2), math.Mod(degrees(lambda2)+540, 360) - 180
+}
+
+// elevationDeg returns the angle above the horizon at which a point at
+// distanceKm along the ground and height2Km is seen from height1Km, taking the
+// curvature of the Earth into account.
+func elevationDeg(distanceKm, height1Km, height2Km float64) float64 {
+	theta := distanceKm / earthRadiusKm
+	r1, r2 := earthRadiusKm+height1Km, earthRadiusKm+height2Km
+	return degrees(math.Atan2(r2*math.Cos(theta)-r1, r2*math.Sin(theta)))
+}
+
+// polygon is a GeoJSON polygon: an outer ring followed by holes, each a list
+// of [longitude, latitude] positions.
+type polygon [][][2]float64
+
+// contains reports whether the point is inside the outer ring and outside every hole.
+func (p polygon) contains(lat, lon float64) bool {
+	if len(p) == 0 || !ringContains(p[0], lat, lon) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if ringContains(hole, lat, lon) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringContains casts a ray from the point and counts the edges it crosses.
+// Zones are small enough for longitude and latitude to be treated as planar.
+func ringContains(ring [][2]float64, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
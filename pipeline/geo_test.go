@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"math"
+	"testing"
+)
+
+// near reports whether got is within tolerance of want.
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+func TestDistanceAndBearing(t *testing.T) {
+	tests := []struct {
+		name              string
+		lat1, lon1        float64
+		lat2, lon2        float64
+		distance, bearing float64
+	}{
+		{"one degree north", 0, 0, 1, 0, 111.195, 0},
+		{"one degree east on the equator", 0, 0, 0, 1, 111.195, 90},
+		{"one degree south", 10, 20, 9, 20, 111.195, 180},
+		{"one degree west on the equator", 0, 0, 0, -1, 111.195, 270},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, 111.195, 90},
+		{"Paris to London", 48.8566, 2.3522, 51.5074, -0.1278, 343.556, 330.021},
+		{"London Heathrow to New York JFK", 51.4700, -0.4543, 40.6413, -73.7781, 5540.011, 287.943},
+		{"same point", 51.5, -0.1, 51.5, -0.1, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := distanceKm(tt.lat1, tt.lon1, tt.lat2, tt.lon2); !near(got, tt.distance, 0.001) {
+				t.Errorf("distance = %.3f km, want %.3f", got, tt.distance)
+			}
+			if got := bearingDeg(tt.lat1, tt.lon1, tt.lat2, tt.lon2); !near(got, tt.bearing, 0.001) {
+				t.Errorf("bearing = %.3f°, want %.3f", got, tt.bearing)
+			}
+		})
+	}
+}
+
+func TestDestination(t *testing.T) {
+	for _, bearing := range []float64{0, 45, 90, 135, 180, 225, 270, 315} {
+		lat, lon := destination(51.4775, -0.4614, bearing, 250)
+		if got := distanceKm(51.4775, -0.4614, lat, lon); !near(got, 250, 1e-6) {
+			t.Errorf("bearing %.0f: destination is %.6f km away, want 250", bearing, got)
+		}
+		if got := bearingDeg(51.4775, -0.4614, lat, lon); !near(math.Remainder(got-bearing, 360), 0, 1e-6) {
+			t.Errorf("bearing %.0f: destination is at bearing %.6f", bearing, got)
+		}
+	}
+	if lat, lon := destination(0, 179.5, 90, 111.195); !near(lat, 0, 1e-6) || !near(lon, -179.5, 1e-3) {
+		t.Errorf("destination across the antimeridian = %.6f, %.6f; want 0, -179.5", lat, lon)
+	}
+}
+
+func TestElevation(t *testing.T) {
+	tests := []struct {
+		name      string
+		distance  float64 // km
+		height1   float64 // km
+		height2   float64 // km
+		elevation float64 // degrees
+	}{
+		{"overhead", 0, 0, 10, 90},
+		{"below overhead", 0, 10, 0, -90},
+		// The aircraft is on the geometric horizon at R acos(R / (R + h)).
+		{"on the horizon", 368.432, 0, 10.668, 0},
+		// The Earth curves away by d / 2R radians at the same height.
+		{"same height", 100, 1, 1, -math.Atan(100/(2*(earthRadiusKm+1))) * 180 / math.Pi},
+		{"close and low", 1, 0, 1, 45},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := elevationDeg(tt.distance, tt.height1, tt.height2); !near(got, tt.elevation, 0.01) {
+				t.Errorf("elevation = %.4f°, want %.4f", got, tt.elevation)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+const (
+	// TagZones is the tag listing the zones a position record is inside, comma-separated.
+	TagZones = "zones"
+	// TagZone is the tag naming the zone of a zone_enter or zone_exit event.
+	TagZone = "zone"
+
+	// geofenceAircraftTTL is how long an aircraft is remembered after its last
+	// message; it then leaves its zones.
+	geofenceAircraftTTL = 5 * time.Minute
+)
+
+func init() {
+	Register("geofence", func(cfg *config.Config, stage config.StageConfig) (Processor, error) {
+		var zones []Zone
+		if stage.GeoJSON != "" {
+			loaded, err := LoadZones(stage.GeoJSON)
+			if err != nil {
+				return nil, err
+			}
+			zones = loaded
+		}
+		if len(stage.Zones) > 0 && !cfg.Receiver.HasPosition() {
+			return nil, fmt.Errorf("zones need receiver.latitude and receiver.longitude, the centre of every zone")
+		}
+		for _, z := range stage.Zones {
+			zones = append(zones, RadiusZone(z.Name, cfg.Receiver.Latitude, cfg.Receiver.Longitude, z.RadiusKm, z.MinAltitude, z.MaxAltitude))
+		}
+		return NewGeofence(zones, stage.DropOutside)
+	})
+}
+
+// Zone is an area, optionally limited to an altitude band.
+type Zone struct {
+	Name        string
+	MinAltitude int // feet
+	MaxAltitude int // feet; 0 sets no ceiling
+	contains    func(lat, lon float64) bool
+}
+
+// RadiusZone returns the cylinder of radiusKm around a point.
+func RadiusZone(name string, lat, lon, radiusKm float64, minAltitude, maxAltitude int) Zone {
+	return Zone{
+		Name:        name,
+		MinAltitude: minAltitude,
+		MaxAltitude: maxAltitude,
+		contains: func(plat, plon float64) bool {
+			return distanceKm(lat, lon, plat, plon) <= radiusKm
+		},
+	}
+}
+
+// bounded reports whether the zone has an altitude band.
+func (z *Zone) bounded() bool {
+	return z.MinAltitude != 0 || z.MaxAltitude != 0
+}
+
+// LoadZones reads the Polygon and MultiPolygon features of a GeoJSON file. A
+// feature is named by its "name" property and can be limited to an altitude
+// band with the "min_altitude_ft" and "max_altitude_ft" properties.
+func LoadZones(path string) ([]Zone, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc geoJSON
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON file %s: %w", path, err)
+	}
+	features := []geoJSON{doc}
+	if doc.Type == "FeatureCollection" {
+		features = doc.Features
+	}
+
+	var zones []Zone
+	for i, feature := range features {
+		if feature.Type != "Feature" || feature.Geometry == nil {
+			return nil, fmt.Errorf("%s: feature %d: expected a Feature with a geometry, got %q", path, i, feature.Type)
+		}
+		zone, err := feature.zone()
+		if err != nil {
+			return nil, fmt.Errorf("%s: feature %d: %w", path, i, err)
+		}
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+// geoJSON holds the members of the GeoJSON objects used for zones.
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Features    []geoJSON       `json:"features"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Properties  map[string]any  `json:"properties"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// zone converts a Feature into a zone.
+func (f *geoJSON) zone() (Zone, error) {
+	name, _ := f.Properties["name"].(string)
+	if name == "" {
+		return Zone{}, fmt.Errorf("missing \"name\" property")
+	}
+	zone := Zone{Name: name}
+	if v, ok := f.Properties["min_altitude_ft"].(float64); ok {
+		zone.MinAltitude = int(v)
+	}
+	if v, ok := f.Properties["max_altitude_ft"].(float64); ok {
+		zone.MaxAltitude = int(v)
+	}
+
+	var polygons []polygon
+	switch f.Geometry.Type {
+	case "Polygon":
+		var p polygon
+		if err := json.Unmarshal(f.Geometry.Coordinates, &p); err != nil {
+			return Zone{}, fmt.Errorf("zone %q: invalid Polygon coordinates: %w", name, err)
+		}
+		polygons = append(polygons, p)
+	case "MultiPolygon":
+		if err := json.Unmarshal(f.Geometry.Coordinates, &polygons); err != nil {
+			return Zone{}, fmt.Errorf("zone %q: invalid MultiPolygon coordinates: %w", name, err)
+		}
+	default:
+		return Zone{}, fmt.Errorf("zone %q: unsupported geometry type %q (expected Polygon or MultiPolygon)", name, f.Geometry.Type)
+	}
+	for _, p := range polygons {
+		if len(p) == 0 || len(p[0]) < 4 {
+			return Zone{}, fmt.Errorf("zone %q: a polygon needs a ring of at least 4 positions", name)
+		}
+	}
+	zone.contains = func(lat, lon float64) bool {
+		for _, p := range polygons {
+			if p.contains(lat, lon) {
+				return true
+			}
+		}
+		return false
+	}
+	return zone, nil
+}
+
+// Geofence tags position records with the zones they are inside and emits a
+// zone_enter or zone_exit event when an aircraft crosses the boundary of a
+// zone. Optionally, it drops the records of aircraft outside every zone.
+type Geofence struct {
+	zones       []Zone
+	dropOutside bool
+	aircraft    map[string]*fenceAircraft
+	swept       time.Time
+}
+
+// fenceAircraft is what the geofence remembers about an aircraft.
+type fenceAircraft struct {
+	inside      []string // names of the zones it is in, in configuration order
+	altitude    int
+	hasAltitude bool
+	callsign    string
+	receiver    string
+	timestamp   time.Time // point time of its last message
+	received    time.Time
+}
+
+// NewGeofence creates a Geofence stage for zones, which must have distinct names.
+func NewGeofence(zones []Zone, dropOutside bool) (*Geofence, error) {
+	seen := make(map[string]bool, len(zones))
+	for _, zone := range zones {
+		if seen[zone.Name] {
+			return nil, fmt.Errorf("duplicate zone name %q", zone.Name)
+		}
+		seen[zone.Name] = true
+	}
+	return &Geofence{zones: zones, dropOutside: dropOutside, aircraft: make(map[string]*fenceAircraft)}, nil
+}
+
+// Process implements the Processor interface.
+func (g *Geofence) Process(ctx context.Context, data *models.AircraftData) (bool, error) {
+	g.sweep(ctx, data.ReceivedTimestamp)
+
+	ac := g.aircraft[data.HexIdent]
+	if ac == nil {
+		ac = &fenceAircraft{}
+		g.aircraft[data.HexIdent] = ac
+	}
+	ac.receiver, ac.timestamp, ac.received = data.Receiver, data.Timestamp, data.ReceivedTimestamp
+	if data.Callsign != "" {
+		ac.callsign = data.Callsign
+	}
+	if data.Altitude != nil {
+		ac.altitude, ac.hasAltitude = *data.Altitude, true
+	}
+
+	if data.IsGone() {
+		wasInside := len(ac.inside) > 0
+		g.leave(ctx, ac, data.HexIdent, ac.inside, data.Latitude, data.Longitude)
+		delete(g.aircraft, data.HexIdent)
+		return wasInside || !g.dropOutside, nil
+	}
+
+	if data.Latitude != nil && data.Longitude != nil {
+		var inside []string
+		for i := range g.zones {
+			zone := &g.zones[i]
+			if zone.bounded() && (!ac.hasAltitude || ac.altitude < zone.MinAltitude || (zone.MaxAltitude != 0 && ac.altitude > zone.MaxAltitude)) {
+				continue
+			}
+			if zone.contains(*data.Latitude, *data.Longitude) {
+				inside = append(inside, zone.Name)
+			}
+		}
+		var left []string
+		for _, name := range ac.inside {
+			if !slices.Contains(inside, name) {
+				left = append(left, name)
+			}
+		}
+		g.leave(ctx, ac, data.HexIdent, left, data.Latitude, data.Longitude)
+		for _, name := range inside {
+			if !slices.Contains(ac.inside, name) {
+				Emit(ctx, g.event(models.MessageTypeZoneEnter, name, data.HexIdent, ac, data.Latitude, data.Longitude))
+			}
+		}
+		ac.inside = inside
+		if len(inside) > 0 {
+			data.SetTag(TagZones, strings.Join(inside, ","))
+		}
+	}
+
+	return len(ac.inside) > 0 || !g.dropOutside, nil
+}
+
+// leave emits a zone_exit event for each zone.
+func (g *Geofence) leave(ctx context.Context, ac *fenceAircraft, hex string, zones []string, lat, lon *float64) {
+	for _, name := range zones {
+		Emit(ctx, g.event(models.MessageTypeZoneExit, name, hex, ac, lat, lon))
+	}
+}
+
+// event creates a zone event at the aircraft's last message.
+func (g *Geofence) event(typ, zone, hex string, ac *fenceAircraft, lat, lon *float64) models.AircraftData {
+	event := models.AircraftData{
+		Receiver:          ac.receiver,
+		MessageType:       typ,
+		HexIdent:          hex,
+		Callsign:          ac.callsign,
+		Timestamp:         ac.timestamp,
+		ReceivedTimestamp: ac.received,
+		Latitude:          lat,
+		Longitude:         lon,
+		Tags:              map[string]string{TagZone: zone},
+	}
+	if ac.hasAltitude {
+		altitude := ac.altitude
+		event.Altitude = &altitude
+	}
+	return event
+}
+
+// sweep forgets the aircraft that have not been heard from for a while,
+// emitting the events of the zones they leave, at most once a minute.
+func (g *Geofence) sweep(ctx context.Context, now time.Time) {
+	if now.Sub(g.swept) < time.Minute {
+		return
+	}
+	g.swept = now
+	for hex, ac := range g.aircraft {
+		if now.Sub(ac.received) >= geofenceAircraftTTL {
+			g.leave(ctx, ac, hex, ac.inside, nil, nil)
+			delete(g.aircraft, hex)
+		}
+	}
+}
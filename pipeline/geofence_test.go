@@ -0,0 +1,297 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+const zonesGeoJSON = `{
+  "type": "FeatureCollection",
+  "features": [
+    {
+      "type": "Feature",
+      "properties": {"name": "dublin"},
+      "geometry": {"type": "Polygon", "coordinates": [
+        [[-6.5, 53.2], [-6.0, 53.2], [-6.0, 53.6], [-6.5, 53.6], [-6.5, 53.2]],
+        [[-6.3, 53.4], [-6.2, 53.4], [-6.2, 53.5], [-6.3, 53.5], [-6.3, 53.4]]
+      ]}
+    },
+    {
+      "type": "Feature",
+      "properties": {"name": "islands", "min_altitude_ft": 1000, "max_altitude_ft": 10000},
+      "geometry": {"type": "MultiPolygon", "coordinates": [
+        [[[-10.0, 51.0], [-9.0, 51.0], [-9.0, 52.0], [-10.0, 52.0], [-10.0, 51.0]]],
+        [[[-8.0, 55.0], [-7.0, 55.0], [-7.5, 56.0], [-8.0, 55.0]]]
+      ]}
+    }
+  ]
+}`
+
+// fix is a position record of hex received at start+at; a negative altitude leaves it unset.
+func fix(hex string, at time.Duration, lat, lon float64, altitude int) *models.AircraftData {
+	data := &models.AircraftData{
+		Receiver:          "roof",
+		MessageType:       models.MessageTypeTransmission,
+		TransmissionType:  "3",
+		HexIdent:          hex,
+		Timestamp:         coverageStart.Add(at),
+		ReceivedTimestamp: coverageStart.Add(at),
+		Latitude:          &lat,
+		Longitude:         &lon,
+	}
+	if altitude >= 0 {
+		data.Altitude = &altitude
+	}
+	return data
+}
+
+// fenceEvents runs records through g and returns whether each was kept, its
+// zones tag, and the events emitted, as "<hex> <type> <zone>".
+func fenceEvents(t *testing.T, g *Geofence, records ...*models.AircraftData) (kept []bool, tags []string, events []string) {
+	t.Helper()
+	ctx := WithEmitter(context.Background(), func(event models.AircraftData) {
+		events = append(events, fmt.Sprintf("%s %s %s", event.HexIdent, event.MessageType, event.Tags[TagZone]))
+	})
+	for _, data := range records {
+		keep, err := g.Process(ctx, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept = append(kept, keep)
+		tags = append(tags, data.Tags[TagZones])
+	}
+	return kept, tags, events
+}
+
+func TestLoadZones(t *testing.T) {
+	zones, err := LoadZones(writeFile(t, "zones.geojson", zonesGeoJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 2 || zones[0].Name != "dublin" || zones[1].Name != "islands" {
+		t.Fatalf("zones = %+v, want dublin and islands", zones)
+	}
+	if zones[0].bounded() || zones[1].MinAltitude != 1000 || zones[1].MaxAltitude != 10000 {
+		t.Errorf("altitude bands %d-%d and %d-%d, want none and 1000-10000",
+			zones[0].MinAltitude, zones[0].MaxAltitude, zones[1].MinAltitude, zones[1].MaxAltitude)
+	}
+	tests := []struct {
+		name     string
+		zone     int
+		lat, lon float64
+		want     bool
+	}{
+		{"inside the outer ring", 0, 53.3, -6.4, true},
+		{"inside the hole", 0, 53.45, -6.25, false},
+		{"outside", 0, 53.7, -6.4, false},
+		{"east of the polygon", 0, 53.3, -5.9, false},
+		{"first polygon", 1, 51.5, -9.5, true},
+		{"second polygon", 1, 55.3, -7.5, true},
+		{"next to the triangle", 1, 55.9, -7.9, false},
+		{"between the polygons", 1, 53.0, -8.5, false},
+	}
+	for _, tt := range tests {
+		if got := zones[tt.zone].contains(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("%s: %s contains %.2f, %.2f = %t, want %t", tt.name, zones[tt.zone].Name, tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
+
+func TestLoadZonesErrors(t *testing.T) {
+	feature := func(properties, geometry string) string {
+		return `{"type": "Feature", "properties": ` + properties + `, "geometry": ` + geometry + `}`
+	}
+	square := `{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}`
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"not JSON", "zones", "invalid GeoJSON file"},
+		{"geometry", `{"type": "Polygon", "coordinates": []}`, `feature 0: expected a Feature with a geometry, got "Polygon"`},
+		{"no name", feature(`{}`, square), `feature 0: missing "name" property`},
+		{"point", feature(`{"name": "p"}`, `{"type": "Point", "coordinates": [0, 0]}`), `zone "p": unsupported geometry type "Point"`},
+		{"short ring", feature(`{"name": "p"}`, `{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [0, 0]]]}`), `zone "p": a polygon needs a ring of at least 4 positions`},
+		{"bad coordinates", feature(`{"name": "p"}`, `{"type": "Polygon", "coordinates": [0, 0]}`), `zone "p": invalid Polygon coordinates`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadZones(writeFile(t, "zones.geojson", tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadZones = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRadiusZone(t *testing.T) {
+	zone := RadiusZone("near", 53.42, -6.27, 10, 0, 0)
+	for _, tt := range []struct {
+		bearing, distance float64
+		want              bool
+	}{
+		{0, 0, true},
+		{90, 5, true},
+		{200, 9.9, true},
+		{270, 10.1, false},
+		{45, 100, false},
+	} {
+		lat, lon := destination(53.42, -6.27, tt.bearing, tt.distance)
+		if got := zone.contains(lat, lon); got != tt.want {
+			t.Errorf("contains the point %.1f km at %.0f° = %t, want %t", tt.distance, tt.bearing, got, tt.want)
+		}
+	}
+}
+
+func TestGeofence(t *testing.T) {
+	north, _ := destination(53.42, -6.27, 0, 8)
+	g, err := NewGeofence([]Zone{
+		RadiusZone("near", 53.42, -6.27, 10, 0, 0),
+		RadiusZone("low", 53.42, -6.27, 20, 0, 5000),
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	far, _ := destination(53.42, -6.27, 0, 15)
+	kept, tags, events := fenceEvents(t, g,
+		fix("4CA2D6", 0, 54, -6.27, 3000),                // outside
+		fix("4CA2D6", time.Second, far, -6.27, 3000),     // enters low
+		fix("4CA2D6", 2*time.Second, north, -6.27, -1),   // enters near, still low at 3000 ft
+		fix("4CA2D6", 3*time.Second, north, -6.27, 6000), // climbs out of low
+		fix("3C6586", 3*time.Second, 53.42, -6.27, -1),   // enters near only, without altitude
+		fix("4CA2D6", 4*time.Second, far, -6.27, 6000),   // leaves near
+		&models.AircraftData{MessageType: models.MessageTypeStatus, Status: models.StatusRemoved, HexIdent: "3C6586"},
+		&models.AircraftData{MessageType: models.MessageTypeTransmission, TransmissionType: "4", HexIdent: "4CA2D6"},
+	)
+	if want := []string{"", "low", "near,low", "near", "near", "", "", ""}; !slices.Equal(tags, want) {
+		t.Errorf("zones tags %q, want %q", tags, want)
+	}
+	if want := []string{
+		"4CA2D6 zone_enter low",
+		"4CA2D6 zone_enter near",
+		"4CA2D6 zone_exit low",
+		"3C6586 zone_enter near",
+		"4CA2D6 zone_exit near",
+		"3C6586 zone_exit near",
+	}; !slices.Equal(events, want) {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(events, "\n"), strings.Join(want, "\n"))
+	}
+	if slices.Contains(kept, false) {
+		t.Errorf("kept %v without drop_outside, want every record", kept)
+	}
+}
+
+func TestGeofenceEventFields(t *testing.T) {
+	g, err := NewGeofence([]Zone{RadiusZone("near", 53.42, -6.27, 10, 0, 0)}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []models.AircraftData
+	ctx := WithEmitter(context.Background(), func(event models.AircraftData) { events = append(events, event) })
+	ident := &models.AircraftData{
+		MessageType:       models.MessageTypeTransmission,
+		TransmissionType:  "1",
+		HexIdent:          "4CA2D6",
+		ReceivedTimestamp: coverageStart,
+		Callsign:          "EIN123",
+	}
+	for _, data := range []*models.AircraftData{ident, fix("4CA2D6", time.Minute, 53.42, -6.27, 2000)} {
+		if _, err := g.Process(ctx, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.MessageType != models.MessageTypeZoneEnter || event.Receiver != "roof" || event.Callsign != "EIN123" ||
+		!event.Timestamp.Equal(coverageStart.Add(time.Minute)) || event.Altitude == nil || *event.Altitude != 2000 ||
+		event.Latitude == nil || *event.Latitude != 53.42 || event.Tags[TagZone] != "near" {
+		t.Errorf("event = %+v, want the zone_enter of near by EIN123 at 2000 ft at the position", event)
+	}
+}
+
+func TestGeofenceDropOutside(t *testing.T) {
+	g, err := NewGeofence([]Zone{RadiusZone("near", 53.42, -6.27, 10, 0, 0)}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, _, _ := fenceEvents(t, g,
+		fix("4CA2D6", 0, 54, -6.27, 3000),              // outside
+		fix("4CA2D6", time.Second, 53.42, -6.27, 3000), // inside
+		&models.AircraftData{MessageType: models.MessageTypeTransmission, TransmissionType: "4", HexIdent: "4CA2D6"}, // still inside
+		fix("4CA2D6", 2*time.Second, 54, -6.27, 3000),                                                                // outside again
+		&models.AircraftData{MessageType: models.MessageTypeTransmission, TransmissionType: "4", HexIdent: "3C6586"}, // never seen inside
+		fix("3C6586", 3*time.Second, 53.42, -6.27, 3000),
+		&models.AircraftData{MessageType: models.MessageTypeStatus, Status: models.StatusRemoved, HexIdent: "3C6586"}, // leaves from inside
+		&models.AircraftData{MessageType: models.MessageTypeStatus, Status: models.StatusRemoved, HexIdent: "4CA2D6"}, // gone from outside
+	)
+	if want := []bool{false, true, true, false, false, true, true, false}; !slices.Equal(kept, want) {
+		t.Errorf("kept %v, want %v", kept, want)
+	}
+}
+
+func TestGeofenceSweep(t *testing.T) {
+	g, err := NewGeofence([]Zone{RadiusZone("near", 53.42, -6.27, 10, 0, 0)}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, events := fenceEvents(t, g,
+		fix("4CA2D6", 0, 53.42, -6.27, 3000),
+		fix("3C6586", time.Minute, 53.42, -6.27, 3000),
+		fix("40621D", geofenceAircraftTTL-time.Second, 54, -6.27, 3000),    // 4CA2D6 not yet expired
+		fix("40621D", geofenceAircraftTTL+30*time.Second, 54, -6.27, 3000), // expired, but swept less than a minute ago
+		fix("40621D", geofenceAircraftTTL+59*time.Second, 54, -6.27, 3000), // 4CA2D6 expired
+		fix("40621D", 2*geofenceAircraftTTL, 54, -6.27, 3000),              // 3C6586 expired
+	)
+	want := []string{
+		"4CA2D6 zone_enter near",
+		"3C6586 zone_enter near",
+		"4CA2D6 zone_exit near",
+		"3C6586 zone_exit near",
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(events, "\n"), strings.Join(want, "\n"))
+	}
+	if _, ok := g.aircraft["4CA2D6"]; ok {
+		t.Error("4CA2D6 still remembered after it expired")
+	}
+	if _, ok := g.aircraft["40621D"]; !ok {
+		t.Error("40621D forgotten while still heard")
+	}
+}
+
+func TestBuildGeofence(t *testing.T) {
+	cfg := config.Default()
+	cfg.Pipeline.Stages = []config.StageConfig{{
+		Type:    "geofence",
+		GeoJSON: writeFile(t, "zones.geojson", zonesGeoJSON),
+		Zones:   []config.ZoneConfig{{Name: "near", RadiusKm: 10}},
+	}}
+	cfg.Sources = []config.SourceConfig{{Name: "attic", Latitude: 53.42, Longitude: -6.27}}
+	if _, err := Build(cfg); err == nil || !strings.Contains(err.Error(), "zones need receiver.latitude and receiver.longitude") {
+		t.Errorf("Build without the station's position = %v, want an error", err)
+	}
+
+	cfg.Receiver.Latitude, cfg.Receiver.Longitude = 53.42, -6.27
+	stages, err := Build(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := stages[0].Processor.(*Geofence)
+	if len(g.zones) != 3 || g.zones[2].Name != "near" || !g.zones[2].contains(53.42, -6.27) || g.zones[2].contains(53.6, -6.27) {
+		t.Errorf("zones %+v, want those of the file and near centred on the station", g.zones)
+	}
+
+	cfg.Pipeline.Stages[0].Zones = append(cfg.Pipeline.Stages[0].Zones, config.ZoneConfig{Name: "dublin", RadiusKm: 5})
+	if _, err := Build(cfg); err == nil || !strings.Contains(err.Error(), `duplicate zone name "dublin"`) {
+		t.Errorf("Build with a duplicate zone = %v, want an error", err)
+	}
+}
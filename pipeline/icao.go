@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+const (
+	// TagCountry is the tag holding the country that allocated the aircraft's
+	// address, i.e. its country of registration. BST output writes it to the
+	// Country column.
+	TagCountry = models.TagCountry
+	// TagMilitary is the tag set to "true" for addresses in a known military
+	// range and "false" otherwise.
+	TagMilitary = models.TagMilitary
+)
+
+func init() {
+	Register("icao", func(_ *config.Config, _ config.StageConfig) (Processor, error) {
+		return ICAO{}, nil
+	})
+}
+
+// icaoBlock is a range of 24-bit addresses, bounds included.
+type icaoBlock struct {
+	first, last uint32
+	country     string
+}
+
+// ICAO tags records with the country that allocated the aircraft's 24-bit
+// address and whether the address is in a known military range, using
+// built-in tables. Records already tagged with a country, such as those read
+// from BST files, keep it. Records with an unallocated address are not tagged
+// with a country.
+type ICAO struct{}
+
+// Process implements the Processor interface.
+func (ICAO) Process(_ context.Context, data *models.AircraftData) (bool, error) {
+	address, err := strconv.ParseUint(data.HexIdent, 16, 24)
+	if err != nil {
+		// Not an address, e.g. a CLK message; nothing to add.
+		return true, nil
+	}
+	if _, ok := data.Tags[TagCountry]; !ok {
+		if block := lookupBlock(icaoBlocks, uint32(address)); block != nil {
+			data.SetTag(TagCountry, block.country)
+		}
+	}
+	data.SetTag(TagMilitary, strconv.FormatBool(lookupBlock(militaryBlocks, uint32(address)) != nil))
+	return true, nil
+}
+
+// lookupBlock returns the block of the sorted blocks containing address, or nil.
+func lookupBlock(blocks []icaoBlock, address uint32) *icaoBlock {
+	i := sort.Search(len(blocks), func(i int) bool { return blocks[i].last >= address })
+	if i < len(blocks) && blocks[i].first <= address {
+		return &blocks[i]
+	}
+	return nil
+}
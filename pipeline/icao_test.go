@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+func TestICAOTablesSorted(t *testing.T) {
+	for name, blocks := range map[string][]icaoBlock{"icaoBlocks": icaoBlocks, "militaryBlocks": militaryBlocks} {
+		for i, block := range blocks {
+			if block.first > block.last || block.last > 0xFFFFFF {
+				t.Errorf("%s[%d] %06X-%06X (%s) is not a range of 24-bit addresses", name, i, block.first, block.last, block.country)
+			}
+			if block.country == "" {
+				t.Errorf("%s[%d] %06X-%06X has no country", name, i, block.first, block.last)
+			}
+			if i > 0 && blocks[i-1].last >= block.first {
+				t.Errorf("%s[%d] %06X-%06X (%s) is not after %06X-%06X (%s)", name, i, block.first, block.last, block.country,
+					blocks[i-1].first, blocks[i-1].last, blocks[i-1].country)
+			}
+		}
+	}
+}
+
+func TestMilitaryBlocksInsideCountries(t *testing.T) {
+	for _, military := range militaryBlocks {
+		first, last := lookupBlock(icaoBlocks, military.first), lookupBlock(icaoBlocks, military.last)
+		if first == nil || first != last || first.country != military.country {
+			t.Errorf("military block %06X-%06X (%s) is not inside a block of %s", military.first, military.last, military.country, military.country)
+		}
+	}
+}
+
+func TestLookupBlock(t *testing.T) {
+	tests := []struct {
+		address uint32
+		country string // "" for none
+	}{
+		{0x000000, ""},
+		{0x003FFF, ""},
+		{0x004000, "Zimbabwe"}, // first block
+		{0x0043FF, "Zimbabwe"},
+		{0x004400, ""}, // gap
+		{0x005FFF, ""},
+		{0x006000, "Mozambique"},
+		{0x3BFFFF, "France"},
+		{0x3C0000, "Germany"},
+		{0x3FFFFF, "Germany"},
+		{0x400000, "United Kingdom"},
+		{0x4CA2D6, "Ireland"},
+		{0xE94000, "Bolivia"},
+		{0xE94FFF, "Bolivia"}, // last block
+		{0xE95000, ""},
+		{0xFFFFFF, ""},
+	}
+	for _, tt := range tests {
+		var country string
+		if block := lookupBlock(icaoBlocks, tt.address); block != nil {
+			country = block.country
+		}
+		if country != tt.country {
+			t.Errorf("lookupBlock(%06X) = %q, want %q", tt.address, country, tt.country)
+		}
+	}
+	if block := lookupBlock(nil, 0x4CA2D6); block != nil {
+		t.Errorf("lookupBlock of no blocks = %+v, want nil", block)
+	}
+}
+
+func TestICAO(t *testing.T) {
+	tests := []struct {
+		name string
+		data models.AircraftData
+		tags map[string]string
+	}{
+		{"civil", models.AircraftData{HexIdent: "4CA2D6"},
+			map[string]string{TagCountry: "Ireland", TagMilitary: "false"}},
+		{"lower case", models.AircraftData{HexIdent: "4ca2d6"},
+			map[string]string{TagCountry: "Ireland", TagMilitary: "false"}},
+		{"before a military range", models.AircraftData{HexIdent: "3F3FFF"},
+			map[string]string{TagCountry: "Germany", TagMilitary: "false"}},
+		{"first address of a military range", models.AircraftData{HexIdent: "3F4000"},
+			map[string]string{TagCountry: "Germany", TagMilitary: "true"}},
+		{"last address of a military range", models.AircraftData{HexIdent: "3FBFFF"},
+			map[string]string{TagCountry: "Germany", TagMilitary: "true"}},
+		{"after a military range", models.AircraftData{HexIdent: "3FC000"},
+			map[string]string{TagCountry: "Germany", TagMilitary: "false"}},
+		{"military range at the start of a block", models.AircraftData{HexIdent: "40003F"},
+			map[string]string{TagCountry: "United Kingdom", TagMilitary: "true"}},
+		{"military range at the end of a block", models.AircraftData{HexIdent: "AFFFFF"},
+			map[string]string{TagCountry: "United States", TagMilitary: "true"}},
+		{"unallocated", models.AircraftData{HexIdent: "F00000"},
+			map[string]string{TagMilitary: "false"}},
+		{"country already tagged", models.AircraftData{HexIdent: "3F4000", Tags: map[string]string{TagCountry: "Deutschland"}},
+			map[string]string{TagCountry: "Deutschland", TagMilitary: "true"}},
+		{"not an address", models.AircraftData{HexIdent: "~4CA2D6"}, nil},
+		{"too long", models.AircraftData{HexIdent: "14CA2D6"}, nil},
+		{"empty", models.AircraftData{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data
+			if keep, err := (ICAO{}).Process(context.Background(), &data); !keep || err != nil {
+				t.Fatalf("Process = %t, %v; want true, nil", keep, err)
+			}
+			if !maps.Equal(data.Tags, tt.tags) {
+				t.Errorf("tags = %v, want %v", data.Tags, tt.tags)
+			}
+		})
+	}
+}
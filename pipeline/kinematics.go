@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"context"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+const (
+	// FieldDistance is the field holding the great-circle distance from the
+	// receiver to the aircraft, in kilometres.
+	FieldDistance = "distance_km"
+	// FieldBearing is the field holding the bearing of the aircraft from the
+	// receiver, in degrees clockwise from true north.
+	FieldBearing = "bearing_deg"
+	// FieldElevation is the field holding the angle above the receiver's
+	// horizon at which the aircraft is seen, in degrees.
+	FieldElevation = "elevation_deg"
+
+	feetToKm = 0.0003048
+)
+
+func init() {
+	Register("kinematics", func(cfg *config.Config, _ config.StageConfig) (Processor, error) {
+		return NewKinematics(sourcePositions(cfg)), nil
+	})
+}
+
+// Kinematics adds the distance, bearing and elevation of the aircraft seen
+// from the receiver to every position record, as fields. The elevation needs
+// the aircraft's altitude, which surface positions lack. Records of receivers
+// whose position is unknown are left as they are.
+type Kinematics struct {
+	positions map[string]Position
+}
+
+// NewKinematics creates a Kinematics stage for receivers at the given
+// positions, keyed by receiver name.
+func NewKinematics(positions map[string]Position) *Kinematics {
+	return &Kinematics{positions: positions}
+}
+
+// Process implements the Processor interface.
+func (k *Kinematics) Process(_ context.Context, data *models.AircraftData) (bool, error) {
+	pos, ok := k.positions[data.Receiver]
+	if !ok || data.Latitude == nil || data.Longitude == nil {
+		return true, nil
+	}
+	distance := distanceKm(pos.Latitude, pos.Longitude, *data.Latitude, *data.Longitude)
+	data.SetField(FieldDistance, distance)
+	data.SetField(FieldBearing, bearingDeg(pos.Latitude, pos.Longitude, *data.Latitude, *data.Longitude))
+	if data.Altitude != nil {
+		data.SetField(FieldElevation, elevationDeg(distance, float64(pos.AltitudeFt)*feetToKm, float64(*data.Altitude)*feetToKm))
+	}
+	return true, nil
+}
@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+func TestSourcePositions(t *testing.T) {
+	cfg := config.Default()
+	cfg.Receiver.Latitude, cfg.Receiver.Longitude, cfg.Receiver.Altitude = 51.4775, -0.4614, 80
+	cfg.Sources = []config.SourceConfig{
+		{Name: "roof"},
+		{Name: "attic", Latitude: 48.8566, Longitude: 2.3522, Altitude: 150},
+	}
+	positions := sourcePositions(cfg)
+	if got, want := positions["roof"], (Position{51.4775, -0.4614, 80}); got != want {
+		t.Errorf("roof at %+v, want the station's %+v", got, want)
+	}
+	if got, want := positions["attic"], (Position{48.8566, 2.3522, 150}); got != want {
+		t.Errorf("attic at %+v, want its own %+v", got, want)
+	}
+
+	cfg.Receiver.Latitude, cfg.Receiver.Longitude = 0, 0
+	if _, ok := sourcePositions(cfg)["roof"]; ok {
+		t.Error("roof has a position without its own or the station's")
+	}
+}
+
+func TestKinematics(t *testing.T) {
+	k := NewKinematics(map[string]Position{
+		"equator": {0, 0, 0},
+		"paris":   {48.8566, 2.3522, 0},
+	})
+	tests := []struct {
+		name     string
+		data     models.AircraftData
+		distance float64 // -1 for none
+		bearing  float64
+		elev     float64 // -100 for none
+	}{
+		{"north at altitude", models.AircraftData{Receiver: "equator", Latitude: ptr(1.0), Longitude: ptr(0.0), Altitude: ptr(35000)},
+			111.195, 0, 4.9755},
+		{"over London from Paris", models.AircraftData{Receiver: "paris", Latitude: ptr(51.5074), Longitude: ptr(-0.1278), Altitude: ptr(10000)},
+			343.556, 330.021, -1.0368},
+		{"surface position", models.AircraftData{Receiver: "equator", Latitude: ptr(0.0), Longitude: ptr(-1.0)},
+			111.195, 270, -100},
+		{"no position", models.AircraftData{Receiver: "equator", Altitude: ptr(35000)}, -1, 0, -100},
+		{"receiver without a position", models.AircraftData{Receiver: "attic", Latitude: ptr(1.0), Longitude: ptr(0.0)}, -1, 0, -100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data
+			if keep, err := k.Process(context.Background(), &data); !keep || err != nil {
+				t.Fatalf("Process = %t, %v; want true, nil", keep, err)
+			}
+			if tt.distance < 0 {
+				if len(data.Fields) > 0 {
+					t.Errorf("fields = %v, want none", data.Fields)
+				}
+				return
+			}
+			if got := data.Fields[FieldDistance]; !near(got, tt.distance, 0.001) {
+				t.Errorf("%s = %.3f, want %.3f", FieldDistance, got, tt.distance)
+			}
+			if got := data.Fields[FieldBearing]; !near(got, tt.bearing, 0.001) {
+				t.Errorf("%s = %.3f, want %.3f", FieldBearing, got, tt.bearing)
+			}
+			got, ok := data.Fields[FieldElevation]
+			if tt.elev == -100 {
+				if ok {
+					t.Errorf("%s = %.4f, want none", FieldElevation, got)
+				}
+			} else if !near(got, tt.elev, 0.001) {
+				t.Errorf("%s = %.4f, want %.4f", FieldElevation, got, tt.elev)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,150 @@
+// Package pipeline defines the processing stages that run on every parsed
+// record between parsing and batching: filters, enrichers, deduplication and
+// derived metrics. Stages are listed under pipeline.stages in the
+// configuration and run in that order; stage types other than the built-in
+// ones can be added with Register.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// Processor processes one record. It may modify the record, e.g. to add tags,
+// and returns whether the record is kept: a dropped record is neither batched
+// nor passed to the following stages. A processor that fails to process a
+// record returns an error, which is reported; the record is then kept or
+// dropped as keep says.
+//
+// Processors are called from a single goroutine and must not keep the record
+// after returning: copy what they need. Processors that also implement
+// io.Closer are closed when they are replaced or the collector stops.
+type Processor interface {
+	Process(ctx context.Context, data *models.AircraftData) (keep bool, err error)
+}
+
+// ProcessorFunc adapts a function to the Processor interface.
+type ProcessorFunc func(ctx context.Context, data *models.AircraftData) (keep bool, err error)
+
+// Process implements the Processor interface.
+func (f ProcessorFunc) Process(ctx context.Context, data *models.AircraftData) (bool, error) {
+	return f(ctx, data)
+}
+
+// emitterKey is the context key of the function that receives emitted records.
+type emitterKey struct{}
+
+// WithEmitter returns a context in which Emit passes records to emit.
+func WithEmitter(ctx context.Context, emit func(models.AircraftData)) context.Context {
+	return context.WithValue(ctx, emitterKey{}, emit)
+}
+
+// Emit adds a record of a processor's own, such as an event, to the batch
+// being assembled after the record being processed. Emitted records do not
+// run through the stages. Emit does nothing if ctx carries no emitter.
+func Emit(ctx context.Context, data models.AircraftData) {
+	if emit, ok := ctx.Value(emitterKey{}).(func(models.AircraftData)); ok {
+		emit(data)
+	}
+}
+
+// counterKey is the context key of the function that receives counts.
+type counterKey struct{}
+
+// WithCounter returns a context in which Count passes counts to count.
+func WithCounter(ctx context.Context, count func(ctx context.Context, counter string, n int64)) context.Context {
+	return context.WithValue(ctx, counterKey{}, count)
+}
+
+// Count adds n to a counter of the stage processing the record, such as the
+// lookups that found nothing. The collector exports the counters as metrics,
+// by stage and counter. Count does nothing if ctx carries no counter.
+func Count(ctx context.Context, counter string, n int64) {
+	if count, ok := ctx.Value(counterKey{}).(func(context.Context, string, int64)); ok {
+		count(ctx, counter, n)
+	}
+}
+
+// Stage is a processor together with the name it is reported under.
+type Stage struct {
+	Name      string
+	Processor Processor
+}
+
+// Factory creates the processor of a configured stage. cfg is the whole
+// configuration, for stages that depend on other settings such as the
+// receiver's position.
+type Factory func(cfg *config.Config, stage config.StageConfig) (Processor, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a stage type available to the configuration, whose
+// validation then accepts it. It panics if the type is already registered.
+func Register(typ string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[typ]; ok {
+		panic(fmt.Sprintf("pipeline: stage type %q registered twice", typ))
+	}
+	registry[typ] = factory
+	config.RegisterStageType(typ)
+}
+
+// Types returns the registered stage types, sorted.
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(registry))
+	for typ := range registry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Build creates the stages of cfg.Pipeline.Stages, in order. If a stage cannot
+// be created, those already created are closed.
+func Build(cfg *config.Config) ([]Stage, error) {
+	stages := make([]Stage, 0, len(cfg.Pipeline.Stages))
+	for i, stageCfg := range cfg.Pipeline.Stages {
+		registryMu.RLock()
+		factory, ok := registry[stageCfg.Type]
+		registryMu.RUnlock()
+		var p Processor
+		var err error
+		if ok {
+			p, err = factory(cfg, stageCfg)
+		} else {
+			err = fmt.Errorf("unknown stage type %q (registered: %v)", stageCfg.Type, Types())
+		}
+		if err != nil {
+			Close(stages)
+			return nil, fmt.Errorf("pipeline.stages[%d]: %w", i, err)
+		}
+		stages = append(stages, Stage{Name: stageCfg.Name, Processor: p})
+	}
+	return stages, nil
+}
+
+// Close closes the processors of stages that implement io.Closer.
+func Close(stages []Stage) error {
+	var errs []error
+	for _, stage := range stages {
+		if c, ok := stage.Processor.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", stage.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,152 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/config"
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+const (
+	// TagRegistration is the tag holding the aircraft's registration, e.g. "EI-DEA".
+	TagRegistration = "registration"
+	// TagTypeCode is the tag holding the ICAO type designator, e.g. "A320".
+	TagTypeCode = "type_code"
+	// TagOperator is the tag holding the aircraft's operator.
+	TagOperator = "operator"
+	// TagManufacturer is the tag holding the aircraft's manufacturer.
+	TagManufacturer = "manufacturer"
+	// TagYear is the tag holding the year the aircraft was built.
+	TagYear = "year"
+)
+
+func init() {
+	Register("registry", func(_ *config.Config, stage config.StageConfig) (Processor, error) {
+		return NewRegistry(stage.Path, stage.WatchInterval)
+	})
+}
+
+// registryColumns maps the normalized CSV header names to the fields of an
+// aircraft. The aliases cover common dumps such as OpenSky's aircraft database.
+var registryColumns = map[string]string{
+	"icao24":           "hex",
+	"icao":             "hex",
+	"hex":              "hex",
+	"icaohex":          "hex",
+	"modes":            "hex",
+	"registration":     TagRegistration,
+	"reg":              TagRegistration,
+	"typecode":         TagTypeCode,
+	"icaotype":         TagTypeCode,
+	"typedesignator":   TagTypeCode,
+	"operator":         TagOperator,
+	"manufacturer":     TagManufacturer,
+	"manufacturername": TagManufacturer,
+	"year":             TagYear,
+	"built":            TagYear,
+	"yearbuilt":        TagYear,
+}
+
+// registryAircraft is what the registry knows about an aircraft.
+type registryAircraft struct {
+	registration string
+	typeCode     string
+	operator     string
+	manufacturer string
+	year         string
+}
+
+// Registry tags records with the registration, type designator, operator,
+// manufacturer and build year of the aircraft, looked up by address in a CSV
+// file. The file is loaded into memory and loaded again when it changes. Hits
+// and misses are counted as the "hits" and "misses" counters of the stage.
+type Registry struct {
+	aircraft atomic.Pointer[map[uint32]registryAircraft]
+	watcher  *fileWatcher
+}
+
+// NewRegistry loads the CSV file at path and checks it for changes every
+// watchInterval. The file has a header row naming its columns: an address
+// column (icao24, icao or hex) is required, and the registration, typecode,
+// operator, manufacturer and year (or built) columns are used if present.
+func NewRegistry(path string, watchInterval time.Duration) (*Registry, error) {
+	r := &Registry{}
+	watcher, err := watchFile(path, watchInterval, func(path string) error {
+		aircraft, err := loadRegistry(path)
+		if err != nil {
+			return err
+		}
+		r.aircraft.Store(&aircraft)
+		log.Printf("Loaded %d aircraft from registry %s.", len(aircraft), path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load registry: %w", err)
+	}
+	r.watcher = watcher
+	return r, nil
+}
+
+// Process implements the Processor interface.
+func (r *Registry) Process(ctx context.Context, data *models.AircraftData) (bool, error) {
+	address, err := strconv.ParseUint(data.HexIdent, 16, 24)
+	if err != nil {
+		return true, nil
+	}
+	ac, ok := (*r.aircraft.Load())[uint32(address)]
+	if !ok {
+		Count(ctx, "misses", 1)
+		return true, nil
+	}
+	Count(ctx, "hits", 1)
+	for _, tag := range [...]struct{ key, value string }{
+		{TagRegistration, ac.registration},
+		{TagTypeCode, ac.typeCode},
+		{TagOperator, ac.operator},
+		{TagManufacturer, ac.manufacturer},
+		{TagYear, ac.year},
+	} {
+		if tag.value != "" {
+			data.SetTag(tag.key, tag.value)
+		}
+	}
+	return true, nil
+}
+
+// Close stops watching the file.
+func (r *Registry) Close() error {
+	return r.watcher.Close()
+}
+
+// loadRegistry reads a registry CSV file. Rows with an invalid address are skipped.
+func loadRegistry(path string) (map[uint32]registryAircraft, error) {
+	aircraft := make(map[uint32]registryAircraft)
+	shared := make(interner)
+	err := readCSV(path, registryColumns, "hex", func(value func(string) string) {
+		address, err := strconv.ParseUint(value("hex"), 16, 24)
+		if err != nil {
+			return
+		}
+		year := value(TagYear)
+		if len(year) > 4 {
+			year = year[:4] // a date, e.g. OpenSky's "built"
+		}
+		aircraft[uint32(address)] = registryAircraft{
+			registration: strings.Clone(value(TagRegistration)),
+			typeCode:     shared.intern(value(TagTypeCode)),
+			operator:     shared.intern(value(TagOperator)),
+			manufacturer: shared.intern(value(TagManufacturer)),
+			year:         shared.intern(year),
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return aircraft, nil
+}
@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"context"
+	"maps"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/m03315/go-dump1090-timeseries-collector/models"
+)
+
+// counting returns a context whose counts are added to counts.
+func counting(counts map[string]int64) context.Context {
+	return WithCounter(context.Background(), func(_ context.Context, counter string, n int64) {
+		counts[counter] += n
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	path := writeFile(t, "registry.csv", "icao24,registration,typecode,operator,manufacturername,built\n"+
+		"4CA2D6,EI-DEA,A320,Aer Lingus,Airbus,2004-05-01\n"+
+		"40621d,G-ABCD,,,,\n"+
+		"zzzzzz,BAD,,,,\n")
+	r, err := NewRegistry(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	tests := []struct {
+		hex    string
+		tags   map[string]string
+		counts map[string]int64
+	}{
+		{"4CA2D6", map[string]string{
+			TagRegistration: "EI-DEA", TagTypeCode: "A320", TagOperator: "Aer Lingus", TagManufacturer: "Airbus", TagYear: "2004",
+		}, map[string]int64{"hits": 1}},
+		{"4ca2d6", map[string]string{
+			TagRegistration: "EI-DEA", TagTypeCode: "A320", TagOperator: "Aer Lingus", TagManufacturer: "Airbus", TagYear: "2004",
+		}, map[string]int64{"hits": 1}},
+		{"40621D", map[string]string{TagRegistration: "G-ABCD"}, map[string]int64{"hits": 1}},
+		{"A00001", nil, map[string]int64{"misses": 1}},
+		{"", nil, map[string]int64{}},
+		{"~4CA2D6", nil, map[string]int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hex, func(t *testing.T) {
+			counts := make(map[string]int64)
+			data := &models.AircraftData{HexIdent: tt.hex}
+			if keep, err := r.Process(counting(counts), data); !keep || err != nil {
+				t.Fatalf("Process = %t, %v; want true, nil", keep, err)
+			}
+			if !maps.Equal(data.Tags, tt.tags) {
+				t.Errorf("tags = %v, want %v", data.Tags, tt.tags)
+			}
+			if !maps.Equal(counts, tt.counts) {
+				t.Errorf("counts = %v, want %v", counts, tt.counts)
+			}
+		})
+	}
+}
+
+func TestRegistryReload(t *testing.T) {
+	path := writeFile(t, "registry.csv", "hex,reg\n4ca2d6,EI-DEA\n")
+	r, err := NewRegistry(path, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	registration := func() string {
+		data := &models.AircraftData{HexIdent: "4CA2D6"}
+		r.Process(context.Background(), data)
+		return data.Tags[TagRegistration]
+	}
+	if got := registration(); got != "EI-DEA" {
+		t.Fatalf("registration = %q, want EI-DEA", got)
+	}
+
+	// A file that fails to load keeps the previous data.
+	if err := os.WriteFile(path, []byte("reg\nEI-DEB\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := registration(); got != "EI-DEA" {
+		t.Fatalf("registration after a bad file = %q, want EI-DEA", got)
+	}
+
+	if err := os.WriteFile(path, []byte("hex,reg\n4ca2d6,EI-DEF\n40621d,G-ABCD\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); registration() != "EI-DEF"; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("registration = %q after the file changed, want EI-DEF", registration())
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// stampFile returns the stamp of the file at path.
+func stampFile(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// fileWatcher loads a data file again whenever it changes.
+type fileWatcher struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// watchFile loads the file at path, then checks every interval whether its
+// size or modification time changed and loads it again, until the watcher is
+// closed. The error of the first load is returned; if the file cannot be
+// loaded again, the error is logged and what was loaded before is kept.
+func watchFile(path string, interval time.Duration, load func(path string) error) (*fileWatcher, error) {
+	stamp, err := stampFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := load(path); err != nil {
+		return nil, err
+	}
+	w := &fileWatcher{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+			current, err := stampFile(path)
+			if err != nil {
+				log.Printf("Warning: cannot watch %s: %v", path, err)
+				continue
+			}
+			if current.size == stamp.size && current.modTime.Equal(stamp.modTime) {
+				continue
+			}
+			if err := load(path); err != nil {
+				log.Printf("ERROR: failed to reload %s, keeping the previous data: %v", path, err)
+				// Retried once the file changes again, e.g. when a partial copy completes.
+			}
+			stamp = current
+		}
+	}()
+	return w, nil
+}
+
+// Close stops watching the file.
+func (w *fileWatcher) Close() error {
+	close(w.stop)
+	<-w.done
+	return nil
+}